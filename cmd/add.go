@@ -18,8 +18,12 @@ var addCmd = &cobra.Command{
 		filePath, _ := internal.ResolvePath(file)
 
 		folderPath := cfg.FolderPath
+		if err := checkWritable(); err != nil {
+			fmt.Printf("Error adding file: %v\n", err)
+			return
+		}
 
-		err := manager.AddFile(filePath, folderPath, force)
+		err := manager.AddFile(prompter, filePath, folderPath, force)
 		if err != nil {
 			fmt.Printf("Error adding file: %v\n", err)
 			return
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ZonCen/dotman/internal/config"
+	"github.com/ZonCen/dotman/internal/manager"
+)
+
+var intoBranch string
+
+// branchCmd represents the branch command
+var branchCmd = &cobra.Command{
+	Use:   "branch",
+	Short: "Work on a per-host branch of your dotfiles repo",
+	Run: func(cmd *cobra.Command, args []string) {
+		current, err := manager.GitBackend().CurrentBranch(cfg.FolderPath)
+		if err != nil {
+			fmt.Printf("Error reading branch: %v\n", err)
+			return
+		}
+		fmt.Printf("Checked out: %v\n", current)
+		if cfg.Branch != "" {
+			fmt.Printf("Synced:      %v\n", cfg.Branch)
+		}
+	},
+}
+
+var branchCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a host branch from the current one and sync it from now on",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := checkWritable(); err != nil {
+			fmt.Printf("Error creating branch: %v\n", err)
+			return
+		}
+		err := manager.CreateBranch(cfg.FolderPath, args[0])
+		if err != nil {
+			fmt.Printf("Error creating branch: %v\n", err)
+			return
+		}
+		saveBranch(args[0])
+		fmt.Printf("Created and switched to %v\n", args[0])
+	},
+}
+
+var branchSwitchCmd = &cobra.Command{
+	Use:   "switch [name]",
+	Short: "Check out another branch, deploy its entries and sync it from now on",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := manager.SwitchBranch(prompter, cfg.FolderPath, args[0])
+		if err != nil {
+			fmt.Printf("Error switching branch: %v\n", err)
+			return
+		}
+		saveBranch(args[0])
+		fmt.Printf("Switched to %v\n", args[0])
+	},
+}
+
+var branchMergeCmd = &cobra.Command{
+	Use:   "merge [branch]",
+	Short: "Merge a host branch (default the current one) back into the shared branch",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := checkWritable(); err != nil {
+			fmt.Printf("Error merging branch: %v\n", err)
+			return
+		}
+		from := ""
+		if len(args) == 1 {
+			from = args[0]
+		}
+		into, err := sharedBranch(from)
+		if err != nil {
+			fmt.Printf("Error merging branch: %v\n", err)
+			return
+		}
+		err = manager.MergeBranch(cfg.FolderPath, from, into)
+		if err != nil {
+			fmt.Printf("Error merging branch: %v\n", err)
+			return
+		}
+		fmt.Printf("Merged into %v\n", into)
+	},
+}
+
+var pickFrom string
+
+var branchPickCmd = &cobra.Command{
+	Use:   "pick [entry...]",
+	Short: "Copy individual entries from a host branch (default the current one) to the shared branch",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := checkWritable(); err != nil {
+			fmt.Printf("Error picking entries: %v\n", err)
+			return
+		}
+		into, err := sharedBranch(pickFrom)
+		if err != nil {
+			fmt.Printf("Error picking entries: %v\n", err)
+			return
+		}
+		err = manager.PickEntries(cfg.FolderPath, pickFrom, into, args)
+		if err != nil {
+			fmt.Printf("Error picking entries: %v\n", err)
+			return
+		}
+		fmt.Printf("Picked %v into %v\n", args, into)
+	},
+}
+
+// sharedBranch returns the branch given with --into, or else the shared branch to merge from into
+func sharedBranch(from string) (string, error) {
+	if intoBranch != "" {
+		return intoBranch, nil
+	}
+	return manager.SharedBranch(cfg.FolderPath, cfg.Branch, from)
+}
+
+func saveBranch(branch string) {
+	change := func(r *config.Repo) { r.Branch = branch }
+	cfg.UpdateRepo(cfg.RepoName, change)
+	if err := config.Update(configPath, func(c *config.Config) { c.UpdateRepo(cfg.RepoName, change) }); err != nil {
+		fmt.Printf("Could not save branch to config: %v\n", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(branchCmd)
+	branchCmd.AddCommand(branchCreateCmd, branchSwitchCmd, branchMergeCmd, branchPickCmd)
+
+	branchCmd.PersistentFlags().StringVar(&intoBranch,
+		"into",
+		"",
+		"Shared branch that merge and pick write to (default the configured branch or the remote's default branch)")
+	branchPickCmd.Flags().StringVar(&pickFrom,
+		"from",
+		"",
+		"Branch to pick entries from (default the current branch)")
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/config"
+)
+
+// configErr is why the config could not be loaded, the config command still runs to fix it
+var configErr error
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show, change and validate the dotman configuration",
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting, its default when unset",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if configErr != nil {
+			fmt.Println(configErr)
+			return
+		}
+		value, set, err := cfg.Get(args[0])
+		if err != nil {
+			fmt.Printf("Error reading config: %v\n", err)
+			return
+		}
+		if !set {
+			if setting, ok := config.Lookup(args[0]); ok {
+				value = setting.Default
+			}
+		}
+		fmt.Println(value)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in the config file, dir_modes.<dir> sets one directory",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.Set(configPath, args[0], args[1]); err != nil {
+			fmt.Printf("Error changing config: %v\n", err)
+			return
+		}
+		internal.LogVerbose("Set %v to %v in %v", args[0], args[1], configPath)
+		if source := cfg.Source(args[0]); strings.HasPrefix(source, "DOTMAN_") {
+			fmt.Printf("Note: %v is set and overrides %v\n", source, args[0])
+		}
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting from the config file so its default applies",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.Unset(configPath, args[0]); err != nil {
+			fmt.Printf("Error changing config: %v\n", err)
+			return
+		}
+		internal.LogVerbose("Removed %v from %v", args[0], configPath)
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every setting with its value, where it comes from and what it does",
+	Run: func(cmd *cobra.Command, args []string) {
+		if configErr != nil {
+			fmt.Println(configErr)
+			return
+		}
+		fmt.Printf("Config file: %v\n\n", internal.ShrinkPath(configPath))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tFROM\tDESCRIPTION")
+		for _, setting := range config.Schema {
+			value, set, err := cfg.Get(setting.Key)
+			if err != nil {
+				fmt.Printf("Error reading config: %v\n", err)
+				return
+			}
+			if !set {
+				value = setting.Default
+			}
+			// Lists and maps are shown on one line, 'config get' prints them in full
+			value = strings.ReplaceAll(value, "\n", " ")
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", setting.Key, value, cfg.Source(setting.Key), setting.Description)
+		}
+		_ = w.Flush()
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the config file in $EDITOR, it is only saved once it is valid",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := editConfig(); err != nil {
+			fmt.Printf("Error editing config: %v\n", err)
+		}
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config for unknown keys, invalid values, missing paths and targets tracked twice",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.Validate(configPath); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if printTargetConflicts() {
+			os.Exit(1)
+		}
+		fmt.Printf("%v is valid\n", internal.ShrinkPath(configPath))
+	},
+}
+
+// editConfig opens a copy of the config in the editor and replaces the config with it once it is valid, an
+// invalid copy can be edited again or thrown away
+func editConfig() error {
+	if internal.NonInteractive {
+		return fmt.Errorf("editing needs a terminal, use 'dotman config set' instead")
+	}
+	content := []byte(configTemplate())
+	if internal.FileExist(configPath) {
+		read, err := os.ReadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("could not read %v: %w", configPath, err)
+		}
+		content = read
+	}
+
+	tmp, err := os.CreateTemp("", "dotman-config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("could not create a copy to edit: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("could not create a copy to edit: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not create a copy to edit: %w", err)
+	}
+
+	for {
+		if err := internal.OpenEditor(tmp.Name()); err != nil {
+			return err
+		}
+		_, err := config.LoadConf(tmp.Name())
+		if err == nil {
+			break
+		}
+		fmt.Println(strings.ReplaceAll(err.Error(), tmp.Name(), configPath))
+		again, err := prompter.Confirm("config.edit-again", "Edit again? Otherwise your changes are discarded (y/N)")
+		if err != nil {
+			return err
+		}
+		if !again {
+			fmt.Println("Changes discarded, the config was not changed")
+			return nil
+		}
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("could not read the edited config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("could not create %v: %w", filepath.Dir(configPath), err)
+	}
+	if err := internal.WriteFileAtomic(configPath, edited, 0644); err != nil {
+		return fmt.Errorf("could not save %v: %w", configPath, err)
+	}
+	fmt.Printf("Saved %v\n", internal.ShrinkPath(configPath))
+	return nil
+}
+
+// configTemplate starts a new config with every setting commented out
+func configTemplate() string {
+	lines := []string{"# dotman config, see 'dotman config list'"}
+	for _, setting := range config.Schema {
+		lines = append(lines, fmt.Sprintf("# %v - %v (default %v)", setting.Key, setting.Description, setting.Default))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd, configListCmd, configEditCmd, configValidateCmd)
+}
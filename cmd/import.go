@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/importer"
+	"github.com/ZonCen/dotman/internal/manager"
+)
+
+var (
+	importFrom   string
+	importTarget string
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import --from stow|chezmoi|bare|yadm [path]",
+	Short: "Convert dotfiles kept by stow, chezmoi or a bare git repository into dotman entries",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := checkWritable(); err != nil {
+			fmt.Printf("Error importing: %v\n", err)
+			return
+		}
+		home, _ := os.UserHomeDir()
+
+		source := ""
+		if len(args) > 0 {
+			source = args[0]
+		}
+		switch {
+		case source != "":
+		case importFrom == importer.FromChezmoi:
+			source = filepath.Join(home, ".local", "share", "chezmoi")
+		case importFrom == importer.FromYadm:
+			source = filepath.Join(home, ".local", "share", "yadm", "repo.git")
+		default:
+			fmt.Printf("Error importing: give the path of the %v directory\n", importFrom)
+			return
+		}
+		source, err := internal.ResolvePath(source)
+		if err != nil {
+			fmt.Printf("Error importing: %v\n", err)
+			return
+		}
+
+		// stow links into the parent of the stow directory unless told otherwise, the others into $HOME
+		target := importTarget
+		if target == "" {
+			target = home
+			if importFrom == importer.FromStow {
+				target = filepath.Dir(source)
+			}
+		}
+		target, err = internal.ResolvePath(target)
+		if err != nil {
+			fmt.Printf("Error importing: %v\n", err)
+			return
+		}
+
+		internal.LogVerbose("Importing %v from %v into %v, linked from %v", importFrom, source, cfg.FolderPath, target)
+		if err := manager.ImportDotfiles(prompter, cfg.FolderPath, importFrom, source, target); err != nil {
+			fmt.Printf("Error importing: %v\n", err)
+			return
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVar(&importFrom, "from", "", "Layout to import: stow, chezmoi, bare or yadm")
+	importCmd.Flags().StringVar(&importTarget, "target", "",
+		"Directory the files are linked from (default the parent of a stow directory, otherwise $HOME)")
+	_ = importCmd.MarkFlagRequired("from")
+}
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/config"
+	"github.com/ZonCen/dotman/internal/git"
 	"github.com/ZonCen/dotman/internal/manager"
 )
 
@@ -14,6 +17,8 @@ var (
 	repository string
 	branch     string
 	force      bool
+	remotes    []string
+	authType   string
 )
 
 // initCmd represents the init command
@@ -21,40 +26,162 @@ var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize your dotman configuration and folder",
 	Run: func(cmd *cobra.Command, args []string) {
+		// Without --folderpath init works on the repository of the config, ~/dotfiles when there is none
+		if folderPath == "" {
+			folderPath = cfg.FolderPath
+		}
+		if folderPath == "" {
+			fmt.Printf("Error initialize repository: %v is a new repository, give its folder with --folderpath\n",
+				cfg.RepoName)
+			return
+		}
 		internal.LogVerbose("Trying to resolve path %v", folderPath)
 		folderPath, err := internal.ResolvePath(folderPath)
 		if err != nil {
 			fmt.Printf("Could not read the folderPath")
 			return
 		}
+		if other := repoUsing(folderPath); other != "" {
+			fmt.Printf("Error initialize repository: %v is the folder of repository %v\n", folderPath, other)
+			return
+		}
+
+		if authType != "" {
+			repository, err = switchTransport(folderPath, repository, authType)
+			if err != nil {
+				fmt.Printf("Error initialize repository: %v\n", err)
+				return
+			}
+			force = true
+		}
+
+		mirrors, err := parseRemotes(remotes)
+		if err != nil {
+			fmt.Printf("Error initialize repository: %v\n", err)
+			return
+		}
 
 		internal.LogVerbose("Starting the initialization")
-		err = manager.Init(folderPath, repository, branch, force)
+		branch, err = manager.Init(prompter, folderPath, repository, branch, force)
 		if err != nil {
 			fmt.Printf("Error initialize repository: %v\n", err)
 			return
 		}
+		for _, mirror := range mirrors {
+			if err := manager.EnsureRemote(folderPath, mirror.Name, mirror.URL, force); err != nil {
+				fmt.Printf("Error initialize repository: %v\n", err)
+				return
+			}
+		}
+		if err := saveInitConfig(folderPath, repository, branch, mirrors); err != nil {
+			fmt.Printf("Could not save the config: %v\n", err)
+			return
+		}
 		fmt.Println("init has been run successfully")
 	},
 }
 
+// saveInitConfig writes the repository init set up to the config, so every other command works on it
+func saveInitConfig(folderPath, repository, branch string, mirrors []config.Remote) error {
+	if repository == "" {
+		// Without --repository the config keeps track of the remote the repository already had
+		repository, _ = manager.GitBackend().GetRemoteURL(folderPath, manager.PrimaryRemote())
+	}
+	change := func(r *config.Repo) {
+		r.SetFolder(folderPath)
+		if repository != "" {
+			r.SetRemote(config.Remote{Name: manager.PrimaryRemote(), URL: repository, Primary: true})
+		}
+		for _, mirror := range mirrors {
+			r.SetRemote(mirror)
+		}
+		if branch != "" {
+			r.Branch = branch
+		}
+	}
+	cfg.UpdateRepo(cfg.RepoName, change)
+
+	internal.LogVerbose("Saving the config to %v", configPath)
+	if err := config.Update(configPath, func(c *config.Config) { c.UpdateRepo(cfg.RepoName, change) }); err != nil {
+		return err
+	}
+	fmt.Printf("Saved the config to %v\n", internal.ShrinkPath(configPath))
+	return nil
+}
+
+// repoUsing returns the other repository kept in folderPath, empty when there is none
+func repoUsing(folderPath string) string {
+	for _, name := range baseCfg.RepoNames() {
+		scoped, err := baseCfg.ForRepo(name)
+		if err == nil && name != cfg.CurrentRepo() && scoped.FolderPath == folderPath {
+			return name
+		}
+	}
+	return ""
+}
+
+// switchTransport returns repository, or the current remote when none is given, reached over ssh or https
+func switchTransport(folderPath, repository, transport string) (string, error) {
+	if repository == "" {
+		current, err := manager.GitBackend().GetRemoteURL(folderPath, manager.PrimaryRemote())
+		if err != nil {
+			return "", fmt.Errorf("no --repository given and %v has no remote to switch: %w", folderPath, err)
+		}
+		repository = current
+	}
+	parsed, err := git.ParseURL(repository)
+	if err != nil {
+		return "", err
+	}
+	switched, err := parsed.WithTransport(transport)
+	if err != nil {
+		return "", err
+	}
+	internal.LogVerbose("Switching %v to %v", repository, switched)
+	return switched.String(), nil
+}
+
+// parseRemotes turns name=url flags into remotes that every push is mirrored to
+func parseRemotes(flags []string) ([]config.Remote, error) {
+	parsed := []config.Remote{}
+	for _, flag := range flags {
+		name, url, ok := strings.Cut(flag, "=")
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("invalid remote %q, use name=url", flag)
+		}
+		if name == manager.PrimaryRemote() {
+			return nil, fmt.Errorf("%v is the primary remote, set it with --repository", name)
+		}
+		parsed = append(parsed, config.Remote{Name: name, URL: url, Push: true})
+	}
+	return parsed, nil
+}
+
 func init() {
 	rootCmd.AddCommand(initCmd)
 
 	initCmd.Flags().StringVar(&folderPath,
 		"folderpath",
-		"~/dotfiles",
-		"Folder where your dot files will be saved")
+		"",
+		"Folder for your dot files, saved to the config (default the repo_path of the config, or ~/dotfiles)")
 	initCmd.Flags().StringVar(&repository,
 		"repository",
 		"",
 		"Which git repository you want to use. Used to run git init if needed")
 	initCmd.Flags().StringVar(&branch,
 		"branch",
-		"main",
-		"Which branch to use")
+		"",
+		"Which branch to use (default the default branch of the remote, or main for an empty remote)")
+	initCmd.Flags().StringArrayVar(&remotes,
+		"remote",
+		nil,
+		"Additional remote every sync is pushed to, as name=url (repeatable)")
 	initCmd.Flags().BoolVar(&force,
 		"force",
 		false,
-		"If the init should change remote URL to new auth_type")
+		"Change the remote URL when it differs from --repository, for example to switch between SSH and HTTPS")
+	initCmd.Flags().StringVar(&authType,
+		"auth",
+		"",
+		"Switch the remote to ssh or https for any host, implies --force")
 }
@@ -20,19 +20,19 @@ func TestAddCommandIntegration(t *testing.T) {
 	})
 
 	// Set config path environment variable
-	originalConfigPath := os.Getenv("DOTMAN_CONFIG_PATH")
-	err := os.Setenv("DOTMAN_CONFIG_PATH", configPath)
+	originalConfigPath := os.Getenv("DOTMAN_CONFIG")
+	err := os.Setenv("DOTMAN_CONFIG", configPath)
 	if err != nil {
 		t.Errorf("os.Setenv() error = %v", err)
 	}
 	defer func() {
 		if originalConfigPath != "" {
-			err := os.Setenv("DOTMAN_CONFIG_PATH", originalConfigPath)
+			err := os.Setenv("DOTMAN_CONFIG", originalConfigPath)
 			if err != nil {
 				t.Errorf("os.Setenv() error = %v", err)
 			}
 		} else {
-			err := os.Unsetenv("DOTMAN_CONFIG_PATH")
+			err := os.Unsetenv("DOTMAN_CONFIG")
 			if err != nil {
 				t.Errorf("os.UnsetEnv() error = %v", err)
 			}
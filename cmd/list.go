@@ -11,12 +11,16 @@ var listCmd = &cobra.Command{
 	Use:   "list handled files",
 	Short: "Will list all files in the configured repopath",
 	Run: func(cmd *cobra.Command, args []string) {
-		folderPath := cfg.FolderPath
+		forEachRepo(allRepos, func() {
+			folderPath := cfg.FolderPath
 
-		manager.ListFiles(folderPath)
+			manager.ListFiles(folderPath)
+		})
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().BoolVar(&allRepos, "all", false, "List the files of every configured repository")
 }
@@ -8,6 +8,8 @@ import (
 	"github.com/ZonCen/dotman/internal/manager"
 )
 
+var removeAction string
+
 var removeCmd = &cobra.Command{
 	Use:   "remove [filename]",
 	Short: "Remove symlink and move file from repofolder",
@@ -15,8 +17,12 @@ var removeCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fileName := args[0]
 		infoPath := cfg.InfoPath
+		if err := checkWritable(); err != nil {
+			fmt.Printf("Error removing file: %v\n", err)
+			return
+		}
 
-		err := manager.RemoveFile(fileName, infoPath, force)
+		err := manager.RemoveFile(fileName, infoPath, removeAction, force)
 		if err != nil {
 			fmt.Printf("Error removing file: %v\n", err)
 			return
@@ -33,4 +39,8 @@ func init() {
 		"force",
 		false,
 		"Use to delete entry from info.json")
+	removeCmd.Flags().StringVar(&removeAction,
+		"action",
+		"restore",
+		"How the entry is retired on every machine: restore (keep a local copy) or delete")
 }
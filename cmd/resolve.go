@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ZonCen/dotman/internal/manager"
+)
+
+// resolveCmd represents the resolve command
+var resolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Walk through conflicts left by a sync and resolve them",
+	Run: func(cmd *cobra.Command, args []string) {
+		folderPath := cfg.FolderPath
+
+		err := manager.ResolveConflicts(folderPath, manager.SyncOptions{Upload: true, Prompter: prompter})
+		if err != nil {
+			fmt.Printf("Error resolving conflicts: %v\n", err)
+			return
+		}
+		fmt.Println("Conflicts resolved")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resolveCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/manager"
+)
+
+var listBackups bool
+
+// restoreBackupCmd represents the restore-backup command
+var restoreBackupCmd = &cobra.Command{
+	Use:   "restore-backup [id]",
+	Short: "Put back the local files a deploy moved aside, the latest backup by default",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if listBackups {
+			backups, err := manager.ListBackups()
+			if err != nil {
+				fmt.Printf("Error listing backups: %v\n", err)
+				return
+			}
+			if len(backups) == 0 {
+				fmt.Println("No backups")
+				return
+			}
+			for _, backup := range backups {
+				fmt.Printf("%v (%v)\n", backup.ID, backup.Created.Local().Format(time.DateTime))
+				for _, entry := range backup.Entries {
+					fmt.Printf("  %v\n", internal.ShrinkPath(entry.Target))
+				}
+			}
+			return
+		}
+
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		if err := manager.RestoreBackup(id, force); err != nil {
+			fmt.Printf("Error restoring backup: %v\n", err)
+			return
+		}
+		fmt.Println("Backup restored")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreBackupCmd)
+
+	restoreBackupCmd.Flags().BoolVar(&listBackups, "list", false, "List the backups instead of restoring one")
+	restoreBackupCmd.Flags().BoolVar(&force,
+		"force",
+		false,
+		"Overwrite files at the original paths that dotman did not link")
+}
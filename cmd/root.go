@@ -1,51 +1,278 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ZonCen/dotman/internal"
 	"github.com/ZonCen/dotman/internal/config"
+	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/manager"
+	"github.com/ZonCen/dotman/internal/prompt"
 )
 
 var (
-	cfg *config.Config
+	cfg        *config.Config
+	configPath string
+	configFlag string
+
+	// baseCfg is the config of every repository, cfg the one scoped to the repository picked with --repo
+	baseCfg  *config.Config
+	repoFlag string
+
+	// prompter answers every question dotman asks, as chosen by --yes, --no, --non-interactive and --answers
+	prompter       prompt.Prompter
+	assumeYes      bool
+	assumeNo       bool
+	nonInteractive bool
+	answersPath    string
 )
 
-func initConfig() {
-	home, _ := os.UserHomeDir()
-	configPath := filepath.Join(home, ".dotconfig")
+// newPrompter builds the prompter from the global flags, an answers file takes precedence for the questions
+// it answers
+func newPrompter() (prompt.Prompter, error) {
+	if assumeYes && assumeNo {
+		return nil, fmt.Errorf("--yes and --no can not be used together")
+	}
+	var p prompt.Prompter = prompt.Stdio()
+	switch {
+	case assumeYes:
+		p = prompt.Fixed(true)
+	case assumeNo:
+		p = prompt.Fixed(false)
+	case nonInteractive:
+		p = prompt.NonInteractive{}
+	}
+	if answersPath == "" {
+		return p, nil
+	}
+	path, err := internal.ResolvePath(answersPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve %v: %w", answersPath, err)
+	}
+	return prompt.LoadAnswers(path, p)
+}
 
-	if !internal.FileExist(configPath) {
-		if internal.ConfirmWithUser("No config found, do you want to create one? (y/N)") {
-			cfg = &config.Config{FolderPath: filepath.Join(home, "dotfiles"),
-				InfoPath: filepath.Join(home, "dotfiles", "info.json")}
-			_ = config.SaveConf(configPath, cfg)
+// initConfig loads the config every command works from and applies the DOTMAN_* overrides. init and config
+// run without a config file and write it, other commands run without one when $DOTMAN_REPO_PATH gives the
+// repository and otherwise offer to create a config for ~/dotfiles
+func initConfig(cmd *cobra.Command) {
+	var err error
+	prompter, err = newPrompter()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	configPath, err = config.Locate(configFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	internal.LogVerbose("Using config %v", configPath)
+
+	home, _ := os.UserHomeDir()
+	switch {
+	case internal.FileExist(configPath):
+		cfg, err = config.LoadConf(configPath)
+		if err != nil && cmd.Parent() == configCmd {
+			// The config command has to run to fix the config
+			cfg, configErr = config.New(filepath.Join(home, "dotfiles")), err
+		} else if err != nil {
+			fmt.Println("Failed to load config:", err)
+			fmt.Println("Run 'dotman config validate' or 'dotman config edit' to fix it")
+			os.Exit(1)
 		}
+	case cmd == initCmd || cmd.Parent() == configCmd || config.HasEnv():
+		cfg = config.New(filepath.Join(home, "dotfiles"))
+	default:
+		cfg = config.New(filepath.Join(home, "dotfiles"))
+		if err := createConfig(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		fmt.Println("Invalid environment:", err)
+		os.Exit(1)
+	}
+	baseCfg = cfg
+	scoped, err := baseCfg.ForRepo(repoFlag)
+	switch {
+	case err != nil && cmd == initCmd:
+		// init sets up a repository that is not configured yet
+		scoped = baseCfg.NewRepo(repoFlag)
+	case err != nil:
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-	var err error
-	cfg, err = config.LoadConf(configPath)
+	backend, err := git.New(cfg.GitBackend)
+	if err != nil {
+		fmt.Println("Invalid config:", err)
+		os.Exit(1)
+	}
+	manager.SetGitBackend(backend)
+	useRepo(scoped)
+	mode, perDir, err := cfg.ParseDirModes()
 	if err != nil {
-		fmt.Println("Failed to load config:", err)
+		fmt.Println("Invalid config:", err)
 		os.Exit(1)
 	}
+	manager.SetDirModes(mode, perDir)
+	manager.SetIgnorePatterns(cfg.Ignore)
+	applyLimits(cfg)
 }
 
-func init() {
-	cobra.OnInitialize(initConfig)
+// useRepo makes scoped the repository commands work on, the other repositories only guard their targets
+func useRepo(scoped *config.Config) {
+	cfg = scoped
+	manager.SetRemotes(cfg.PrimaryRemote(), cfg.MirrorRemotes())
+
+	others := baseCfg.InfoPaths()
+	delete(others, cfg.CurrentRepo())
+	manager.SetOtherRepos(others)
+
+	stack := []manager.Layer{}
+	for _, name := range baseCfg.Layers {
+		stack = append(stack, manager.Layer{Name: name, InfoPath: others[name]})
+	}
+	if i := slices.Index(baseCfg.Layers, cfg.CurrentRepo()); i >= 0 {
+		stack[i].InfoPath = cfg.InfoPath
+	}
+	manager.SetLayers(cfg.CurrentRepo(), stack)
+}
+
+// checkWritable fails for a read-only repository, its changes come from upstream
+func checkWritable() error {
+	if cfg.ReadOnly {
+		return fmt.Errorf("repository %v is read-only, make the change upstream or in a layer above it",
+			cfg.CurrentRepo())
+	}
+	return nil
+}
+
+// forEachRepo runs run on every configured repository in turn when all is set, otherwise on the one in use
+func forEachRepo(all bool, run func()) {
+	if !all {
+		run()
+		return
+	}
+	current := cfg
+	defer useRepo(current)
+	for _, name := range baseCfg.RepoNames() {
+		scoped, err := baseCfg.ForRepo(name)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		useRepo(scoped)
+		fmt.Printf("== %v (%v) ==\n", name, internal.ShrinkPath(cfg.FolderPath))
+		run()
+	}
+}
+
+// printTargetConflicts warns about targets more than one repository tracks and reports whether there were any
+func printTargetConflicts() bool {
+	if len(baseCfg.Repos) == 0 {
+		return false
+	}
+	conflicts, err := manager.TargetConflicts(baseCfg.InfoPaths())
+	if err != nil {
+		fmt.Printf("Could not compare repositories: %v\n", err)
+		return false
+	}
+	for _, conflict := range conflicts {
+		fmt.Printf("[warning] %v is tracked by more than one repository: %v\n", internal.ShrinkPath(conflict.Target),
+			strings.Join(conflict.Repos, ", "))
+	}
+	return len(conflicts) > 0
+}
+
+// printLayers shows the layer every target of the layers is deployed from
+func printLayers() {
+	if len(baseCfg.Layers) == 0 {
+		return
+	}
+	entries, err := manager.LayerEntries()
+	if err != nil {
+		fmt.Printf("Could not read the layers: %v\n", err)
+		return
+	}
+	fmt.Printf("\nLayers %v, later ones win:\n", strings.Join(baseCfg.Layers, " < "))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tLAYER\tSTATE")
+	for _, entry := range entries {
+		state := "linked"
+		if !entry.Linked {
+			state = "not linked"
+		}
+		if len(entry.Overrides) > 0 {
+			state += ", overrides " + strings.Join(entry.Overrides, ", ")
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", internal.ShrinkPath(entry.Target), entry.Layer, state)
+	}
+	_ = w.Flush()
+}
+
+// createConfig saves the default config after asking, there is nothing to work on without one
+func createConfig() error {
+	create, err := prompter.Confirm("config.create", "No config found, do you want to create one? (y/N)")
+	if err != nil {
+		return err
+	}
+	if !create {
+		return fmt.Errorf("no config at %v, run 'dotman init' to create one", configPath)
+	}
+	if err := config.SaveConf(configPath, cfg); err != nil {
+		return fmt.Errorf("could not create config: %w", err)
+	}
+	return nil
+}
+
+// applyLimits takes the command timeouts and retries from the config, keeping the defaults for unset values
+func applyLimits(cfg *config.Config) {
+	if cfg.CommandTimeout > 0 {
+		internal.CommandTimeout = cfg.CommandTimeout
+	}
+	if cfg.NetworkTimeout > 0 {
+		internal.NetworkTimeout = cfg.NetworkTimeout
+	}
+	if cfg.NetworkRetries != 0 {
+		internal.NetworkRetries = max(cfg.NetworkRetries, 0)
+	}
+	internal.NonInteractive = nonInteractive || !internal.IsTerminal(os.Stdin)
 }
 
 var rootCmd = &cobra.Command{
 	Use:   "dotman",
 	Short: "Dotman is a simple dotfiles manager",
 	Long:  "Manage your dotfiles with ease: add, remove, list, and sync dotfiles across machines.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		initConfig(cmd)
+	},
 }
 
 func Execute() {
+	// The first interrupt stops the running git command and lets dotman stop at a safe point,
+	// a second one kills it as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	internal.SetContext(ctx)
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -55,5 +282,15 @@ func Execute() {
 func init() {
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
+	rootCmd.PersistentFlags().StringVar(&configFlag, "config", "",
+		"Config file to use (default $XDG_CONFIG_HOME/dotman/config.yaml or ~/.dotconfig), $DOTMAN_CONFIG wins")
+	rootCmd.PersistentFlags().StringVar(&repoFlag, "repo", "",
+		"Repository to work on, one of the names under repos in the config (default the top-level one)")
 	rootCmd.PersistentFlags().BoolVarP(&internal.Verbose, "verbose", "v", false, "Show detailed output")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer yes to every yes/no question")
+	rootCmd.PersistentFlags().BoolVar(&assumeNo, "no", false, "Answer no to every yes/no question")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false,
+		"Never ask, fail when a question needs an answer")
+	rootCmd.PersistentFlags().StringVar(&answersPath, "answers", "",
+		"YAML file answering questions by key, e.g. init.git-init: yes")
 }
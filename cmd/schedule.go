@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ZonCen/dotman/internal/schedule"
+)
+
+var (
+	scheduleEvery   time.Duration
+	scheduleBackend string
+	scheduleAll     bool
+)
+
+// scheduleCmd represents the schedule command
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run sync and status on a timer with systemd or cron",
+}
+
+var scheduleInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install and enable a recurring sync followed by a status check",
+	Run: func(cmd *cobra.Command, args []string) {
+		scheduler, err := schedule.New(scheduleBackend)
+		if err != nil {
+			fmt.Printf("Error installing schedule: %v\n", err)
+			return
+		}
+		opts, err := schedule.DefaultOptions(scheduleEvery)
+		if err != nil {
+			fmt.Printf("Error installing schedule: %v\n", err)
+			return
+		}
+		// The timer reads the config this schedule was installed with and syncs the same repositories
+		if opts.Config, err = filepath.Abs(configPath); err != nil {
+			fmt.Printf("Error installing schedule: %v\n", err)
+			return
+		}
+		opts.Repo = repoFlag
+		opts.All = repoFlag == "" && (scheduleAll || len(baseCfg.Repos) > 0)
+		if err := scheduler.Install(opts); err != nil {
+			fmt.Printf("Error installing schedule: %v\n", err)
+			return
+		}
+		fmt.Printf("dotman will sync every %v, output is logged to %v\n", scheduleEvery, opts.LogPath)
+	},
+}
+
+var scheduleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether a schedule is installed and when it runs next",
+	Run: func(cmd *cobra.Command, args []string) {
+		scheduler, err := schedule.New(scheduleBackend)
+		if err != nil {
+			fmt.Printf("Error reading schedule: %v\n", err)
+			return
+		}
+		status, err := scheduler.Status()
+		if err != nil {
+			fmt.Printf("Error reading schedule: %v\n", err)
+			return
+		}
+		if !status.Installed {
+			fmt.Printf("No schedule installed (%v)\n", status.Backend)
+			return
+		}
+		fmt.Printf("Backend:  %v\n", status.Backend)
+		fmt.Printf("Every:    %v\n", status.Every)
+		if status.Active != "" {
+			fmt.Printf("State:    %v\n", status.Active)
+		}
+		if status.NextRun != "" {
+			fmt.Printf("Next run: %v\n", status.NextRun)
+		}
+		if status.LogPath != "" {
+			fmt.Printf("Log:      %v\n", status.LogPath)
+		}
+		for _, file := range status.Files {
+			fmt.Printf("Installed: %v\n", file)
+		}
+	},
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Disable and remove the schedule",
+	Run: func(cmd *cobra.Command, args []string) {
+		scheduler, err := schedule.New(scheduleBackend)
+		if err != nil {
+			fmt.Printf("Error removing schedule: %v\n", err)
+			return
+		}
+		if err := scheduler.Remove(); err != nil {
+			fmt.Printf("Error removing schedule: %v\n", err)
+			return
+		}
+		fmt.Println("Schedule removed")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleInstallCmd, scheduleStatusCmd, scheduleRemoveCmd)
+
+	scheduleCmd.PersistentFlags().StringVar(&scheduleBackend,
+		"backend",
+		"",
+		"Scheduler to use: systemd or cron (default systemd when available, otherwise cron)")
+	scheduleInstallCmd.Flags().DurationVar(&scheduleEvery,
+		"every",
+		time.Hour,
+		"How often to sync, for example 30m or 1h")
+	scheduleInstallCmd.Flags().BoolVar(&scheduleAll,
+		"all",
+		false,
+		"Sync every configured repository (the default when there is more than one, --repo picks a single one)")
+}
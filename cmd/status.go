@@ -5,7 +5,6 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/ZonCen/dotman/internal"
 	"github.com/ZonCen/dotman/internal/manager"
 )
 
@@ -14,19 +13,23 @@ var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show if the symlink file still exists",
 	Run: func(cmd *cobra.Command, args []string) {
-		filePath, err := internal.ResolvePath("~/dotfiles/info.json")
-		if err != nil {
-			fmt.Println("could not resolve path:", err)
-			return
-		}
-		err = manager.CheckStatus(filePath)
-		if err != nil {
-			fmt.Println("Could not run checkStatus:", err)
-			return
-		}
+		forEachRepo(allRepos, func() {
+			err := manager.CheckStatus(cfg.InfoPath)
+			if err != nil {
+				fmt.Println("Could not run checkStatus:", err)
+				return
+			}
+			if err := manager.PrintPending(cfg.FolderPath); err != nil {
+				fmt.Println("Could not check pending sync:", err)
+			}
+		})
+		printLayers()
+		printTargetConflicts()
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().BoolVar(&allRepos, "all", false, "Check every configured repository")
 }
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -10,9 +12,17 @@ import (
 )
 
 var (
-	dryRun   bool
-	download bool
-	upload   bool
+	dryRun       bool
+	download     bool
+	upload       bool
+	strategy     string
+	autostash    bool
+	syncContinue bool
+	syncAbort    bool
+	noDeploy     bool
+
+	// allRepos runs list, status and sync on every configured repository
+	allRepos bool
 )
 
 // syncCmd represents the sync command
@@ -20,30 +30,108 @@ var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync with your github repo",
 	Run: func(cmd *cobra.Command, args []string) {
-		folderPath := cfg.FolderPath
-		// Dry-run
-		if dryRun {
-			internal.LogVerbose("Will run in dry-run mode")
-			internal.Verbose = true
-			download = false
-			upload = false
-		}
-		// Download
-		if download && !upload {
-			internal.LogVerbose("Will only download files")
-		}
-		// Upload
-		if upload && !download {
-			internal.LogVerbose("Will only upload files")
+		forEachRepo(allRepos, func() { syncRepo(cmd) })
+	},
+}
+
+// syncRepo syncs the repository in use
+func syncRepo(cmd *cobra.Command) {
+	folderPath := cfg.FolderPath
+
+	if syncContinue && syncAbort {
+		fmt.Println("--continue and --abort can not be used together")
+		return
+	}
+	if syncAbort {
+		err := manager.AbortSync(folderPath)
+		if err != nil {
+			fmt.Printf("Error aborting sync: %v\n", err)
+			return
 		}
+		fmt.Println("Sync aborted")
+		return
+	}
+
+	// Dry-run
+	if dryRun {
+		internal.LogVerbose("Will run in dry-run mode")
+		download = false
+		upload = false
+	}
+	// Download
+	if download && !upload {
+		internal.LogVerbose("Will only download files")
+	}
+	// Upload
+	if upload && !download {
+		internal.LogVerbose("Will only upload files")
+	}
+
+	if err := ensureRemotes(folderPath); err != nil {
+		fmt.Printf("Error syncing with github: %v\n", err)
+		return
+	}
+
+	opts := manager.SyncOptions{
+		DryRun:    dryRun,
+		Download:  download,
+		Upload:    upload,
+		Strategy:  cfg.PullStrategy,
+		Autostash: cfg.Autostash,
+		NoDeploy:  noDeploy,
+		Branch:    cfg.Branch,
+		ReadOnly:  cfg.ReadOnly,
+		Prompter:  prompter,
+	}
+	if cmd.Flags().Changed("strategy") {
+		opts.Strategy = strategy
+	}
+	if cmd.Flags().Changed("autostash") {
+		opts.Autostash = autostash
+	}
 
-		err := manager.SyncRepo(folderPath, dryRun, download, upload)
+	var err error
+	if syncContinue {
+		err = manager.ContinueSync(folderPath, opts)
+	} else {
+		err = manager.SyncRepo(folderPath, opts)
+	}
+	var conflictErr *manager.ConflictError
+	if errors.As(err, &conflictErr) {
+		fmt.Printf("Sync stopped with conflicts in: %v\n", strings.Join(conflictErr.Entries, ", "))
+		resolve, err := prompter.Confirm("sync.resolve-now", "Do you want to resolve them now? (y/N)")
 		if err != nil {
 			fmt.Printf("Error syncing with github: %v\n", err)
 			return
 		}
-		fmt.Println("Sync completed")
-	},
+		if !resolve {
+			fmt.Println("Run 'dotman resolve' or 'dotman sync --continue' once resolved, or 'dotman sync --abort'")
+			return
+		}
+		err = manager.ResolveConflicts(folderPath, opts)
+	}
+	if errors.Is(err, manager.ErrPending) {
+		fmt.Printf("Sync pending: %v\n", err)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error syncing with github: %v\n", err)
+		return
+	}
+	fmt.Println("Sync completed")
+}
+
+// ensureRemotes adds the remotes from the config that the repository does not know yet
+func ensureRemotes(folderPath string) error {
+	for _, remote := range cfg.Remotes {
+		if remote.URL == "" {
+			continue
+		}
+		if err := manager.EnsureRemote(folderPath, remote.Name, remote.URL, false); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func init() {
@@ -61,4 +149,25 @@ func init() {
 		"upload",
 		true,
 		"Uploads local changes only")
+	syncCmd.Flags().StringVar(&strategy,
+		"strategy",
+		"ff-only",
+		"How to integrate remote changes: ff-only, rebase or merge (overrides pull_strategy in the config)")
+	syncCmd.Flags().BoolVar(&autostash,
+		"autostash",
+		false,
+		"Stash local changes before pulling and re-apply them afterwards")
+	syncCmd.Flags().BoolVar(&noDeploy,
+		"no-deploy",
+		false,
+		"Do not link, unlink or re-link entries that changed in the pulled info.json")
+	syncCmd.Flags().BoolVar(&allRepos, "all", false, "Sync every configured repository in turn")
+	syncCmd.Flags().BoolVar(&syncContinue,
+		"continue",
+		false,
+		"Finish a sync that stopped on conflicts once they are resolved")
+	syncCmd.Flags().BoolVar(&syncAbort,
+		"abort",
+		false,
+		"Back out of a sync that stopped on conflicts")
 }
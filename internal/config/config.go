@@ -3,27 +3,201 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/ZonCen/dotman/internal"
 )
 
+// Remote is a named git remote of the dotfiles repository
+type Remote struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url,omitempty"`
+	// Primary is the remote sync pulls from, the first remote when none is marked
+	Primary bool `yaml:"primary,omitempty"`
+	// Push mirrors every push to this remote as well, the primary is always pushed to
+	Push bool `yaml:"push,omitempty"`
+}
+
+// DefaultFolder is the repository used when repo_path is not set
+const DefaultFolder = "~/dotfiles"
+
+// InfoFile is the manifest of tracked entries at the root of the repository
+const InfoFile = "info.json"
+
 type Config struct {
-	FolderPath string `yaml:"repo_path"`
-	InfoPath   string `yaml:"info_path"`
+	FolderPath   string   `yaml:"repo_path,omitempty"`
+	InfoPath     string   `yaml:"info_path,omitempty"`
+	PullStrategy string   `yaml:"pull_strategy,omitempty"`
+	Autostash    bool     `yaml:"autostash,omitempty"`
+	Branch       string   `yaml:"branch,omitempty"`
+	GitBackend   string   `yaml:"git_backend,omitempty"`
+	Remotes      []Remote `yaml:"remotes,omitempty"`
+	// ReadOnly repositories are only pulled, nothing is committed or pushed to them
+	ReadOnly bool `yaml:"read_only,omitempty"`
+
+	// Timeouts for git, zero keeps the default. Negative retries disable retrying
+	CommandTimeout time.Duration `yaml:"command_timeout,omitempty"`
+	NetworkTimeout time.Duration `yaml:"network_timeout,omitempty"`
+	NetworkRetries int           `yaml:"network_retries,omitempty"`
+
+	// Octal modes of the parent directories created for links, DirModes sets them per directory and below
+	DirMode  string            `yaml:"dir_mode,omitempty"`
+	DirModes map[string]string `yaml:"dir_modes,omitempty"`
+
+	// Ignore adds patterns in gitignore syntax to the .dotmanignore of every repository, on this machine only
+	Ignore []string `yaml:"ignore,omitempty"`
+
+	// Repos are further repositories next to the one in repo_path, picked with --repo
+	Repos []Repo `yaml:"repos,omitempty"`
+	// Layers stacks repositories from the base to the top, a later layer wins a target path an earlier one tracks
+	Layers []string `yaml:"layers,omitempty"`
+	// RepoName is the repository this config was scoped to by ForRepo, empty for the default one
+	RepoName string `yaml:"-"`
+
+	// lines are the lines of the keys in the file the config was read from
+	lines map[string]int
 }
 
-func LoadConf(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+// New returns the config of a repository at folderPath
+func New(folderPath string) *Config {
+	cfg := &Config{}
+	cfg.SetFolder(folderPath)
+	return cfg
+}
+
+// SetFolder points the config at the repository in folderPath and its info.json
+func (c *Config) SetFolder(folderPath string) {
+	c.FolderPath = folderPath
+	c.InfoPath = filepath.Join(folderPath, InfoFile)
+}
+
+// resolvePaths expands ~ in the repository paths, an unset repo_path is ~/dotfiles and an unset info_path the
+// info.json of the repository
+func (c *Config) resolvePaths() error {
+	if c.FolderPath == "" {
+		c.FolderPath = DefaultFolder
+	}
+	folderPath, infoPath, err := resolveRepo(c.FolderPath, c.InfoPath)
+	if err != nil {
+		return err
+	}
+	c.FolderPath, c.InfoPath = folderPath, infoPath
+	for i, repo := range c.Repos {
+		if repo.FolderPath == "" {
+			continue
+		}
+		folderPath, infoPath, err := resolveRepo(repo.FolderPath, repo.InfoPath)
+		if err != nil {
+			return fmt.Errorf("repos %v: %w", repo.Name, err)
+		}
+		c.Repos[i].FolderPath, c.Repos[i].InfoPath = folderPath, infoPath
+	}
+	return nil
+}
+
+func resolveRepo(folderPath, infoPath string) (string, string, error) {
+	resolved, err := internal.ResolvePath(folderPath)
+	if err != nil {
+		return "", "", fmt.Errorf("repo_path: %w", err)
+	}
+	if infoPath == "" {
+		return resolved, filepath.Join(resolved, InfoFile), nil
+	}
+	resolvedInfo, err := internal.ResolvePath(infoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config: %w", err)
+		return "", "", fmt.Errorf("info_path: %w", err)
 	}
+	return resolved, resolvedInfo, nil
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse the config %w", err)
+// ParseDirModes returns dir_mode, zero when unset, and dir_modes keyed by resolved directory
+func (c *Config) ParseDirModes() (os.FileMode, map[string]os.FileMode, error) {
+	var mode os.FileMode
+	if c.DirMode != "" {
+		parsed, err := parseMode(c.DirMode)
+		if err != nil {
+			return 0, nil, fmt.Errorf("dir_mode: %w", err)
+		}
+		mode = parsed
 	}
 
-	return &cfg, nil
+	perDir := make(map[string]os.FileMode, len(c.DirModes))
+	for dir, value := range c.DirModes {
+		parsed, err := parseMode(value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("dir_modes %v: %w", dir, err)
+		}
+		resolved, err := internal.ResolvePath(dir)
+		if err != nil {
+			return 0, nil, fmt.Errorf("dir_modes %v: %w", dir, err)
+		}
+		perDir[resolved] = parsed
+	}
+	return mode, perDir, nil
+}
+
+func parseMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q, use an octal mode such as 0755", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// PrimaryRemote returns the name of the remote sync pulls from, empty when no remotes are configured
+func (c *Config) PrimaryRemote() string {
+	for _, remote := range c.Remotes {
+		if remote.Primary {
+			return remote.Name
+		}
+	}
+	if len(c.Remotes) > 0 {
+		return c.Remotes[0].Name
+	}
+	return ""
+}
+
+// MirrorRemotes returns the names of the remotes that receive every push besides the primary
+func (c *Config) MirrorRemotes() []string {
+	primary := c.PrimaryRemote()
+	mirrors := []string{}
+	for _, remote := range c.Remotes {
+		if remote.Push && remote.Name != primary {
+			mirrors = append(mirrors, remote.Name)
+		}
+	}
+	return mirrors
+}
+
+// SetRemote adds a remote or updates the one with the same name
+func (c *Config) SetRemote(remote Remote) {
+	c.Remotes = setRemote(c.Remotes, remote)
+}
+
+func setRemote(remotes []Remote, remote Remote) []Remote {
+	for i, existing := range remotes {
+		if existing.Name == remote.Name {
+			remotes[i] = remote
+			return remotes
+		}
+	}
+	return append(remotes, remote)
+}
+
+func LoadConf(path string) (*Config, error) {
+	cfg, err := readConf(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.resolvePaths(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
 }
 
 func SaveConf(path string, cfg *Config) error {
@@ -32,7 +206,10 @@ func SaveConf(path string, cfg *Config) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := internal.WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
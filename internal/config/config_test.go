@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/ZonCen/dotman/internal/testutils"
@@ -50,6 +51,42 @@ invalid_yaml: [unclosed
 	}
 }
 
+func TestLoadConfResolvesPaths(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("HOME", testDir)
+
+	configPath := filepath.Join(testDir, "config.yaml")
+	tests := []struct {
+		name     string
+		content  string
+		wantRepo string
+		wantInfo string
+	}{
+		{"tilde", "repo_path: ~/src/dotfiles\ninfo_path: ~/src/dotfiles/info.json\n",
+			filepath.Join(testDir, "src", "dotfiles"), filepath.Join(testDir, "src", "dotfiles", "info.json")},
+		{"info path defaults to the repository", "repo_path: /srv/dotfiles\n",
+			"/srv/dotfiles", "/srv/dotfiles/info.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutils.CreateTestFile(t, configPath, tt.content)
+			cfg, err := LoadConf(configPath)
+			if err != nil {
+				t.Fatalf("LoadConf() error = %v", err)
+			}
+			if cfg.FolderPath != tt.wantRepo || cfg.InfoPath != tt.wantInfo {
+				t.Errorf("LoadConf() = %v, %v, want %v, %v", cfg.FolderPath, cfg.InfoPath, tt.wantRepo, tt.wantInfo)
+			}
+		})
+	}
+
+	if cfg := New("/srv/dotfiles"); cfg.InfoPath != "/srv/dotfiles/info.json" {
+		t.Errorf("New() InfoPath = %v, want /srv/dotfiles/info.json", cfg.InfoPath)
+	}
+}
+
 func TestSaveConf(t *testing.T) {
 	testDir := testutils.TestDir(t)
 	defer testutils.CleanupTestDir(t, testDir)
@@ -113,3 +150,77 @@ func TestConfigRoundTrip(t *testing.T) {
 		t.Errorf("InfoPath mismatch: got %v, want %v", loadedCfg.InfoPath, originalCfg.InfoPath)
 	}
 }
+
+func TestRemotes(t *testing.T) {
+	tests := []struct {
+		name        string
+		remotes     []Remote
+		wantPrimary string
+		wantMirrors string
+	}{
+		{"none configured", nil, "", ""},
+		{"first remote is primary", []Remote{{Name: "work"}, {Name: "backup", Push: true}}, "work", "backup"},
+		{"marked primary", []Remote{{Name: "backup", Push: true}, {Name: "work", Primary: true, Push: true}},
+			"work", "backup"},
+		{"fetch only remote is not pushed", []Remote{{Name: "origin"}, {Name: "upstream"}}, "origin", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Remotes: tt.remotes}
+			if got := cfg.PrimaryRemote(); got != tt.wantPrimary {
+				t.Errorf("PrimaryRemote() = %v, want %v", got, tt.wantPrimary)
+			}
+			if got := strings.Join(cfg.MirrorRemotes(), ","); got != tt.wantMirrors {
+				t.Errorf("MirrorRemotes() = %v, want %v", got, tt.wantMirrors)
+			}
+		})
+	}
+
+	cfg := &Config{Remotes: []Remote{{Name: "origin", URL: "old"}}}
+	cfg.SetRemote(Remote{Name: "origin", URL: "new", Primary: true})
+	cfg.SetRemote(Remote{Name: "backup", URL: "/srv/backup.git", Push: true})
+	if len(cfg.Remotes) != 2 || cfg.Remotes[0].URL != "new" || cfg.Remotes[1].Name != "backup" {
+		t.Errorf("SetRemote() = %+v, want origin updated and backup added", cfg.Remotes)
+	}
+}
+
+func TestParseDirModes(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	tests := []struct {
+		name     string
+		cfg      Config
+		wantMode os.FileMode
+		wantDirs map[string]os.FileMode
+		wantErr  bool
+	}{
+		{"unset", Config{}, 0, map[string]os.FileMode{}, false},
+		{"default and per directory", Config{DirMode: "0750", DirModes: map[string]string{"~/.ssh": "700"}},
+			0750, map[string]os.FileMode{filepath.Join(home, ".ssh"): 0700}, false},
+		{"not octal", Config{DirMode: "rwx"}, 0, nil, true},
+		{"too large", Config{DirModes: map[string]string{"~/.gnupg": "01777"}}, 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, perDir, err := tt.cfg.ParseDirModes()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDirModes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if mode != tt.wantMode {
+				t.Errorf("ParseDirModes() mode = %v, want %v", mode, tt.wantMode)
+			}
+			if len(perDir) != len(tt.wantDirs) {
+				t.Fatalf("ParseDirModes() dirs = %v, want %v", perDir, tt.wantDirs)
+			}
+			for dir, want := range tt.wantDirs {
+				if perDir[dir] != want {
+					t.Errorf("ParseDirModes() dirs[%v] = %v, want %v", dir, perDir[dir], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// EnvConfig names the config file to use, ahead of --config
+const EnvConfig = "DOTMAN_CONFIG"
+
+// envPrefix prefixes the environment variables overriding single settings, DOTMAN_REPO_PATH sets repo_path
+const envPrefix = "DOTMAN_"
+
+// LegacyPath is where the config lived before dotman followed the XDG base directories
+func LegacyPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".dotconfig")
+}
+
+// XDGPath is $XDG_CONFIG_HOME/dotman/config.yaml, ~/.config/dotman/config.yaml when it is unset
+func XDGPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, _ := os.UserHomeDir()
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "dotman", "config.yaml")
+}
+
+// Locate returns the config file to use: $DOTMAN_CONFIG, then flag, then the XDG path and the legacy
+// ~/.dotconfig, whichever exists. A new config goes to the XDG path
+func Locate(flag string) (string, error) {
+	for _, explicit := range []string{os.Getenv(EnvConfig), flag} {
+		if explicit != "" {
+			path, err := internal.ResolvePath(explicit)
+			if err != nil {
+				return "", fmt.Errorf("could not resolve %v: %w", explicit, err)
+			}
+			return path, nil
+		}
+	}
+	for _, path := range []string{XDGPath(), LegacyPath()} {
+		if internal.FileExist(path) {
+			return path, nil
+		}
+	}
+	return XDGPath(), nil
+}
+
+// EnvName is the environment variable overriding a setting, empty for settings that can not be overridden
+func EnvName(key string) string {
+	for _, field := range envFields() {
+		if field.key == key {
+			return field.env
+		}
+	}
+	return ""
+}
+
+// Source tells where the value of key comes from: the environment variable overriding it, "config" for the
+// config file, or "default"
+func (c *Config) Source(key string) string {
+	if env := EnvName(key); env != "" {
+		if _, ok := os.LookupEnv(env); ok {
+			return env
+		}
+	}
+	if _, ok := c.lines[key]; ok {
+		return "config"
+	}
+	return "default"
+}
+
+// HasEnv reports whether the environment sets the repository, dotman then runs without a config file
+func HasEnv() bool {
+	_, ok := os.LookupEnv(EnvName("repo_path"))
+	return ok
+}
+
+type envField struct {
+	key   string
+	env   string
+	index int
+}
+
+// envFields lists the settings with a single value, lists and maps such as remotes only come from the file
+func envFields() []envField {
+	fields := []envField{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		switch t.Field(i).Type.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64:
+		default:
+			continue
+		}
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		fields = append(fields, envField{key: key, env: envPrefix + strings.ToUpper(key), index: i})
+	}
+	return fields
+}
+
+// ApplyEnv overrides settings with the DOTMAN_* variables that are set. Overriding repo_path alone moves
+// info_path along with it
+func (c *Config) ApplyEnv() error {
+	infoOverridden := false
+	repoOverridden := false
+	v := reflect.ValueOf(c).Elem()
+	for _, field := range envFields() {
+		value, ok := os.LookupEnv(field.env)
+		if !ok {
+			continue
+		}
+		if err := setValue(v.Field(field.index), value); err != nil {
+			return fmt.Errorf("%v: %w", field.env, err)
+		}
+		infoOverridden = infoOverridden || field.key == "info_path"
+		repoOverridden = repoOverridden || field.key == "repo_path"
+	}
+	if repoOverridden && !infoOverridden {
+		c.InfoPath = ""
+	}
+	if problems := c.checkValues(); len(problems) > 0 {
+		return fmt.Errorf("%v: %v", EnvName(problems[0].Key), problems[0].Message)
+	}
+	return c.resolvePaths()
+}
+
+// setValue parses value into a string, bool, int or duration setting
+func setValue(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == reflect.TypeOf(time.Duration(0)):
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(parsed))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(parsed)
+	case field.Kind() == reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetInt(int64(parsed))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestLocate(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("HOME", testDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(testDir, "xdg"))
+	t.Setenv(EnvConfig, "")
+
+	xdg := filepath.Join(testDir, "xdg", "dotman", "config.yaml")
+	legacy := filepath.Join(testDir, ".dotconfig")
+	locate := func(flag, want string) {
+		t.Helper()
+		if got, err := Locate(flag); err != nil || got != want {
+			t.Errorf("Locate(%q) = %v, %v, want %v", flag, got, err, want)
+		}
+	}
+
+	// Nothing exists yet, a new config goes to the XDG path
+	locate("", xdg)
+	testutils.CreateTestFile(t, legacy, "")
+	locate("", legacy)
+	testutils.CreateTestFile(t, xdg, "")
+	locate("", xdg)
+	locate("/etc/dotman.yaml", "/etc/dotman.yaml")
+	t.Setenv(EnvConfig, "/srv/dotman.yaml")
+	locate("/etc/dotman.yaml", "/srv/dotman.yaml")
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "nothing set keeps the file",
+			want: Config{FolderPath: "/home/me/dotfiles", InfoPath: "/home/me/dotfiles/info.json", Branch: "main"},
+		},
+		{
+			name: "repository moves info.json along",
+			env:  map[string]string{"DOTMAN_REPO_PATH": "/tmp/other"},
+			want: Config{FolderPath: "/tmp/other", InfoPath: "/tmp/other/info.json", Branch: "main"},
+		},
+		{
+			name: "typed settings",
+			env: map[string]string{"DOTMAN_BRANCH": "laptop", "DOTMAN_AUTOSTASH": "true",
+				"DOTMAN_NETWORK_TIMEOUT": "30s", "DOTMAN_NETWORK_RETRIES": "-1"},
+			want: Config{FolderPath: "/home/me/dotfiles", InfoPath: "/home/me/dotfiles/info.json", Branch: "laptop",
+				Autostash: true, NetworkTimeout: 30 * time.Second, NetworkRetries: -1},
+		},
+		{
+			name:    "invalid duration",
+			env:     map[string]string{"DOTMAN_COMMAND_TIMEOUT": "soon"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg := New("/home/me/dotfiles")
+			cfg.Branch = "main"
+			err := cfg.ApplyEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (cfg.FolderPath != tt.want.FolderPath || cfg.InfoPath != tt.want.InfoPath ||
+				cfg.Branch != tt.want.Branch || cfg.Autostash != tt.want.Autostash ||
+				cfg.NetworkTimeout != tt.want.NetworkTimeout || cfg.NetworkRetries != tt.want.NetworkRetries) {
+				t.Errorf("ApplyEnv() = %+v, want %+v", *cfg, tt.want)
+			}
+		})
+	}
+
+	if got := EnvName("dir_mode"); got != "DOTMAN_DIR_MODE" {
+		t.Errorf("EnvName(dir_mode) = %v, want DOTMAN_DIR_MODE", got)
+	}
+	if got := EnvName("remotes"); got != "" {
+		t.Errorf("EnvName(remotes) = %v, want none", got)
+	}
+}
+
+func TestUpdateKeepsFileAsWritten(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	configPath := filepath.Join(testDir, "dotman", "config.yaml")
+	if err := Update(configPath, func(c *Config) { c.FolderPath = "~/dotfiles" }); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := Update(configPath, func(c *Config) { c.Branch = "laptop" }); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	testutils.AssertFileContent(t, configPath, "repo_path: ~/dotfiles\nbranch: laptop\n")
+}
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// readConf parses the config file as written, without resolving paths. Unknown keys, values of the wrong type
+// and values out of range are reported together as a ValidationError
+func readConf(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	cfg, _, problems, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the config %w", err)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Path: path, Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+// parse decodes the config key by key, so every problem can be reported with its key and line. It returns the
+// document the config was read from, for writing changes back
+func parse(data []byte) (*Config, *yaml.Node, []Problem, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, nil, fmt.Errorf("line %d: the config must map keys to values", root.Line)
+	}
+
+	cfg := &Config{lines: map[string]int{}}
+	problems := []Problem{}
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if _, seen := cfg.lines[key.Value]; seen {
+			problems = append(problems, Problem{Key: key.Value, Line: key.Line, Message: "is set twice"})
+			continue
+		}
+		cfg.lines[key.Value] = key.Line
+		index, ok := fieldIndex(key.Value)
+		if !ok {
+			problems = append(problems, Problem{Key: key.Value, Line: key.Line, Message: unknownKey(key.Value)})
+			continue
+		}
+		if err := value.Decode(v.Field(index).Addr().Interface()); err != nil {
+			problems = append(problems, Problem{Key: key.Value, Line: value.Line, Message: typeMessage(err)})
+		}
+	}
+	return cfg, doc, append(problems, cfg.checkValues()...), nil
+}
+
+// typeMessage drops the line yaml puts in front of a type error, the problem carries it already
+func typeMessage(err error) string {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) || len(typeErr.Errors) == 0 {
+		return err.Error()
+	}
+	message := typeErr.Errors[0]
+	if strings.HasPrefix(message, "line ") {
+		if _, rest, ok := strings.Cut(message, ": "); ok {
+			message = rest
+		}
+	}
+	return message
+}
+
+// Validate checks the config file at path: the problems LoadConf reports, and whether the repository and its
+// info.json exist where the file says
+func Validate(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	cfg, _, problems, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse the config %w", err)
+	}
+	if err := cfg.resolvePaths(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	problems = append(problems, cfg.checkPaths()...)
+	if len(problems) > 0 {
+		return &ValidationError{Path: path, Problems: problems}
+	}
+	return nil
+}
+
+// Get returns the value of key as written in a config file, dir_modes.<dir> reads one directory. set is false
+// when the key is unset and the default applies
+func (c *Config) Get(key string) (value string, set bool, err error) {
+	if name, dir, ok := strings.Cut(key, "."); ok && name == "dir_modes" {
+		mode, set := c.DirModes[dir]
+		return mode, set, nil
+	}
+	index, ok := fieldIndex(key)
+	if !ok {
+		return "", false, fmt.Errorf("%v: %v", key, unknownKey(key))
+	}
+	field := reflect.ValueOf(c).Elem().Field(index)
+	if field.IsZero() {
+		return "", false, nil
+	}
+	switch field.Kind() {
+	case reflect.Slice, reflect.Map:
+		data, err := yaml.Marshal(field.Interface())
+		if err != nil {
+			return "", false, fmt.Errorf("could not show %v: %w", key, err)
+		}
+		return strings.TrimSuffix(string(data), "\n"), true, nil
+	}
+	if duration, ok := field.Interface().(time.Duration); ok {
+		return duration.String(), true, nil
+	}
+	return fmt.Sprint(field.Interface()), true, nil
+}
+
+// Set changes key in the config file at path, creating it when missing. The value is checked like the file is
+// when it is loaded, dir_modes.<dir> sets the mode of one directory
+func Set(path, key, value string) error {
+	return update(path, func(c *Config) error {
+		if name, dir, ok := strings.Cut(key, "."); ok && name == "dir_modes" {
+			if c.DirModes == nil {
+				c.DirModes = map[string]string{}
+			}
+			c.DirModes[dir] = value
+		} else {
+			index, ok := fieldIndex(key)
+			if !ok {
+				return fmt.Errorf("%v: %v", key, unknownKey(key))
+			}
+			field := reflect.ValueOf(c).Elem().Field(index)
+			switch field.Kind() {
+			case reflect.Slice, reflect.Map:
+				return fmt.Errorf("%v holds more than one value, change it with 'dotman config edit'", key)
+			}
+			if err := setValue(field, value); err != nil {
+				return fmt.Errorf("%v: %w", key, err)
+			}
+		}
+
+		name, _, _ := strings.Cut(key, ".")
+		for _, problem := range c.checkValues() {
+			if problem.Key == name {
+				return fmt.Errorf("%v: %v", problem.Key, problem.Message)
+			}
+		}
+		return nil
+	})
+}
+
+// Unset removes key from the config file at path so its default applies again
+func Unset(path, key string) error {
+	return update(path, func(c *Config) error {
+		if name, dir, ok := strings.Cut(key, "."); ok && name == "dir_modes" {
+			delete(c.DirModes, dir)
+			return nil
+		}
+		index, ok := fieldIndex(key)
+		if !ok {
+			return fmt.Errorf("%v: %v", key, unknownKey(key))
+		}
+		field := reflect.ValueOf(c).Elem().Field(index)
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	})
+}
+
+// Update applies change to the config file at path, creating it when missing. Only the file is changed, so
+// environment overrides of the running config are never written to it
+func Update(path string, change func(*Config)) error {
+	return update(path, func(c *Config) error {
+		change(c)
+		return nil
+	})
+}
+
+// update applies change to the config file at path and writes back only the keys it changed, so comments,
+// the order of the keys and keys dotman does not know survive
+func update(path string, change func(*Config) error) error {
+	data := []byte{}
+	if internal.FileExist(path) {
+		read, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
+		data = read
+	}
+	// Problems in other keys must not stop fixing a key, they are left as they are
+	cfg, doc, _, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse the config %w", err)
+	}
+
+	before, err := encodeKeys(cfg)
+	if err != nil {
+		return err
+	}
+	if err := change(cfg); err != nil {
+		return err
+	}
+	after, err := encodeKeys(cfg)
+	if err != nil {
+		return err
+	}
+	for _, setting := range Schema {
+		previous, changed := before[setting.Key], after[setting.Key]
+		if previous == nil && changed == nil || previous != nil && changed != nil && sameNode(previous, changed) {
+			continue
+		}
+		setKey(doc.Content[0], setting.Key, changed)
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := internal.WriteFileAtomic(path, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// encodeKeys encodes cfg and returns the value node of every key that is set
+func encodeKeys(cfg *Config) (map[string]*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	keys := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keys[node.Content[i].Value] = node.Content[i+1]
+	}
+	return keys, nil
+}
+
+func sameNode(a, b *yaml.Node) bool {
+	encodedA, errA := yaml.Marshal(a)
+	encodedB, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// setKey replaces the value of key in the mapping root, adds the key when missing and removes it for a nil value
+func setKey(root *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != key {
+			continue
+		}
+		if value == nil {
+			root.Content = slices.Delete(root.Content, i, i+2)
+			return
+		}
+		if value.LineComment == "" {
+			value.LineComment = root.Content[i+1].LineComment
+		}
+		root.Content[i+1] = value
+		return
+	}
+	if value != nil {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+}
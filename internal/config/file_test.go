@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestLoadConfReportsProblems(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	configPath := filepath.Join(testDir, "config.yaml")
+	testutils.CreateTestFile(t, configPath, `repo_path: /home/user/dotfiles
+pull_stratgy: rebase
+git_backend: libgit2
+network_retries: lots
+dir_modes:
+  ~/.ssh: "0800"
+`)
+
+	_, err := LoadConf(configPath)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("LoadConf() error = %v, want a ValidationError", err)
+	}
+	want := []string{
+		"line 2: pull_stratgy: unknown key, did you mean pull_strategy?",
+		"line 4: network_retries: cannot unmarshal !!str `lots` into int",
+		`line 3: git_backend: "libgit2" is not one of exec, go-git`,
+		`line 5: dir_modes: ~/.ssh: invalid mode "0800", use an octal mode such as 0755`,
+	}
+	if len(validationErr.Problems) != len(want) {
+		t.Fatalf("LoadConf() problems = %v, want %v", validationErr.Problems, want)
+	}
+	for i, problem := range validationErr.Problems {
+		if problem.Error() != want[i] {
+			t.Errorf("problem %d = %v, want %v", i, problem.Error(), want[i])
+		}
+	}
+}
+
+func TestSetAndUnsetKeepTheFile(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	configPath := filepath.Join(testDir, "config.yaml")
+	testutils.CreateTestFile(t, configPath, `# my dotfiles
+repo_path: ~/dotfiles # synced from work
+branch: laptop
+`)
+
+	if err := Set(configPath, "network_timeout", "30s"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := Set(configPath, "dir_modes.~/.ssh", "0700"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := Unset(configPath, "branch"); err != nil {
+		t.Fatalf("Unset() error = %v", err)
+	}
+	testutils.AssertFileContent(t, configPath, `# my dotfiles
+repo_path: ~/dotfiles # synced from work
+network_timeout: 30s
+dir_modes:
+  ~/.ssh: "0700"
+`)
+
+	for key, value := range map[string]string{"git_backend": "libgit2", "network_retries": "lots",
+		"remotes": "origin", "pull_stratgy": "rebase", "dir_mode": "999"} {
+		if err := Set(configPath, key, value); err == nil {
+			t.Errorf("Set(%v, %v) succeeded, want an error", key, value)
+		}
+	}
+
+	cfg, err := LoadConf(configPath)
+	if err != nil {
+		t.Fatalf("LoadConf() error = %v", err)
+	}
+	for key, want := range map[string]string{"network_timeout": "30s", "dir_modes.~/.ssh": "0700", "branch": ""} {
+		if got, _, err := cfg.Get(key); err != nil || got != want {
+			t.Errorf("Get(%v) = %v, %v, want %v", key, got, err, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	repo := filepath.Join(testDir, "dotfiles")
+	configPath := filepath.Join(testDir, "config.yaml")
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"missing repository", "repo_path: " + repo + "\n", "line 1: repo_path: " + repo + " does not exist"},
+		{"info.json outside the repository", "repo_path: " + repo + "\ninfo_path: " + testDir + "/info.json\n",
+			"line 2: info_path: " + testDir + "/info.json is not inside repo_path"},
+		{"valid", "repo_path: " + repo + "\n", ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if i > 0 {
+				testutils.CreateTestFile(t, filepath.Join(repo, "info.json"), "{}")
+			}
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := Validate(configPath)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
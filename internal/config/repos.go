@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// DefaultRepo names the repository kept in the top-level repo_path, info_path, branch and remotes
+const DefaultRepo = "default"
+
+// Repo is a further dotfiles repository next to the default one, for example one for work. Every other
+// setting is shared by all repositories
+type Repo struct {
+	Name       string   `yaml:"name"`
+	FolderPath string   `yaml:"repo_path"`
+	InfoPath   string   `yaml:"info_path,omitempty"`
+	Branch     string   `yaml:"branch,omitempty"`
+	Remotes    []Remote `yaml:"remotes,omitempty"`
+	ReadOnly   bool     `yaml:"read_only,omitempty"`
+}
+
+// SetFolder points the repository at folderPath and its info.json
+func (r *Repo) SetFolder(folderPath string) {
+	r.FolderPath = folderPath
+	r.InfoPath = filepath.Join(folderPath, InfoFile)
+}
+
+// SetRemote adds a remote or updates the one with the same name
+func (r *Repo) SetRemote(remote Remote) {
+	r.Remotes = setRemote(r.Remotes, remote)
+}
+
+// CurrentRepo names the repository this config is scoped to
+func (c *Config) CurrentRepo() string {
+	if c.RepoName == "" {
+		return DefaultRepo
+	}
+	return c.RepoName
+}
+
+// RepoNames lists the default repository and then the others in the order they are configured
+func (c *Config) RepoNames() []string {
+	names := []string{DefaultRepo}
+	for _, repo := range c.Repos {
+		names = append(names, repo.Name)
+	}
+	return names
+}
+
+// ForRepo returns the config of one repository: a copy with its repo_path, info_path, branch and remotes in the
+// top-level keys every command reads. An empty name is the repository in use
+func (c *Config) ForRepo(name string) (*Config, error) {
+	if name == "" || name == c.RepoName {
+		return c, nil
+	}
+	repo, ok := c.lookupRepo(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown repository %q, configured are %v", name, c.RepoNames())
+	}
+	scoped := *c
+	scoped.RepoName = repo.Name
+	scoped.setDefaultRepo(repo)
+	return &scoped, nil
+}
+
+// NewRepo returns the config of a repository that is not configured yet, for init to set up
+func (c *Config) NewRepo(name string) *Config {
+	scoped := *c
+	scoped.RepoName = name
+	scoped.setDefaultRepo(Repo{Name: name})
+	return &scoped
+}
+
+// UpdateRepo applies change to the repository name, adding it when it is not configured. On a config from
+// ForRepo the top-level keys are the repository in use
+func (c *Config) UpdateRepo(name string, change func(*Repo)) {
+	if name == "" || name == DefaultRepo || name == c.RepoName {
+		repo := c.defaultRepo()
+		change(&repo)
+		c.setDefaultRepo(repo)
+		return
+	}
+	for i := range c.Repos {
+		if c.Repos[i].Name == name {
+			change(&c.Repos[i])
+			return
+		}
+	}
+	repo := Repo{Name: name}
+	change(&repo)
+	c.Repos = append(c.Repos, repo)
+}
+
+// InfoPaths maps every repository to its info.json
+func (c *Config) InfoPaths() map[string]string {
+	paths := map[string]string{}
+	for _, name := range c.RepoNames() {
+		if scoped, err := c.ForRepo(name); err == nil {
+			paths[name] = scoped.InfoPath
+		}
+	}
+	return paths
+}
+
+func (c *Config) lookupRepo(name string) (Repo, bool) {
+	if name == DefaultRepo {
+		repo := c.defaultRepo()
+		repo.Name = DefaultRepo
+		return repo, true
+	}
+	index := slices.IndexFunc(c.Repos, func(repo Repo) bool { return repo.Name == name })
+	if index < 0 {
+		return Repo{}, false
+	}
+	return c.Repos[index], true
+}
+
+// defaultRepo returns the repository kept in the top-level keys
+func (c *Config) defaultRepo() Repo {
+	return Repo{Name: c.RepoName, FolderPath: c.FolderPath, InfoPath: c.InfoPath, Branch: c.Branch,
+		Remotes: c.Remotes, ReadOnly: c.ReadOnly}
+}
+
+// setDefaultRepo puts repo in the top-level keys
+func (c *Config) setDefaultRepo(repo Repo) {
+	c.FolderPath, c.InfoPath, c.Branch, c.Remotes, c.ReadOnly =
+		repo.FolderPath, repo.InfoPath, repo.Branch, repo.Remotes, repo.ReadOnly
+}
+
+// checkRepos checks that every repository has a unique name and a folder of its own, and that the layers are
+// configured repositories
+func (c *Config) checkRepos() []Problem {
+	problems := []Problem{}
+	add := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Line: c.lines[key], Message: fmt.Sprintf(format, args...)})
+	}
+
+	names := map[string]bool{DefaultRepo: true}
+	for _, repo := range c.Repos {
+		switch {
+		case repo.Name == "":
+			add("repos", "every repository needs a name")
+		case repo.Name == DefaultRepo:
+			add("repos", "%v names the top-level repository, pick another name", DefaultRepo)
+		case names[repo.Name]:
+			add("repos", "%v is listed twice", repo.Name)
+		}
+		names[repo.Name] = true
+		if repo.FolderPath == "" {
+			add("repos", "%v has no repo_path", repo.Name)
+		}
+	}
+
+	layers := map[string]bool{}
+	for _, layer := range c.Layers {
+		switch {
+		case !names[layer]:
+			add("layers", "unknown repository %v, configured are %v", layer, c.RepoNames())
+		case layers[layer]:
+			add("layers", "%v is listed twice", layer)
+		}
+		layers[layer] = true
+	}
+	return problems
+}
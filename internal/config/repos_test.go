@@ -0,0 +1,106 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestRepos(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	configPath := filepath.Join(testDir, "config.yaml")
+	testutils.CreateTestFile(t, configPath, `repo_path: /home/me/dotfiles
+branch: main
+network_retries: 5
+repos:
+  - name: work
+    repo_path: /home/me/work-dotfiles
+    remotes:
+      - name: origin
+        url: git@git.corp.example:me/dotfiles.git
+`)
+	cfg, err := LoadConf(configPath)
+	if err != nil {
+		t.Fatalf("LoadConf() error = %v", err)
+	}
+	if got := strings.Join(cfg.RepoNames(), ","); got != "default,work" {
+		t.Errorf("RepoNames() = %v, want default,work", got)
+	}
+
+	work, err := cfg.ForRepo("work")
+	if err != nil {
+		t.Fatalf("ForRepo(work) error = %v", err)
+	}
+	if work.FolderPath != "/home/me/work-dotfiles" || work.InfoPath != "/home/me/work-dotfiles/info.json" ||
+		work.Branch != "" || work.PrimaryRemote() != "origin" || work.NetworkRetries != 5 {
+		t.Errorf("ForRepo(work) = %+v, want the work repository with the shared settings", work)
+	}
+	if _, err := cfg.ForRepo("home"); err == nil {
+		t.Error("ForRepo(home) succeeded, want an unknown repository")
+	}
+	if paths := cfg.InfoPaths(); paths["default"] != "/home/me/dotfiles/info.json" ||
+		paths["work"] != "/home/me/work-dotfiles/info.json" {
+		t.Errorf("InfoPaths() = %v", paths)
+	}
+
+	// Changes to a scoped repository go to its entry, the top-level keys stay the default repository
+	err = Update(configPath, func(c *Config) { c.UpdateRepo("work", func(r *Repo) { r.Branch = "laptop" }) })
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	err = Update(configPath, func(c *Config) {
+		c.UpdateRepo("tools", func(r *Repo) { r.SetFolder("/home/me/tools") })
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	cfg, err = LoadConf(configPath)
+	if err != nil {
+		t.Fatalf("LoadConf() error = %v", err)
+	}
+	if work, _ := cfg.ForRepo("work"); work.Branch != "laptop" || cfg.Branch != "main" {
+		t.Errorf("branches = %v, %v, want laptop for work and main for default", work.Branch, cfg.Branch)
+	}
+	if tools, err := cfg.ForRepo("tools"); err != nil || tools.FolderPath != "/home/me/tools" {
+		t.Errorf("ForRepo(tools) = %+v, %v, want the added repository", tools, err)
+	}
+
+	// A read-only repository stays read-only when it is scoped, the default one is writable
+	err = Update(configPath, func(c *Config) { c.UpdateRepo("work", func(r *Repo) { r.ReadOnly = true }) })
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	cfg, err = LoadConf(configPath)
+	if err != nil {
+		t.Fatalf("LoadConf() error = %v", err)
+	}
+	if work, _ := cfg.ForRepo("work"); !work.ReadOnly || cfg.ReadOnly {
+		t.Errorf("read_only = %v, %v, want work read-only and default writable", work.ReadOnly, cfg.ReadOnly)
+	}
+}
+
+func TestReposProblems(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	configPath := filepath.Join(testDir, "config.yaml")
+	testutils.CreateTestFile(t, configPath, `repo_path: /home/me/dotfiles
+repos:
+  - name: default
+    repo_path: /home/me/other
+  - name: work
+layers: [team, default, default]
+`)
+	_, err := LoadConf(configPath)
+	for _, want := range []string{"line 2: repos: default names the top-level repository",
+		"line 2: repos: work has no repo_path", "line 6: layers: unknown repository team",
+		"line 6: layers: default is listed twice"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadConf() error = %v, want %v", err, want)
+		}
+	}
+}
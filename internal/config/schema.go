@@ -0,0 +1,243 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal/git"
+)
+
+// Setting describes a key of the config file
+type Setting struct {
+	Key         string
+	Description string
+	// Default is what an unset key means, as shown to the user
+	Default string
+	// Values lists the accepted values, empty when any value of the type is accepted
+	Values []string
+}
+
+// Schema lists every key of the config file in the order they are shown
+var Schema = []Setting{
+	{Key: "repo_path", Description: "Folder of the dotfiles repository", Default: DefaultFolder},
+	{Key: "info_path", Description: "Manifest of the tracked entries, inside repo_path",
+		Default: "info.json in repo_path"},
+	{Key: "pull_strategy", Description: "How sync integrates remote changes", Default: git.StrategyFFOnly,
+		Values: []string{git.StrategyFFOnly, git.StrategyRebase, git.StrategyMerge}},
+	{Key: "autostash", Description: "Stash local changes while sync pulls", Default: "false"},
+	{Key: "branch", Description: "Branch this machine works on", Default: "the default branch of the remote"},
+	{Key: "git_backend", Description: "git implementation, go-git works without a git binary",
+		Default: git.BackendExec, Values: []string{git.BackendExec, git.BackendGoGit}},
+	{Key: "remotes", Description: "Remotes sync pulls from and pushes to", Default: git.DefaultRemote},
+	{Key: "read_only", Description: "Only pull the repository, never commit or push to it", Default: "false"},
+	{Key: "command_timeout", Description: "Timeout of local git commands", Default: "10m"},
+	{Key: "network_timeout", Description: "Timeout of fetch, pull and push", Default: "2m"},
+	{Key: "network_retries", Description: "Retries of failed network operations, -1 disables them", Default: "2"},
+	{Key: "dir_mode", Description: "Octal mode of the parent directories created for links", Default: "0755"},
+	{Key: "dir_modes", Description: "Modes of created directories per directory and below", Default: "none"},
+	{Key: "ignore", Description: "Patterns ignored on this machine on top of .dotmanignore, in gitignore syntax",
+		Default: "none"},
+	{Key: "repos", Description: "Further repositories with a name, repo_path, info_path, branch and remotes",
+		Default: "none"},
+	{Key: "layers", Description: "Repositories stacked from base to top, later ones win per target path",
+		Default: "none"},
+}
+
+// Lookup returns the setting of key
+func Lookup(key string) (Setting, bool) {
+	for _, setting := range Schema {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// fieldIndex returns the index of the Config field stored under key
+func fieldIndex(key string) (int, bool) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag == key && tag != "" && tag != "-" {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Problem is something wrong with one key of the config file
+type Problem struct {
+	Key string
+	// Line is where the key is in the file, zero when it is not in the file
+	Line    int
+	Message string
+}
+
+func (p Problem) Error() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%v: %v", p.Key, p.Message)
+	}
+	return fmt.Sprintf("line %d: %v: %v", p.Line, p.Key, p.Message)
+}
+
+// ValidationError lists every problem found in a config file
+type ValidationError struct {
+	Path     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{fmt.Sprintf("invalid config %v:", e.Path)}
+	for _, problem := range e.Problems {
+		lines = append(lines, "  "+problem.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// unknownKey explains a key that is not in the schema, suggesting the closest one
+func unknownKey(key string) string {
+	best, bestDistance := "", 3
+	for _, setting := range Schema {
+		if distance := editDistance(key, setting.Key); distance < bestDistance {
+			best, bestDistance = setting.Key, distance
+		}
+	}
+	if best == "" {
+		return "unknown key, see 'dotman config list'"
+	}
+	return fmt.Sprintf("unknown key, did you mean %v?", best)
+}
+
+// editDistance is the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// checkValues checks the values that parsed beyond their type
+func (c *Config) checkValues() []Problem {
+	problems := []Problem{}
+	add := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Line: c.lines[key], Message: fmt.Sprintf(format, args...)})
+	}
+
+	enums := []struct{ key, value string }{{"pull_strategy", c.PullStrategy}, {"git_backend", c.GitBackend}}
+	for _, enum := range enums {
+		setting, _ := Lookup(enum.key)
+		if enum.value != "" && !slices.Contains(setting.Values, enum.value) {
+			add(enum.key, "%q is not one of %v", enum.value, strings.Join(setting.Values, ", "))
+		}
+	}
+	if c.CommandTimeout < 0 {
+		add("command_timeout", "can not be negative")
+	}
+	if c.NetworkTimeout < 0 {
+		add("network_timeout", "can not be negative")
+	}
+	if c.DirMode != "" {
+		if _, err := parseMode(c.DirMode); err != nil {
+			add("dir_mode", "%v", err)
+		}
+	}
+	dirs := slices.Sorted(maps.Keys(c.DirModes))
+	for _, dir := range dirs {
+		if _, err := parseMode(c.DirModes[dir]); err != nil {
+			add("dir_modes", "%v: %v", dir, err)
+		}
+	}
+
+	names := map[string]bool{}
+	primaries := 0
+	for _, remote := range c.Remotes {
+		switch {
+		case remote.Name == "":
+			add("remotes", "every remote needs a name")
+		case names[remote.Name]:
+			add("remotes", "%v is listed twice", remote.Name)
+		}
+		names[remote.Name] = true
+		if remote.Primary {
+			primaries++
+		}
+	}
+	if primaries > 1 {
+		add("remotes", "only one remote can be primary")
+	}
+	return append(problems, c.checkRepos()...)
+}
+
+// checkPaths checks that every repository and its info.json exist where they are configured, each in a folder
+// of its own
+func (c *Config) checkPaths() []Problem {
+	problems := c.checkRepoPaths("", c.FolderPath, c.InfoPath)
+	folders := map[string]string{c.FolderPath: DefaultRepo}
+	for _, repo := range c.Repos {
+		if repo.FolderPath == "" {
+			continue
+		}
+		if other, ok := folders[repo.FolderPath]; ok {
+			problems = append(problems, Problem{Key: "repos", Line: c.lines["repos"],
+				Message: fmt.Sprintf("%v uses the folder of %v", repo.Name, other)})
+			continue
+		}
+		folders[repo.FolderPath] = repo.Name
+		problems = append(problems, c.checkRepoPaths(repo.Name, repo.FolderPath, repo.InfoPath)...)
+	}
+	return problems
+}
+
+// checkRepoPaths checks the paths of one repository, name is empty for the top-level one
+func (c *Config) checkRepoPaths(name, folderPath, infoPath string) []Problem {
+	problems := []Problem{}
+	add := func(key, message string) {
+		line := c.lines[key]
+		if name != "" {
+			key, line = "repos", c.lines["repos"]
+			message = name + ": " + message
+		}
+		problems = append(problems, Problem{Key: key, Line: line, Message: message})
+	}
+
+	stat, err := os.Stat(folderPath)
+	switch {
+	case os.IsNotExist(err):
+		add("repo_path", fmt.Sprintf("%v does not exist, run 'dotman init'", folderPath))
+		return problems
+	case err != nil:
+		add("repo_path", err.Error())
+		return problems
+	case !stat.IsDir():
+		add("repo_path", fmt.Sprintf("%v is not a directory", folderPath))
+		return problems
+	}
+
+	rel, err := filepath.Rel(folderPath, infoPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		add("info_path", fmt.Sprintf("%v is not inside repo_path %v", infoPath, folderPath))
+		return problems
+	}
+	if _, err := os.Stat(infoPath); err != nil {
+		add("info_path", fmt.Sprintf("%v does not exist, run 'dotman init'", infoPath))
+	}
+	return problems
+}
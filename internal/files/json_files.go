@@ -5,18 +5,48 @@ import (
 	"fmt"
 	"maps"
 	"os"
+	"slices"
 
 	"github.com/ZonCen/dotman/internal"
 )
 
 type FileInfo struct {
-	Symlink string   `json:"symlink"`
-	Path    string   `json:"path"`
-	Status  string   `json:"status"`
-	Errors  []string `json:"errors"`
+	Symlink   string     `json:"symlink"`
+	Path      string     `json:"path"`
+	Status    string     `json:"status"`
+	Errors    []string   `json:"errors"`
+	Tombstone *Tombstone `json:"tombstone,omitempty"`
+}
+
+// Ways a removed entry is retired on every machine
+const (
+	RetireRestore = "restore"
+	RetireDelete  = "delete"
+)
+
+// Tombstone marks an entry as removed so every machine can retire it on its next sync
+type Tombstone struct {
+	Action    string   `json:"action"`
+	RemovedBy string   `json:"removed_by"`
+	RemovedAt string   `json:"removed_at"`
+	AppliedBy []string `json:"applied_by"`
+}
+
+// AppliedOn reports whether machine has already retired the entry
+func (t *Tombstone) AppliedOn(machine string) bool {
+	return slices.Contains(t.AppliedBy, machine)
 }
 
 func SaveStatus(path string, info map[string]FileInfo) error {
+	internal.LogVerbose("Shrinking paths so the file can be shared between machines")
+	shrunk := make(map[string]FileInfo, len(info))
+	for fileName, fileInfo := range info {
+		fileInfo.Symlink = internal.ShrinkPath(fileInfo.Symlink)
+		fileInfo.Path = internal.ShrinkPath(fileInfo.Path)
+		shrunk[fileName] = fileInfo
+	}
+	info = shrunk
+
 	internal.LogVerbose("Marshal information and adding indentations")
 	jsonBytes, err := json.MarshalIndent(info, "", "  ")
 	if err != nil {
@@ -24,7 +54,7 @@ func SaveStatus(path string, info map[string]FileInfo) error {
 	}
 
 	internal.LogVerbose("Writing data to %v", path)
-	if err := os.WriteFile(path, jsonBytes, 0644); err != nil {
+	if err := internal.WriteFileAtomic(path, jsonBytes, 0644); err != nil {
 		return fmt.Errorf("failed to write to disk: %w", err)
 	}
 
@@ -37,9 +67,15 @@ func ReadFile(path string) (map[string]FileInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not read the file: %w", err)
 	}
+
+	return ParseFile(bytes)
+}
+
+// ParseFile unmarshals info.json content and resolves the paths of every entry
+func ParseFile(bytes []byte) (map[string]FileInfo, error) {
 	internal.LogVerbose("Unmarshal bytes")
 	data := make(map[string]FileInfo)
-	err = json.Unmarshal(bytes, &data)
+	err := json.Unmarshal(bytes, &data)
 	if err != nil {
 		return nil, fmt.Errorf("could not unmarshal data: %w", err)
 	}
@@ -79,22 +115,3 @@ func AddFiles(path string, info map[string]FileInfo) error {
 
 	return nil
 }
-
-func RemoveFiles(path, filename string) error {
-	internal.LogVerbose("Collecting data from disk")
-	localFileInfo, err := ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("%w", err)
-	}
-
-	internal.LogVerbose("Removing data")
-	delete(localFileInfo, filename)
-
-	internal.LogVerbose("Saving data to disk")
-	err = SaveStatus(path, localFileInfo)
-	if err != nil {
-		return fmt.Errorf("%w", err)
-	}
-
-	return nil
-}
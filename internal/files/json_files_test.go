@@ -144,57 +144,6 @@ func TestAddFiles(t *testing.T) {
 	}
 }
 
-func TestRemoveFiles(t *testing.T) {
-	testDir := testutils.TestDir(t)
-	defer testutils.CleanupTestDir(t, testDir)
-
-	infoPath := filepath.Join(testDir, "info.json")
-
-	// Create initial file with multiple entries
-	initialInfo := map[string]FileInfo{
-		".zshrc": {
-			Symlink: "~/.zshrc",
-			Path:    "~/dotfiles/.zshrc",
-			Status:  "ok",
-			Errors:  nil,
-		},
-		".vimrc": {
-			Symlink: "~/.vimrc",
-			Path:    "~/dotfiles/.vimrc",
-			Status:  "ok",
-			Errors:  nil,
-		},
-	}
-
-	err := SaveStatus(infoPath, initialInfo)
-	if err != nil {
-		t.Errorf("SaveStatus() error = %v", err)
-	}
-
-	// Remove one file
-	err = RemoveFiles(infoPath, ".zshrc")
-	if err != nil {
-		t.Errorf("RemoveFiles() error = %v", err)
-	}
-
-	// Verify only one file remains
-	info, err := ReadFile(infoPath)
-	if err != nil {
-		t.Errorf("ReadFile() error = %v", err)
-	}
-
-	if len(info) != 1 {
-		t.Errorf("Expected 1 file, got %d", len(info))
-	}
-
-	if _, exists := info[".zshrc"]; exists {
-		t.Error("Expected .zshrc to be removed")
-	}
-	if _, exists := info[".vimrc"]; !exists {
-		t.Error("Expected .vimrc to still exist")
-	}
-}
-
 func TestFileInfoRoundTrip(t *testing.T) {
 	testDir := testutils.TestDir(t)
 	defer testutils.CleanupTestDir(t, testDir)
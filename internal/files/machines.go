@@ -0,0 +1,52 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// ReadMachines returns every machine that has synced the repository, keyed by name with the last time it was seen
+func ReadMachines(path string) (map[string]string, error) {
+	machines := make(map[string]string)
+	internal.LogVerbose("Reading known machines from %v", path)
+	bytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return machines, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the file: %w", err)
+	}
+	if err := json.Unmarshal(bytes, &machines); err != nil {
+		return nil, fmt.Errorf("could not unmarshal data: %w", err)
+	}
+	return machines, nil
+}
+
+// RegisterMachine records machine in the known machines file
+func RegisterMachine(path, machine string) (map[string]string, error) {
+	machines, err := ReadMachines(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	// Only the day is kept so syncing does not produce a new commit every time
+	today := time.Now().UTC().Format(time.DateOnly)
+	if machines[machine] == today {
+		return machines, nil
+	}
+	machines[machine] = today
+
+	internal.LogVerbose("Registering %v in %v", machine, path)
+	jsonBytes, err := json.MarshalIndent(machines, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal machines: %w", err)
+	}
+	if err := internal.WriteFileAtomic(path, jsonBytes, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write to disk: %w", err)
+	}
+	return machines, nil
+}
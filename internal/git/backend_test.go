@@ -0,0 +1,277 @@
+package git
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+// setupEnv isolates git from the user's config and gives commits an author
+func setupEnv(t *testing.T, testDir string) {
+	t.Setenv("HOME", testDir)
+	t.Setenv("GIT_AUTHOR_NAME", "dotman")
+	t.Setenv("GIT_AUTHOR_EMAIL", "dotman@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "dotman")
+	t.Setenv("GIT_COMMITTER_EMAIL", "dotman@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(testDir, "gitconfig"))
+	testutils.CreateTestFile(t, filepath.Join(testDir, "gitconfig"), "[init]\n\tdefaultBranch = main\n")
+}
+
+func TestBackends(t *testing.T) {
+	backends := map[string]Backend{BackendExec: ExecBackend{}, BackendGoGit: GoGitBackend{}}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			if name == BackendExec {
+				if _, err := exec.LookPath("git"); err != nil {
+					t.Skip("git is not installed")
+				}
+			}
+			testDir := testutils.TestDir(t)
+			defer testutils.CleanupTestDir(t, testDir)
+			setupEnv(t, testDir)
+
+			remote := filepath.Join(testDir, "remote.git")
+			if _, err := gogit.PlainInit(remote, true); err != nil {
+				t.Fatalf("PlainInit() error = %v", err)
+			}
+			machineA := filepath.Join(testDir, "machineA")
+			machineB := filepath.Join(testDir, "machineB")
+
+			must := func(step string, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("%v error = %v", step, err)
+				}
+			}
+
+			// machineA creates the repository and publishes it
+			testutils.CreateTestFile(t, filepath.Join(machineA, "info.json"), "{}")
+			if ok, err := backend.IsRepo(machineA); err != nil || ok {
+				t.Fatalf("IsRepo() before Init = %v, %v, want false", ok, err)
+			}
+			must("Init()", backend.Init(machineA))
+			if ok, err := backend.IsRepo(machineA); err != nil || !ok {
+				t.Fatalf("IsRepo() after Init = %v, %v, want true", ok, err)
+			}
+			must("AddRemote()", backend.AddRemote(machineA, DefaultRemote, remote))
+			if refs, err := backend.ListRemote(machineA, DefaultRemote); err != nil || !refs.Empty() {
+				t.Fatalf("ListRemote() of a new remote = %+v, %v, want it empty", refs, err)
+			}
+			must("Add()", backend.Add(machineA))
+			if staged, err := backend.HasStagedChanges(machineA); err != nil || !staged {
+				t.Fatalf("HasStagedChanges() = %v, %v, want true", staged, err)
+			}
+			must("Commit()", backend.Commit(machineA, "initial"))
+			must("RenameBranch()", backend.RenameBranch(machineA, "trunk"))
+			branch, err := backend.CurrentBranch(machineA)
+			must("CurrentBranch()", err)
+			if branch != "trunk" {
+				t.Fatalf("CurrentBranch() after RenameBranch = %v, want trunk", branch)
+			}
+			must("Push()", backend.Push(machineA, DefaultRemote, branch, true))
+			if url, err := backend.GetRemoteURL(machineA, DefaultRemote); err != nil || url != remote {
+				t.Errorf("GetRemoteURL() = %v, %v, want %v", url, err, remote)
+			}
+
+			// a second remote mirrors the branch
+			backup := filepath.Join(testDir, "backup.git")
+			if _, err := gogit.PlainInit(backup, true); err != nil {
+				t.Fatalf("PlainInit() error = %v", err)
+			}
+			must("AddRemote(backup)", backend.AddRemote(machineA, "backup", backup))
+			must("Push(backup)", backend.Push(machineA, "backup", branch, false))
+			if refs, err := backend.ListRemote(machineA, DefaultRemote); err != nil ||
+				strings.Join(refs.Branches, ",") != branch {
+				t.Fatalf("ListRemote() = %+v, %v, want only %v", refs, err, branch)
+			}
+			if exists, err := backend.RemoteBranchExists(machineA, "backup", branch); err != nil || !exists {
+				t.Fatalf("RemoteBranchExists(backup) = %v, %v, want true", exists, err)
+			}
+
+			// machineB checks it out like init does
+			testutils.CreateTestFile(t, filepath.Join(machineB, ".keep"), "")
+			must("Init()", backend.Init(machineB))
+			must("AddRemote()", backend.AddRemote(machineB, DefaultRemote, remote))
+			must("Fetch()", backend.Fetch(machineB, DefaultRemote))
+			must("FirstCheckout()", backend.FirstCheckout(machineB, DefaultRemote, branch))
+			testutils.AssertFileContent(t, filepath.Join(machineB, "info.json"), "{}")
+
+			// a change on machineA is fast-forwarded into machineB
+			testutils.CreateTestFile(t, filepath.Join(machineA, "info.json"), `{"a": {}}`)
+			must("Add()", backend.Add(machineA))
+			must("Commit()", backend.Commit(machineA, "change"))
+			must("Push()", backend.Push(machineA, DefaultRemote, "", true))
+
+			if exists, err := backend.RemoteBranchExists(machineB, DefaultRemote, branch); err != nil || !exists {
+				t.Fatalf("RemoteBranchExists() = %v, %v, want true", exists, err)
+			}
+			if exists, err := backend.RemoteBranchExists(machineB, DefaultRemote, "missing"); err != nil || exists {
+				t.Errorf("RemoteBranchExists(missing) = %v, %v, want false", exists, err)
+			}
+			before, err := backend.RevParse(machineB, "HEAD")
+			must("RevParse()", err)
+			must("Pull()", backend.Pull(machineB, DefaultRemote, branch, StrategyFFOnly, false))
+			testutils.AssertFileContent(t, filepath.Join(machineB, "info.json"), `{"a": {}}`)
+
+			changed, err := backend.ChangedFiles(machineB, before, "HEAD")
+			must("ChangedFiles()", err)
+			if strings.Join(changed, ",") != "info.json" {
+				t.Errorf("ChangedFiles() = %v, want [info.json]", changed)
+			}
+			if tracked, err := backend.ListFiles(machineB, "HEAD"); err != nil || strings.Join(tracked, ",") != "info.json" {
+				t.Errorf("ListFiles() = %v, %v, want [info.json]", tracked, err)
+			}
+			if content, err := backend.ShowFile(machineB, before, "info.json"); err != nil || content != "{}" {
+				t.Errorf("ShowFile() = %q, %v, want {}", content, err)
+			}
+			commits, err := backend.Log(machineB, before, "HEAD")
+			must("Log()", err)
+			if len(commits) != 1 || commits[0].Subject != "change" || commits[0].Author != "dotman" {
+				t.Errorf("Log() = %+v, want the change commit", commits)
+			}
+			if all, err := backend.Log(machineB, "", "HEAD"); err != nil || len(all) != 2 {
+				t.Errorf("Log() of everything = %+v, %v, want 2 commits", all, err)
+			}
+			if base, err := backend.MergeBase(machineB, before, "HEAD"); err != nil || base != before {
+				t.Errorf("MergeBase() = %v, %v, want %v", base, err, before)
+			}
+
+			// a host branch is fast-forward merged back and a path picked from it
+			must("CreateBranch()", backend.CreateBranch(machineB, "host"))
+			testutils.CreateTestFile(t, filepath.Join(machineB, ".zshrc"), "export EDITOR=vim\n")
+			must("Add()", backend.Add(machineB))
+			must("Commit()", backend.Commit(machineB, "host change"))
+			must("Checkout()", backend.Checkout(machineB, branch))
+			testutils.AssertFileNotExists(t, filepath.Join(machineB, ".zshrc"))
+			if !backend.LocalBranchExists(machineB, "host") {
+				t.Error("LocalBranchExists(host) = false, want true")
+			}
+
+			must("CheckoutPaths()", backend.CheckoutPaths(machineB, "host", ".zshrc"))
+			testutils.AssertFileContent(t, filepath.Join(machineB, ".zshrc"), "export EDITOR=vim\n")
+			must("StagePath()", backend.StagePath(machineB, ".zshrc"))
+			must("Merge()", backend.Merge(machineB, "host"))
+
+			if status, err := backend.Status(machineB); err != nil || strings.TrimSpace(status) != "" {
+				t.Errorf("Status() = %q, %v, want clean", status, err)
+			}
+			if operation, err := backend.InProgress(machineB); err != nil || operation != OperationNone {
+				t.Errorf("InProgress() = %q, %v, want none", operation, err)
+			}
+			if conflicts, err := backend.ConflictedFiles(machineB); err != nil || len(conflicts) != 0 {
+				t.Errorf("ConflictedFiles() = %v, %v, want none", conflicts, err)
+			}
+
+			// a committed file that is ignored now is untracked, kept on disk and not added again
+			testutils.CreateTestFile(t, filepath.Join(machineB, ".zshrc.swp"), "swap")
+			must("Add()", backend.Add(machineB))
+			must("Commit()", backend.Commit(machineB, "swap file"))
+			testutils.CreateTestFile(t, filepath.Join(machineB, ".gitignore"), "*.swp\n")
+			must("Untrack()", backend.Untrack(machineB, ".zshrc.swp"))
+			must("Add()", backend.Add(machineB))
+			must("Commit()", backend.Commit(machineB, "ignore swap files"))
+			if tracked, err := backend.ListFiles(machineB, "HEAD"); err != nil || slices.Contains(tracked, ".zshrc.swp") {
+				t.Errorf("ListFiles() after Untrack = %v, %v, want the swap file gone", tracked, err)
+			}
+			testutils.AssertFileExists(t, filepath.Join(machineB, ".zshrc.swp"))
+
+			// both machines committed, a fast-forward only pull fails the way git words it
+			testutils.CreateTestFile(t, filepath.Join(machineA, ".vimrc"), "set number\n")
+			must("Add()", backend.Add(machineA))
+			must("Commit()", backend.Commit(machineA, "vim"))
+			must("Push()", backend.Push(machineA, DefaultRemote, "", true))
+			err = backend.Pull(machineB, DefaultRemote, branch, StrategyFFOnly, false)
+			if err == nil || !strings.Contains(strings.ToLower(err.Error()), "not possible to fast-forward") {
+				t.Errorf("Pull() of diverged history error = %v, want not possible to fast-forward", err)
+			}
+		})
+	}
+}
+
+func TestGoGitBackendUnsupported(t *testing.T) {
+	backend := GoGitBackend{}
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"pull with rebase", backend.Pull("unused", DefaultRemote, "main", StrategyRebase, false)},
+		{"pull with autostash", backend.Pull("unused", DefaultRemote, "main", StrategyFFOnly, true)},
+		{"merge continue", backend.MergeContinue("unused")},
+		{"rebase abort", backend.RebaseAbort("unused")},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, ErrUnsupported) {
+			t.Errorf("%v error = %v, want ErrUnsupported", tt.name, tt.err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	backends := map[string]Backend{"": ExecBackend{}, BackendExec: ExecBackend{}, BackendGoGit: GoGitBackend{}}
+	for name, want := range backends {
+		got, err := New(name)
+		if err != nil || got != want {
+			t.Errorf("New(%q) = %T, %v, want %T", name, got, err, want)
+		}
+	}
+	if _, err := New("svn"); err == nil {
+		t.Error("Expected error for an unknown backend")
+	}
+}
+
+// A merge stopped by the git binary must be readable through the go-git backend
+func TestGoGitBackendReadsConflicts(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	repo := filepath.Join(testDir, "repo")
+	testutils.CloneGitRemote(t, remote, repo)
+
+	testutils.RunGit(t, repo, "checkout", "-q", "-b", "host")
+	testutils.CreateTestFile(t, filepath.Join(repo, "info.json"), `{"host": {}}`)
+	testutils.RunGit(t, repo, "commit", "-q", "-am", "host")
+	testutils.RunGit(t, repo, "checkout", "-q", "main")
+	testutils.CreateTestFile(t, filepath.Join(repo, "info.json"), `{"main": {}}`)
+	testutils.RunGit(t, repo, "commit", "-q", "-am", "main")
+	if err := (ExecBackend{}).Merge(repo, "host"); err == nil {
+		t.Fatal("Expected the merge to stop on a conflict")
+	}
+
+	backend := GoGitBackend{}
+	if operation, err := backend.InProgress(repo); err != nil || operation != OperationMerge {
+		t.Errorf("InProgress() = %q, %v, want %v", operation, err, OperationMerge)
+	}
+	if conflicts, err := backend.ConflictedFiles(repo); err != nil || strings.Join(conflicts, ",") != "info.json" {
+		t.Errorf("ConflictedFiles() = %v, %v, want [info.json]", conflicts, err)
+	}
+	for stage, want := range map[int]string{StageBase: "{}", StageOurs: `{"main": {}}`, StageTheirs: `{"host": {}}`} {
+		content, ok, err := backend.ShowStage(repo, stage, "info.json")
+		if err != nil || !ok || content != want {
+			t.Errorf("ShowStage(%d) = %q, %v, %v, want %q", stage, content, ok, err, want)
+		}
+	}
+}
+
+func TestParseRemoteRefs(t *testing.T) {
+	output := "ref: refs/heads/trunk\tHEAD\n" +
+		"3f2a9c1e\tHEAD\n" +
+		"3f2a9c1e\trefs/heads/trunk\n" +
+		"9b1d0e2f\trefs/heads/host\n" +
+		"7c4e5a6b\trefs/tags/v1\n"
+	refs := parseRemoteRefs(output)
+	if refs.Head != "trunk" || strings.Join(refs.Branches, ",") != "trunk,host" {
+		t.Errorf("parseRemoteRefs() = %+v, want head trunk and branches trunk, host", refs)
+	}
+	if !parseRemoteRefs("").Empty() {
+		t.Error("parseRemoteRefs() of no output is not empty")
+	}
+}
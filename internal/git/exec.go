@@ -0,0 +1,364 @@
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// ExecBackend runs every operation through the git binary
+type ExecBackend struct{}
+
+func run(repoPath string, args ...string) error {
+	_, err := internal.Run("git", append([]string{"-C", repoPath}, args...)...)
+	return err
+}
+
+// IsRepo reports whether repoPath is inside a work tree, an error means git itself could not run
+// runNetwork runs a git command that talks to origin, see internal.RunNetwork
+func runNetwork(repoPath string, args ...string) error {
+	_, err := internal.RunNetwork("git", append([]string{"-C", repoPath}, args...)...)
+	return err
+}
+
+func (ExecBackend) IsRepo(repoPath string) (bool, error) {
+	code, err := internal.Run("git", "-C", repoPath, "rev-parse", "--is-inside-work-tree")
+	if code > 0 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to run git: %w", err)
+	}
+	return true, nil
+}
+
+func (ExecBackend) Init(repoPath string) error {
+	return run(repoPath, "init")
+}
+
+func (ExecBackend) Status(repoPath string) (string, error) {
+	return internal.RunOutput("git", "-C", repoPath, "status", "--porcelain")
+}
+
+// HasStagedChanges reports whether the index differs from HEAD
+func (ExecBackend) HasStagedChanges(repoPath string) (bool, error) {
+	code, err := internal.Run("git", "-C", repoPath, "diff", "--cached", "--quiet")
+	if code == 1 {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to diff the index: %w", err)
+	}
+	return false, nil
+}
+
+func (ExecBackend) Add(repoPath string) error {
+	return run(repoPath, "add", "-A")
+}
+
+// StagePath stages the current state of path, including its deletion
+func (ExecBackend) StagePath(repoPath, path string) error {
+	return run(repoPath, "add", "-A", "--", path)
+}
+
+// Untrack removes paths from the index and keeps them in the work tree
+func (ExecBackend) Untrack(repoPath string, paths ...string) error {
+	return run(repoPath, append([]string{"rm", "-r", "-q", "--cached", "--ignore-unmatch", "--"}, paths...)...)
+}
+
+func (ExecBackend) Commit(repoPath, message string) error {
+	return run(repoPath, "commit", "-m", message)
+}
+
+func (ExecBackend) GetRemoteURL(repoPath, remote string) (string, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "remote", "get-url", remote)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote URL %w", err)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+func (ExecBackend) AddRemote(repoPath, remote, url string) error {
+	return run(repoPath, "remote", "add", remote, url)
+}
+
+func (ExecBackend) ChangeRemote(repoPath, remote, url string) error {
+	return run(repoPath, "remote", "set-url", remote, url)
+}
+
+func (ExecBackend) Fetch(repoPath, remote string) error {
+	return runNetwork(repoPath, "fetch", remote)
+}
+
+// Push pushes branch to remote, optionally making it the upstream. An empty branch pushes the current one
+// as configured
+func (ExecBackend) Push(repoPath, remote, branch string, upstream bool) error {
+	if branch == "" {
+		return runNetwork(repoPath, "push")
+	}
+	if upstream {
+		return runNetwork(repoPath, "push", "-u", remote, branch)
+	}
+	return runNetwork(repoPath, "push", remote, branch)
+}
+
+// Pull integrates branch from remote using strategy, an empty branch pulls the configured upstream
+func (ExecBackend) Pull(repoPath, remote, branch, strategy string, autostash bool) error {
+	args := []string{"-c", "merge.conflictStyle=diff3", "pull"}
+	switch strategy {
+	case StrategyRebase:
+		args = append(args, "--rebase")
+	case StrategyMerge:
+		args = append(args, "--no-rebase", "--no-edit")
+	default:
+		args = append(args, "--ff-only")
+	}
+	if autostash {
+		args = append(args, "--autostash")
+	}
+	if branch != "" {
+		args = append(args, remote, branch)
+	}
+	return runNetwork(repoPath, args...)
+}
+
+// RemoteBranchExists checks remote for branch
+func (ExecBackend) RemoteBranchExists(repoPath, remote, branch string) (bool, error) {
+	code, err := internal.RunNetwork("git", "-C", repoPath, "ls-remote", "--exit-code", "--heads", remote, branch)
+	if code == 2 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %v on %v: %w", branch, remote, err)
+	}
+	return true, nil
+}
+
+// ListRemote asks remote for its branches and default branch
+func (ExecBackend) ListRemote(repoPath, remote string) (RemoteRefs, error) {
+	out, err := internal.RunNetworkOutput("git", "-C", repoPath, "ls-remote", "--symref", remote)
+	if err != nil {
+		return RemoteRefs{}, fmt.Errorf("failed to list %v: %w", remote, err)
+	}
+	return parseRemoteRefs(out), nil
+}
+
+// parseRemoteRefs reads the output of git ls-remote --symref
+func parseRemoteRefs(output string) RemoteRefs {
+	refs := RemoteRefs{}
+	for _, line := range ListChanges(output) {
+		target, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if symref, found := strings.CutPrefix(target, "ref: "); found {
+			if name == "HEAD" {
+				refs.Head = strings.TrimPrefix(symref, "refs/heads/")
+			}
+			continue
+		}
+		if branch, found := strings.CutPrefix(name, "refs/heads/"); found {
+			refs.Branches = append(refs.Branches, branch)
+		}
+	}
+	return refs
+}
+
+func (ExecBackend) CurrentBranch(repoPath string) (string, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// LocalBranchExists checks whether branch exists in the local repository
+func (ExecBackend) LocalBranchExists(repoPath, branch string) bool {
+	return run(repoPath, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch) == nil
+}
+
+// FirstCheckout creates a local branch tracking the same branch on remote
+func (ExecBackend) FirstCheckout(repoPath, remote, branch string) error {
+	return run(repoPath, "checkout", "-b", branch, "--track", remote+"/"+branch)
+}
+
+func (ExecBackend) Checkout(repoPath, branch string) error {
+	return run(repoPath, "checkout", branch)
+}
+
+func (ExecBackend) CreateBranch(repoPath, branch string) error {
+	return run(repoPath, "checkout", "-b", branch)
+}
+
+// RenameBranch renames the current branch, also when it has no commits yet
+func (ExecBackend) RenameBranch(repoPath, branch string) error {
+	return run(repoPath, "branch", "-M", branch)
+}
+
+// CheckoutPaths takes paths from rev into the working tree and index
+func (ExecBackend) CheckoutPaths(repoPath, rev string, paths ...string) error {
+	return run(repoPath, append([]string{"checkout", rev, "--"}, paths...)...)
+}
+
+// Merge merges branch into the current branch with diff3 conflict markers
+func (ExecBackend) Merge(repoPath, branch string) error {
+	return run(repoPath, "-c", "merge.conflictStyle=diff3", "merge", "--no-edit", branch)
+}
+
+// RevParse resolves a revision such as HEAD or ORIG_HEAD to a commit
+func (ExecBackend) RevParse(repoPath, rev string) (string, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "rev-parse", "--verify", "--quiet", rev)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %v: %w", rev, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ShowFile returns the content of path as it was in the given revision
+func (ExecBackend) ShowFile(repoPath, rev, path string) (string, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "show", rev+":"+path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %v at %v: %w", path, rev, err)
+	}
+	return out, nil
+}
+
+// ListFiles lists the regular files tracked in the given revision, symlinks and submodules are left out
+func (ExecBackend) ListFiles(repoPath, rev string) ([]string, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "ls-tree", "-r", "-z", rev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files at %v: %w", rev, err)
+	}
+	return parseTree(out), nil
+}
+
+// parseTree reads ls-tree -z output, "<mode> <type> <hash>\t<path>" per entry, keeping regular files
+func parseTree(out string) []string {
+	paths := []string{}
+	for _, entry := range strings.Split(out, "\x00") {
+		meta, path, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+		if mode, _, _ := strings.Cut(meta, " "); mode == "100644" || mode == "100755" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// ChangedFiles lists the paths that differ between two revisions
+func (ExecBackend) ChangedFiles(repoPath, from, to string) ([]string, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "diff", "--name-only", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	return ListChanges(out), nil
+}
+
+// Log lists the commits reachable from to but not from, newest first. An empty from lists all of them
+func (ExecBackend) Log(repoPath, from, to string) ([]Commit, error) {
+	revs := to
+	if from != "" {
+		revs = from + ".." + to
+	}
+	out, err := internal.RunOutput("git", "-C", repoPath, "log", "--format=%H%x09%an%x09%s", revs, "--")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits %v: %w", revs, err)
+	}
+	commits := []Commit{}
+	for _, line := range ListChanges(out) {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		commits = append(commits, Commit{Hash: fields[0], Author: fields[1], Subject: fields[2]})
+	}
+	return commits, nil
+}
+
+// MergeBase returns the best common ancestor of two revisions
+func (ExecBackend) MergeBase(repoPath, a, b string) (string, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "merge-base", a, b)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the merge base of %v and %v: %w", a, b, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// InProgress reports which operation, if any, is waiting to be continued or aborted
+func (ExecBackend) InProgress(repoPath string) (string, error) {
+	for _, check := range []struct {
+		path      string
+		operation string
+	}{
+		{"rebase-merge", OperationRebase},
+		{"rebase-apply", OperationRebase},
+		{"MERGE_HEAD", OperationMerge},
+	} {
+		out, err := internal.RunOutput("git", "-C", repoPath, "rev-parse", "--git-path", check.path)
+		if err != nil {
+			return OperationNone, fmt.Errorf("failed to locate %v: %w", check.path, err)
+		}
+		path := strings.TrimSpace(out)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(repoPath, path)
+		}
+		if internal.FileExist(path) {
+			return check.operation, nil
+		}
+	}
+	return OperationNone, nil
+}
+
+// ConflictedFiles lists the repository paths that still have unmerged changes
+func (ExecBackend) ConflictedFiles(repoPath string) ([]string, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conflicts: %w", err)
+	}
+	return ListChanges(out), nil
+}
+
+// ShowStage returns the content of path at the given index stage, and false if the stage does not exist
+func (ExecBackend) ShowStage(repoPath string, stage int, path string) (string, bool, error) {
+	out, err := internal.RunOutput("git", "-C", repoPath, "ls-files", "--unmerged", "--", path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to list stages for %v: %w", path, err)
+	}
+	found := false
+	for _, line := range ListChanges(out) {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[2] == fmt.Sprint(stage) {
+			found = true
+		}
+	}
+	if !found {
+		return "", false, nil
+	}
+
+	content, err := internal.RunOutput("git", "-C", repoPath, "show", fmt.Sprintf(":%d:%s", stage, path))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read stage %d of %v: %w", stage, path, err)
+	}
+	return content, true, nil
+}
+
+func (ExecBackend) MergeContinue(repoPath string) error {
+	return run(repoPath, "commit", "--no-edit")
+}
+
+func (ExecBackend) MergeAbort(repoPath string) error {
+	return run(repoPath, "merge", "--abort")
+}
+
+func (ExecBackend) RebaseContinue(repoPath string) error {
+	return run(repoPath, "-c", "core.editor=true", "rebase", "--continue")
+}
+
+func (ExecBackend) RebaseAbort(repoPath string) error {
+	return run(repoPath, "rebase", "--abort")
+}
@@ -1,77 +1,143 @@
 package git
 
 import (
+	"errors"
 	"fmt"
 	"strings"
-
-	"github.com/ZonCen/dotman/internal"
 )
 
-func CheckIfRepo(repoPath string) (int, error) {
-	return internal.Run("git", "-C", repoPath, "rev-parse", "--is-inside-work-tree")
-}
-
-func Diff(repoPath string) (int, error) {
-	return internal.Run("git", "-C", repoPath, "diff", "--cached", "--quiet")
-}
+// Pull strategies understood by Pull
+const (
+	StrategyFFOnly = "ff-only"
+	StrategyRebase = "rebase"
+	StrategyMerge  = "merge"
+)
 
-func Status(repoPath string) (string, error) {
-	return internal.RunOutput("git", "-C", repoPath, "status", "--porcelain")
-}
+// Operations git can be left in the middle of after a pull
+const (
+	OperationNone   = ""
+	OperationMerge  = "merge"
+	OperationRebase = "rebase"
+)
 
-func ListChanges(input string) []string {
-	lines := strings.Split(input, "\n")
-	sliceLines := []string{}
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		sliceLines = append(sliceLines, line)
-	}
-	return sliceLines
-}
+// Index stages of a conflicted path
+const (
+	StageBase   = 1
+	StageOurs   = 2
+	StageTheirs = 3
+)
 
-func ChangeRemote(folderPath, desiredURL string) (int, error) {
-	return internal.Run("git", "-C", folderPath, "remote", "set-url", "origin", desiredURL)
-}
+// Backends that can be selected with git_backend in the config
+const (
+	BackendExec  = "exec"
+	BackendGoGit = "go-git"
+)
 
-func AddRemote(folderPath, url string) (int, error) {
-	return internal.Run("git", "-C", folderPath, "remote", "add", "origin", url)
-}
+// DefaultRemote is the remote used when none is configured
+const DefaultRemote = "origin"
 
-func FetchOrigin(folderPath string) (int, error) {
-	return internal.Run("git", "-C", folderPath, "fetch", "origin")
-}
+// ErrUnsupported is returned by backends for operations they can not perform
+var ErrUnsupported = errors.New("operation not supported by this git backend")
 
-func FirstCheckout(folderpath, branch string) (int, error) {
-	return internal.Run("git", "-C", folderpath, "checkout", "-b", branch, "--track", "origin/"+branch)
+// Commit is a single commit as listed by Log
+type Commit struct {
+	Hash    string
+	Author  string
+	Subject string
 }
 
-func GetRemoteURL(repoPath string) (string, error) {
-	out, err := internal.RunOutput("git", "-C", repoPath, "remote", "get-url", "origin")
-	if err != nil {
-		return "", fmt.Errorf("failed to get remote URL %w", err)
+// Short returns the abbreviated hash git shows by default
+func (c Commit) Short() string {
+	if len(c.Hash) > 7 {
+		return c.Hash[:7]
 	}
+	return c.Hash
+}
 
-	return strings.TrimSpace(out), nil
+// RemoteRefs are the branches of a remote and the branch its HEAD points at, Head is empty when the remote
+// does not advertise it
+type RemoteRefs struct {
+	Head     string
+	Branches []string
 }
 
-func Add(repoPath string) (int, error) {
-	return internal.Run("git", "-C", repoPath, "add", "-A")
+// Empty reports whether the remote has no branches at all, like a freshly created repository
+func (r RemoteRefs) Empty() bool {
+	return len(r.Branches) == 0
 }
 
-func Commit(repoPath, message string) (int, error) {
-	return internal.Run("git", "-C", repoPath, "commit", "-m", message)
+// Backend is every git operation dotman needs, so the git binary can be swapped for another implementation
+type Backend interface {
+	IsRepo(repoPath string) (bool, error)
+	Init(repoPath string) error
+	Status(repoPath string) (string, error)
+	HasStagedChanges(repoPath string) (bool, error)
+	Add(repoPath string) error
+	StagePath(repoPath, path string) error
+	Untrack(repoPath string, paths ...string) error
+	Commit(repoPath, message string) error
+
+	GetRemoteURL(repoPath, remote string) (string, error)
+	AddRemote(repoPath, remote, url string) error
+	ChangeRemote(repoPath, remote, url string) error
+	Fetch(repoPath, remote string) error
+	Push(repoPath, remote, branch string, upstream bool) error
+	Pull(repoPath, remote, branch, strategy string, autostash bool) error
+	RemoteBranchExists(repoPath, remote, branch string) (bool, error)
+	ListRemote(repoPath, remote string) (RemoteRefs, error)
+
+	CurrentBranch(repoPath string) (string, error)
+	LocalBranchExists(repoPath, branch string) bool
+	FirstCheckout(repoPath, remote, branch string) error
+	Checkout(repoPath, branch string) error
+	CreateBranch(repoPath, branch string) error
+	RenameBranch(repoPath, branch string) error
+	CheckoutPaths(repoPath, rev string, paths ...string) error
+	Merge(repoPath, branch string) error
+
+	RevParse(repoPath, rev string) (string, error)
+	ShowFile(repoPath, rev, path string) (string, error)
+	ListFiles(repoPath, rev string) ([]string, error)
+	ChangedFiles(repoPath, from, to string) ([]string, error)
+	Log(repoPath, from, to string) ([]Commit, error)
+	MergeBase(repoPath, a, b string) (string, error)
+
+	InProgress(repoPath string) (string, error)
+	ConflictedFiles(repoPath string) ([]string, error)
+	ShowStage(repoPath string, stage int, path string) (string, bool, error)
+	MergeContinue(repoPath string) error
+	MergeAbort(repoPath string) error
+	RebaseContinue(repoPath string) error
+	RebaseAbort(repoPath string) error
 }
 
-func Push(repoPath string) (int, error) {
-	return internal.Run("git", "-C", repoPath, "push")
+// New returns the backend with the given name, an empty name selects the git binary
+func New(name string) (Backend, error) {
+	switch name {
+	case "", BackendExec:
+		return ExecBackend{}, nil
+	case BackendGoGit:
+		return GoGitBackend{}, nil
+	}
+	return nil, fmt.Errorf("unknown git backend %q, use %v or %v", name, BackendExec, BackendGoGit)
 }
 
-func Pull(repoPath string) (int, error) {
-	return internal.Run("git", "-C", repoPath, "pull", "--ff-only")
+func ValidStrategy(strategy string) bool {
+	switch strategy {
+	case StrategyFFOnly, StrategyRebase, StrategyMerge:
+		return true
+	}
+	return false
 }
 
-func Init(repoPath string) (int, error) {
-	return internal.Run("git", "-C", repoPath, "init")
+func ListChanges(input string) []string {
+	lines := strings.Split(input, "\n")
+	sliceLines := []string{}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sliceLines = append(sliceLines, line)
+	}
+	return sliceLines
 }
@@ -0,0 +1,260 @@
+// Package gittest provides a git backend for tests that records calls instead of touching a repository
+package gittest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ZonCen/dotman/internal/git"
+)
+
+// Fake is an in-memory git.Backend. Every call is recorded as "Method arg..." and Errors, keyed by
+// method name or by method name and one of its arguments ("Push backup"), makes that call fail.
+// The remaining fields are returned by the matching queries
+type Fake struct {
+	mu sync.Mutex
+
+	Calls  []string
+	Errors map[string]error
+	// OnCall, when set, runs before every call returns, for example to simulate an interrupt
+	OnCall func(method string)
+
+	NotRepo       bool
+	StatusOutput  string
+	Staged        bool
+	RemoteURL     string
+	RemoteURLs    map[string]string
+	Branch        string
+	Branches      map[string]bool
+	RemoteMissing bool
+	Remote        git.RemoteRefs
+	Revisions     map[string]string
+	Files         map[string]string
+	Tracked       []string
+	Changed       []string
+	Logs          map[string][]git.Commit
+	Base          string
+	Operation     string
+	Conflicts     []string
+	Stages        map[string]string
+}
+
+var _ git.Backend = (*Fake)(nil)
+
+// NewFake returns a fake on main with a staged change, so a sync commits, pulls and pushes
+func NewFake() *Fake {
+	return &Fake{Branch: "main", Staged: true, RemoteURL: "git@example.com:user/dotfiles.git"}
+}
+
+func (f *Fake) record(method string, args ...string) error {
+	f.mu.Lock()
+	f.Calls = append(f.Calls, strings.TrimSpace(method+" "+strings.Join(args, " ")))
+	err := f.Errors[method]
+	for _, arg := range args {
+		if argErr, ok := f.Errors[method+" "+arg]; ok {
+			err = argErr
+		}
+	}
+	f.mu.Unlock()
+
+	if f.OnCall != nil {
+		f.OnCall(method)
+	}
+	return err
+}
+
+// Methods returns the recorded calls without their arguments
+func (f *Fake) Methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	methods := make([]string, len(f.Calls))
+	for i, call := range f.Calls {
+		methods[i] = strings.Fields(call)[0]
+	}
+	return methods
+}
+
+func (f *Fake) IsRepo(repoPath string) (bool, error) {
+	return !f.NotRepo, f.record("IsRepo", repoPath)
+}
+
+func (f *Fake) Init(repoPath string) error {
+	return f.record("Init", repoPath)
+}
+
+func (f *Fake) Status(repoPath string) (string, error) {
+	return f.StatusOutput, f.record("Status", repoPath)
+}
+
+func (f *Fake) HasStagedChanges(repoPath string) (bool, error) {
+	return f.Staged, f.record("HasStagedChanges", repoPath)
+}
+
+func (f *Fake) Add(repoPath string) error {
+	return f.record("Add", repoPath)
+}
+
+func (f *Fake) StagePath(repoPath, path string) error {
+	return f.record("StagePath", repoPath, path)
+}
+
+// Untrack drops paths from Tracked
+func (f *Fake) Untrack(repoPath string, paths ...string) error {
+	f.mu.Lock()
+	f.Tracked = slices.DeleteFunc(f.Tracked, func(path string) bool { return slices.Contains(paths, path) })
+	f.mu.Unlock()
+	return f.record("Untrack", append([]string{repoPath}, paths...)...)
+}
+
+func (f *Fake) Commit(repoPath, message string) error {
+	return f.record("Commit", repoPath, message)
+}
+
+// GetRemoteURL returns RemoteURLs[remote], or RemoteURL for remotes not in the map
+func (f *Fake) GetRemoteURL(repoPath, remote string) (string, error) {
+	err := f.record("GetRemoteURL", repoPath, remote)
+	if url, ok := f.RemoteURLs[remote]; ok {
+		return url, err
+	}
+	return f.RemoteURL, err
+}
+
+func (f *Fake) AddRemote(repoPath, remote, url string) error {
+	return f.record("AddRemote", repoPath, remote, url)
+}
+
+func (f *Fake) ChangeRemote(repoPath, remote, url string) error {
+	return f.record("ChangeRemote", repoPath, remote, url)
+}
+
+func (f *Fake) Fetch(repoPath, remote string) error {
+	return f.record("Fetch", repoPath, remote)
+}
+
+func (f *Fake) Push(repoPath, remote, branch string, upstream bool) error {
+	return f.record("Push", repoPath, remote, branch, fmt.Sprint(upstream))
+}
+
+func (f *Fake) Pull(repoPath, remote, branch, strategy string, autostash bool) error {
+	return f.record("Pull", repoPath, remote, branch, strategy, fmt.Sprint(autostash))
+}
+
+func (f *Fake) RemoteBranchExists(repoPath, remote, branch string) (bool, error) {
+	return !f.RemoteMissing, f.record("RemoteBranchExists", repoPath, remote, branch)
+}
+
+// ListRemote returns Remote
+func (f *Fake) ListRemote(repoPath, remote string) (git.RemoteRefs, error) {
+	return f.Remote, f.record("ListRemote", repoPath, remote)
+}
+
+func (f *Fake) CurrentBranch(repoPath string) (string, error) {
+	return f.Branch, f.record("CurrentBranch", repoPath)
+}
+
+func (f *Fake) LocalBranchExists(repoPath, branch string) bool {
+	_ = f.record("LocalBranchExists", repoPath, branch)
+	return branch == f.Branch || f.Branches[branch]
+}
+
+func (f *Fake) FirstCheckout(repoPath, remote, branch string) error {
+	return f.record("FirstCheckout", repoPath, remote, branch)
+}
+
+func (f *Fake) Checkout(repoPath, branch string) error {
+	return f.record("Checkout", repoPath, branch)
+}
+
+func (f *Fake) CreateBranch(repoPath, branch string) error {
+	return f.record("CreateBranch", repoPath, branch)
+}
+
+func (f *Fake) RenameBranch(repoPath, branch string) error {
+	return f.record("RenameBranch", repoPath, branch)
+}
+
+func (f *Fake) CheckoutPaths(repoPath, rev string, paths ...string) error {
+	return f.record("CheckoutPaths", append([]string{repoPath, rev}, paths...)...)
+}
+
+func (f *Fake) Merge(repoPath, branch string) error {
+	return f.record("Merge", repoPath, branch)
+}
+
+// RevParse returns the configured revision, or the revision name itself
+func (f *Fake) RevParse(repoPath, rev string) (string, error) {
+	err := f.record("RevParse", repoPath, rev)
+	if hash, ok := f.Revisions[rev]; ok {
+		return hash, err
+	}
+	return rev, err
+}
+
+// ShowFile returns Files["rev:path"]
+func (f *Fake) ShowFile(repoPath, rev, path string) (string, error) {
+	err := f.record("ShowFile", repoPath, rev, path)
+	if err != nil {
+		return "", err
+	}
+	content, ok := f.Files[rev+":"+path]
+	if !ok {
+		return "", fmt.Errorf("%v does not exist in %v", path, rev)
+	}
+	return content, nil
+}
+
+// ListFiles returns Tracked
+func (f *Fake) ListFiles(repoPath, rev string) ([]string, error) {
+	return f.Tracked, f.record("ListFiles", repoPath, rev)
+}
+
+func (f *Fake) ChangedFiles(repoPath, from, to string) ([]string, error) {
+	return f.Changed, f.record("ChangedFiles", repoPath, from, to)
+}
+
+// Log returns Logs["from..to"]
+func (f *Fake) Log(repoPath, from, to string) ([]git.Commit, error) {
+	return f.Logs[from+".."+to], f.record("Log", repoPath, from, to)
+}
+
+// MergeBase returns Base, or a when Base is empty
+func (f *Fake) MergeBase(repoPath, a, b string) (string, error) {
+	err := f.record("MergeBase", repoPath, a, b)
+	if f.Base == "" {
+		return a, err
+	}
+	return f.Base, err
+}
+
+func (f *Fake) InProgress(repoPath string) (string, error) {
+	return f.Operation, f.record("InProgress", repoPath)
+}
+
+func (f *Fake) ConflictedFiles(repoPath string) ([]string, error) {
+	return f.Conflicts, f.record("ConflictedFiles", repoPath)
+}
+
+// ShowStage returns Stages["stage:path"]
+func (f *Fake) ShowStage(repoPath string, stage int, path string) (string, bool, error) {
+	err := f.record("ShowStage", repoPath, fmt.Sprint(stage), path)
+	content, ok := f.Stages[fmt.Sprintf("%d:%s", stage, path)]
+	return content, ok, err
+}
+
+func (f *Fake) MergeContinue(repoPath string) error {
+	return f.record("MergeContinue", repoPath)
+}
+
+func (f *Fake) MergeAbort(repoPath string) error {
+	return f.record("MergeAbort", repoPath)
+}
+
+func (f *Fake) RebaseContinue(repoPath string) error {
+	return f.record("RebaseContinue", repoPath)
+}
+
+func (f *Fake) RebaseAbort(repoPath string) error {
+	return f.record("RebaseAbort", repoPath)
+}
@@ -0,0 +1,864 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// GoGitBackend implements the backend in pure Go, so dotman works on machines without the git binary.
+// It can only fast-forward, pulls with another strategy and continuing or aborting merges are unsupported
+type GoGitBackend struct{}
+
+func open(repoPath string) (*gogit.Repository, error) {
+	repo, err := gogit.PlainOpenWithOptions(repoPath, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository %v: %w", repoPath, err)
+	}
+	return repo, nil
+}
+
+func openWorktree(repoPath string) (*gogit.Repository, *gogit.Worktree, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open worktree: %w", err)
+	}
+	return repo, worktree, nil
+}
+
+// network runs a remote operation with the network timeout, retrying transient failures
+func network(op func(ctx context.Context) error) error {
+	return internal.Retry(func() error {
+		ctx, cancel := internal.NetworkContext()
+		defer cancel()
+		err := op(ctx)
+		if errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+			return nil
+		}
+		return err
+	})
+}
+
+func unsupported(operation string) error {
+	return fmt.Errorf("%v: %w", operation, ErrUnsupported)
+}
+
+func (GoGitBackend) IsRepo(repoPath string) (bool, error) {
+	_, err := gogit.PlainOpenWithOptions(repoPath, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if errors.Is(err, gogit.ErrRepositoryNotExists) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open repository %v: %w", repoPath, err)
+	}
+	return true, nil
+}
+
+func (GoGitBackend) Init(repoPath string) error {
+	if _, err := gogit.PlainInit(repoPath, false); err != nil {
+		return fmt.Errorf("failed to initialize %v: %w", repoPath, err)
+	}
+	return nil
+}
+
+// Status returns the short status of the worktree, in the same two column format as git status --porcelain
+func (GoGitBackend) Status(repoPath string) (string, error) {
+	_, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return "", err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return "", fmt.Errorf("failed to collect status: %w", err)
+	}
+	return status.String(), nil
+}
+
+func (GoGitBackend) HasStagedChanges(repoPath string) (bool, error) {
+	_, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return false, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, fmt.Errorf("failed to collect status: %w", err)
+	}
+	for _, file := range status {
+		if file.Staging != gogit.Unmodified && file.Staging != gogit.Untracked {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (GoGitBackend) Add(repoPath string) error {
+	_, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	if err := worktree.AddWithOptions(&gogit.AddOptions{All: true}); err != nil {
+		return fmt.Errorf("failed to stage changes: %w", err)
+	}
+	return nil
+}
+
+// StagePath stages the current state of path, including its deletion
+func (GoGitBackend) StagePath(repoPath, path string) error {
+	_, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(filepath.Join(repoPath, path)); os.IsNotExist(err) {
+		_, err = worktree.Remove(path)
+		if err != nil {
+			return fmt.Errorf("failed to stage removal of %v: %w", path, err)
+		}
+		return nil
+	}
+	if err := worktree.AddWithOptions(&gogit.AddOptions{Path: path}); err != nil {
+		return fmt.Errorf("failed to stage %v: %w", path, err)
+	}
+	return nil
+}
+
+// Untrack removes paths from the index and keeps them in the work tree
+func (GoGitBackend) Untrack(repoPath string, paths ...string) error {
+	repo, err := open(repoPath)
+	if err != nil {
+		return err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("failed to read the index: %w", err)
+	}
+	for _, path := range paths {
+		if _, err := idx.Remove(path); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+			return fmt.Errorf("failed to untrack %v: %w", path, err)
+		}
+	}
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	return nil
+}
+
+func (GoGitBackend) Commit(repoPath, message string) error {
+	repo, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	if _, err := worktree.Commit(message, &gogit.CommitOptions{Author: signature(repo)}); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// signature takes the author from the git environment variables or config, falling back to dotman
+func signature(repo *gogit.Repository) *object.Signature {
+	sig := &object.Signature{
+		Name:  os.Getenv("GIT_AUTHOR_NAME"),
+		Email: os.Getenv("GIT_AUTHOR_EMAIL"),
+		When:  time.Now(),
+	}
+	if cfg, err := repo.ConfigScoped(gitconfig.GlobalScope); err == nil {
+		if sig.Name == "" {
+			sig.Name = cfg.User.Name
+		}
+		if sig.Email == "" {
+			sig.Email = cfg.User.Email
+		}
+	}
+	if sig.Name == "" {
+		sig.Name = "dotman"
+	}
+	if sig.Email == "" {
+		sig.Email = "dotman@" + internal.MachineName()
+	}
+	return sig
+}
+
+func (GoGitBackend) GetRemoteURL(repoPath, name string) (string, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return "", err
+	}
+	remote, err := repo.Remote(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote URL %w", err)
+	}
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("%v has no URL", name)
+	}
+	return urls[0], nil
+}
+
+func (GoGitBackend) AddRemote(repoPath, name, url string) error {
+	repo, err := open(repoPath)
+	if err != nil {
+		return err
+	}
+	if _, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: name, URLs: []string{url}}); err != nil {
+		return fmt.Errorf("failed to add %v: %w", name, err)
+	}
+	return nil
+}
+
+func (GoGitBackend) ChangeRemote(repoPath, name, url string) error {
+	repo, err := open(repoPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read repository config: %w", err)
+	}
+	remote, ok := cfg.Remotes[name]
+	if !ok {
+		return fmt.Errorf("no remote named %v", name)
+	}
+	remote.URLs = []string{url}
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to change %v: %w", name, err)
+	}
+	return nil
+}
+
+func (GoGitBackend) Fetch(repoPath, remote string) error {
+	repo, err := open(repoPath)
+	if err != nil {
+		return err
+	}
+	err = network(func(ctx context.Context) error {
+		return repo.FetchContext(ctx, &gogit.FetchOptions{RemoteName: remote})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch %v: %w", remote, err)
+	}
+	return nil
+}
+
+// Push pushes branch to remote, optionally making it the upstream. An empty branch pushes the current one
+func (b GoGitBackend) Push(repoPath, remote, branch string, upstream bool) error {
+	repo, err := open(repoPath)
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		if branch, err = b.CurrentBranch(repoPath); err != nil {
+			return err
+		}
+	}
+
+	ref := plumbing.NewBranchReferenceName(branch)
+	err = network(func(ctx context.Context) error {
+		return repo.PushContext(ctx, &gogit.PushOptions{
+			RemoteName: remote,
+			RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(ref + ":" + ref)},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to push %v to %v: %w", branch, remote, err)
+	}
+	if !upstream {
+		return nil
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read repository config: %w", err)
+	}
+	cfg.Branches[branch] = &gitconfig.Branch{Name: branch, Remote: remote, Merge: ref}
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to set upstream of %v: %w", branch, err)
+	}
+	return nil
+}
+
+// Pull fast-forwards branch from remote, an empty branch pulls the current one
+func (b GoGitBackend) Pull(repoPath, remote, branch, strategy string, autostash bool) error {
+	if strategy != "" && strategy != StrategyFFOnly {
+		return unsupported("pull with strategy " + strategy)
+	}
+	if autostash {
+		return unsupported("pull with autostash")
+	}
+	_, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		if branch, err = b.CurrentBranch(repoPath); err != nil {
+			return err
+		}
+	}
+
+	err = network(func(ctx context.Context) error {
+		return worktree.PullContext(ctx, &gogit.PullOptions{
+			RemoteName:    remote,
+			ReferenceName: plumbing.NewBranchReferenceName(branch),
+			SingleBranch:  true,
+		})
+	})
+	if errors.Is(err, gogit.ErrNonFastForwardUpdate) {
+		// Worded like git so the failure is explained the same way for both backends
+		return fmt.Errorf("failed to pull %v: not possible to fast-forward: %w", branch, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pull %v: %w", branch, err)
+	}
+	return nil
+}
+
+func (GoGitBackend) RemoteBranchExists(repoPath, name, branch string) (bool, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return false, err
+	}
+	remote, err := repo.Remote(name)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %v: %w", name, err)
+	}
+	var refs []*plumbing.Reference
+	err = network(func(ctx context.Context) error {
+		refs, err = remote.ListContext(ctx, &gogit.ListOptions{})
+		return err
+	})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %v on %v: %w", branch, name, err)
+	}
+	want := plumbing.NewBranchReferenceName(branch)
+	for _, ref := range refs {
+		if ref.Name() == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListRemote asks remote for its branches and default branch
+func (GoGitBackend) ListRemote(repoPath, name string) (RemoteRefs, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return RemoteRefs{}, err
+	}
+	remote, err := repo.Remote(name)
+	if err != nil {
+		return RemoteRefs{}, fmt.Errorf("failed to look up %v: %w", name, err)
+	}
+	var listed []*plumbing.Reference
+	err = network(func(ctx context.Context) error {
+		listed, err = remote.ListContext(ctx, &gogit.ListOptions{})
+		return err
+	})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return RemoteRefs{}, nil
+	}
+	if err != nil {
+		return RemoteRefs{}, fmt.Errorf("failed to list %v: %w", name, err)
+	}
+
+	refs := RemoteRefs{}
+	for _, ref := range listed {
+		switch {
+		case ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference:
+			refs.Head = ref.Target().Short()
+		case ref.Name().IsBranch():
+			refs.Branches = append(refs.Branches, ref.Name().Short())
+		}
+	}
+	return refs, nil
+}
+
+// CurrentBranch returns the checked out branch, or HEAD when detached
+func (GoGitBackend) CurrentBranch(repoPath string) (string, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	if head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		return head.Target().Short(), nil
+	}
+	return "HEAD", nil
+}
+
+func (GoGitBackend) LocalBranchExists(repoPath, branch string) bool {
+	repo, err := open(repoPath)
+	if err != nil {
+		return false
+	}
+	_, err = repo.Reference(plumbing.NewBranchReferenceName(branch), false)
+	return err == nil
+}
+
+// FirstCheckout creates a local branch tracking the same branch on remote
+func (GoGitBackend) FirstCheckout(repoPath, remote, branch string) error {
+	repo, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	remoteRef, err := repo.Reference(plumbing.NewRemoteReferenceName(remote, branch), true)
+	if err != nil {
+		return fmt.Errorf("branch %v not found on %v: %w", branch, remote, err)
+	}
+
+	ref := plumbing.NewBranchReferenceName(branch)
+	err = worktree.Checkout(&gogit.CheckoutOptions{Branch: ref, Hash: remoteRef.Hash(), Create: true})
+	if err != nil {
+		return fmt.Errorf("failed to checkout %v: %w", branch, err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read repository config: %w", err)
+	}
+	cfg.Branches[branch] = &gitconfig.Branch{Name: branch, Remote: remote, Merge: ref}
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to set upstream of %v: %w", branch, err)
+	}
+	return nil
+}
+
+func (GoGitBackend) Checkout(repoPath, branch string) error {
+	_, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	if err := worktree.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch)}); err != nil {
+		return fmt.Errorf("failed to checkout %v: %w", branch, err)
+	}
+	return nil
+}
+
+func (GoGitBackend) CreateBranch(repoPath, branch string) error {
+	repo, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	err = worktree.Checkout(&gogit.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Hash:   head.Hash(),
+		Create: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %v: %w", branch, err)
+	}
+	return nil
+}
+
+// RenameBranch renames the current branch, also when it has no commits yet
+func (b GoGitBackend) RenameBranch(repoPath, branch string) error {
+	repo, err := open(repoPath)
+	if err != nil {
+		return err
+	}
+	current, err := b.CurrentBranch(repoPath)
+	if err != nil {
+		return err
+	}
+	if current == "HEAD" {
+		return fmt.Errorf("no branch is checked out to rename")
+	}
+	if current == branch {
+		return nil
+	}
+
+	oldRef := plumbing.NewBranchReferenceName(current)
+	newRef := plumbing.NewBranchReferenceName(branch)
+	if head, err := repo.Reference(oldRef, false); err == nil {
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(newRef, head.Hash())); err != nil {
+			return fmt.Errorf("failed to create %v: %w", branch, err)
+		}
+		if err := repo.Storer.RemoveReference(oldRef); err != nil {
+			return fmt.Errorf("failed to remove %v: %w", current, err)
+		}
+	}
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, newRef)); err != nil {
+		return fmt.Errorf("failed to rename %v to %v: %w", current, branch, err)
+	}
+	return nil
+}
+
+// CheckoutPaths takes paths, files or directories, from rev into the working tree and index
+func (GoGitBackend) CheckoutPaths(repoPath, rev string, paths ...string) error {
+	repo, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to read tree of %v: %w", rev, err)
+	}
+
+	for _, path := range paths {
+		found := false
+		err := tree.Files().ForEach(func(file *object.File) error {
+			if file.Name != path && !strings.HasPrefix(file.Name, path+"/") {
+				return nil
+			}
+			found = true
+			return writeFile(repoPath, file)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to take %v from %v: %w", path, rev, err)
+		}
+		if !found {
+			return fmt.Errorf("%v does not exist in %v", path, rev)
+		}
+		if err := worktree.AddWithOptions(&gogit.AddOptions{Path: path}); err != nil {
+			return fmt.Errorf("failed to stage %v: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func writeFile(repoPath string, file *object.File) error {
+	content, err := file.Contents()
+	if err != nil {
+		return err
+	}
+	mode, err := file.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(repoPath, filepath.FromSlash(file.Name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, []byte(content), mode.Perm())
+}
+
+// Merge fast-forwards the current branch to branch, anything else needs the exec backend
+func (GoGitBackend) Merge(repoPath, branch string) error {
+	repo, worktree, err := openWorktree(repoPath)
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	current, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	other, err := resolveCommit(repo, branch)
+	if err != nil {
+		return err
+	}
+
+	if ok, err := other.IsAncestor(current); err != nil || ok {
+		return err
+	}
+	if ok, err := current.IsAncestor(other); err != nil {
+		return fmt.Errorf("failed to compare with %v: %w", branch, err)
+	} else if !ok {
+		return unsupported("merge of diverged branch " + branch)
+	}
+
+	if err := worktree.Reset(&gogit.ResetOptions{Commit: other.Hash, Mode: gogit.MergeReset}); err != nil {
+		return fmt.Errorf("failed to fast-forward to %v: %w", branch, err)
+	}
+	return nil
+}
+
+func resolveCommit(repo *gogit.Repository, rev string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %v: %w", rev, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %w", rev, err)
+	}
+	return commit, nil
+}
+
+func (GoGitBackend) RevParse(repoPath, rev string) (string, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %v: %w", rev, err)
+	}
+	return hash.String(), nil
+}
+
+func (GoGitBackend) ShowFile(repoPath, rev, path string) (string, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return "", err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return "", err
+	}
+	file, err := commit.File(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %v at %v: %w", path, rev, err)
+	}
+	return file.Contents()
+}
+
+func (GoGitBackend) ListFiles(repoPath, rev string) ([]string, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files at %v: %w", rev, err)
+	}
+	paths := []string{}
+	err = files.ForEach(func(file *object.File) error {
+		if file.Mode == filemode.Regular || file.Mode == filemode.Executable {
+			paths = append(paths, file.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files at %v: %w", rev, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (GoGitBackend) ChangedFiles(repoPath, from, to string) ([]string, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	trees := make([]*object.Tree, 2)
+	for i, rev := range []string{from, to} {
+		commit, err := resolveCommit(repo, rev)
+		if err != nil {
+			return nil, err
+		}
+		if trees[i], err = commit.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to read tree of %v: %w", rev, err)
+		}
+	}
+
+	changes, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	seen := map[string]bool{}
+	paths := []string{}
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				paths = append(paths, name)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Log lists the commits reachable from to but not from, newest first. An empty from lists all of them
+func (GoGitBackend) Log(repoPath, from, to string) ([]Commit, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := map[plumbing.Hash]bool{}
+	if from != "" {
+		start, err := resolveCommit(repo, from)
+		if err != nil {
+			return nil, err
+		}
+		iter := object.NewCommitPreorderIter(start, nil, nil)
+		err = iter.ForEach(func(commit *object.Commit) error {
+			exclude[commit.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %v: %w", from, err)
+		}
+	}
+
+	end, err := resolveCommit(repo, to)
+	if err != nil {
+		return nil, err
+	}
+	commits := []Commit{}
+	iter := object.NewCommitIterCTime(end, nil, nil)
+	err = iter.ForEach(func(commit *object.Commit) error {
+		if !exclude[commit.Hash] {
+			subject, _, _ := strings.Cut(commit.Message, "\n")
+			commits = append(commits, Commit{Hash: commit.Hash.String(), Author: commit.Author.Name, Subject: subject})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits of %v: %w", to, err)
+	}
+	return commits, nil
+}
+
+func (GoGitBackend) MergeBase(repoPath, a, b string) (string, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return "", err
+	}
+	first, err := resolveCommit(repo, a)
+	if err != nil {
+		return "", err
+	}
+	second, err := resolveCommit(repo, b)
+	if err != nil {
+		return "", err
+	}
+	bases, err := first.MergeBase(second)
+	if err != nil || len(bases) == 0 {
+		return "", fmt.Errorf("failed to find the merge base of %v and %v: %v", a, b, err)
+	}
+	return bases[0].Hash.String(), nil
+}
+
+// InProgress reports which operation, if any, another git client left waiting to be continued or aborted
+func (GoGitBackend) InProgress(repoPath string) (string, error) {
+	if _, err := open(repoPath); err != nil {
+		return OperationNone, err
+	}
+	gitDir := filepath.Join(repoPath, ".git")
+	for _, check := range []struct {
+		path      string
+		operation string
+	}{
+		{"rebase-merge", OperationRebase},
+		{"rebase-apply", OperationRebase},
+		{"MERGE_HEAD", OperationMerge},
+	} {
+		if internal.FileExist(filepath.Join(gitDir, check.path)) {
+			return check.operation, nil
+		}
+	}
+	return OperationNone, nil
+}
+
+func unmergedEntries(repoPath string) ([]*index.Entry, error) {
+	repo, err := open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	entries := []*index.Entry{}
+	for _, entry := range idx.Entries {
+		// Merged entries decode as stage 0, index.Merged shares its value with AncestorMode
+		if entry.Stage != 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (GoGitBackend) ConflictedFiles(repoPath string) ([]string, error) {
+	entries, err := unmergedEntries(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conflicts: %w", err)
+	}
+	seen := map[string]bool{}
+	paths := []string{}
+	for _, entry := range entries {
+		if !seen[entry.Name] {
+			seen[entry.Name] = true
+			paths = append(paths, entry.Name)
+		}
+	}
+	return paths, nil
+}
+
+func (GoGitBackend) ShowStage(repoPath string, stage int, path string) (string, bool, error) {
+	entries, err := unmergedEntries(repoPath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to list stages for %v: %w", path, err)
+	}
+	for _, entry := range entries {
+		if entry.Name != path || int(entry.Stage) != stage {
+			continue
+		}
+		repo, err := open(repoPath)
+		if err != nil {
+			return "", false, err
+		}
+		blob, err := repo.BlobObject(entry.Hash)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read stage %d of %v: %w", stage, path, err)
+		}
+		reader, err := blob.Reader()
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read stage %d of %v: %w", stage, path, err)
+		}
+		defer reader.Close()
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read stage %d of %v: %w", stage, path, err)
+		}
+		return string(content), true, nil
+	}
+	return "", false, nil
+}
+
+func (GoGitBackend) MergeContinue(string) error {
+	return unsupported("merge --continue")
+}
+
+func (GoGitBackend) MergeAbort(string) error {
+	return unsupported("merge --abort")
+}
+
+func (GoGitBackend) RebaseContinue(string) error {
+	return unsupported("rebase --continue")
+}
+
+func (GoGitBackend) RebaseAbort(string) error {
+	return unsupported("rebase --abort")
+}
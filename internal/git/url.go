@@ -0,0 +1,151 @@
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Transports a remote URL can use
+const (
+	TransportSSH   = "ssh"
+	TransportHTTPS = "https"
+	TransportHTTP  = "http"
+	TransportGit   = "git"
+	TransportFile  = "file"
+)
+
+// URL is a parsed remote URL in any of the forms git accepts: scp-style (user@host:path), ssh://, https://,
+// http://, git://, file:// or a local path
+type URL struct {
+	Transport string
+	User      string
+	Host      string
+	Port      string
+	// Path is relative to the host for network transports and a filesystem path for file
+	Path string
+	// SCP is set for the user@host:path form, Local for a plain filesystem path without file://
+	SCP   bool
+	Local bool
+}
+
+// ParseURL parses a remote URL the way git interprets it
+func ParseURL(raw string) (*URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, fmt.Errorf("empty repository URL")
+	}
+
+	if strings.Contains(raw, "://") {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository URL %q: %w", raw, err)
+		}
+		u := &URL{Transport: strings.ToLower(parsed.Scheme), Host: parsed.Hostname(), Port: parsed.Port()}
+		switch u.Transport {
+		case "ssh", "git+ssh", "ssh+git":
+			u.Transport = TransportSSH
+		case TransportHTTPS, TransportHTTP, TransportGit:
+		case TransportFile:
+			u.Path = filepath.Clean(parsed.Path)
+			return u, nil
+		default:
+			return nil, fmt.Errorf("unsupported transport %q in %q", parsed.Scheme, raw)
+		}
+		if parsed.User != nil {
+			u.User = parsed.User.Username()
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("repository URL %q has no host", raw)
+		}
+		u.Path = strings.TrimPrefix(parsed.Path, "/")
+		return u, nil
+	}
+
+	// git treats anything with a colon before the first slash as scp-style
+	if colon := strings.Index(raw, ":"); colon > 0 && !strings.Contains(raw[:colon], "/") {
+		host, path := raw[:colon], raw[colon+1:]
+		u := &URL{Transport: TransportSSH, Host: host, Path: strings.TrimPrefix(path, "/"), SCP: true}
+		if user, rest, ok := strings.Cut(host, "@"); ok {
+			u.User, u.Host = user, rest
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("repository URL %q has no host", raw)
+		}
+		return u, nil
+	}
+
+	return &URL{Transport: TransportFile, Path: filepath.Clean(raw), Local: true}, nil
+}
+
+// String formats the URL in the form it was parsed from
+func (u *URL) String() string {
+	if u.Transport == TransportFile {
+		if u.Local {
+			return u.Path
+		}
+		return "file://" + filepath.ToSlash(u.Path)
+	}
+	if u.SCP {
+		host := u.Host
+		if u.User != "" {
+			host = u.User + "@" + host
+		}
+		return host + ":" + u.Path
+	}
+
+	host := u.Host
+	if u.Port != "" {
+		host += ":" + u.Port
+	}
+	if u.User != "" {
+		host = u.User + "@" + host
+	}
+	return u.Transport + "://" + host + "/" + u.Path
+}
+
+// Identity names the repository independently of transport, user, port and a trailing .git
+func (u *URL) Identity() string {
+	if u.Transport == TransportFile {
+		path := u.Path
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		return strings.TrimSuffix(path, ".git")
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return strings.ToLower(u.Host) + "/" + path
+}
+
+// WithTransport returns the same repository reached over ssh or https, keeping the .git suffix as it was.
+// SSH URLs use the scp-style form with the git user, which every common host accepts
+func (u *URL) WithTransport(transport string) (*URL, error) {
+	if u.Transport == TransportFile {
+		return nil, fmt.Errorf("%v is a local repository, it has no ssh or https URL", u)
+	}
+	switch transport {
+	case TransportSSH:
+		return &URL{Transport: TransportSSH, User: "git", Host: u.Host, Path: u.Path, SCP: true}, nil
+	case TransportHTTPS:
+		return &URL{Transport: TransportHTTPS, Host: u.Host, Path: u.Path}, nil
+	}
+	return nil, fmt.Errorf("unknown transport %q, use %v or %v", transport, TransportSSH, TransportHTTPS)
+}
+
+// SameRepository reports whether two remote URLs point at the same repository, URLs that can not be
+// parsed only match themselves
+func SameRepository(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ua, err := ParseURL(a)
+	if err != nil {
+		return false
+	}
+	ub, err := ParseURL(b)
+	if err != nil {
+		return false
+	}
+	return ua.Identity() == ub.Identity()
+}
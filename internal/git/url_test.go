@@ -0,0 +1,114 @@
+package git
+
+import "testing"
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    URL
+		wantErr bool
+	}{
+		{"scp style", "git@github.com:user/repo.git",
+			URL{Transport: TransportSSH, User: "git", Host: "github.com", Path: "user/repo.git", SCP: true}, false},
+		{"scp style without user", "gitea.local:team/dotfiles",
+			URL{Transport: TransportSSH, Host: "gitea.local", Path: "team/dotfiles", SCP: true}, false},
+		{"ssh with port", "ssh://git@gitea.example.com:2222/me/dotfiles.git",
+			URL{Transport: TransportSSH, User: "git", Host: "gitea.example.com", Port: "2222", Path: "me/dotfiles.git"},
+			false},
+		{"https", "https://gitlab.com/group/sub/repo.git",
+			URL{Transport: TransportHTTPS, Host: "gitlab.com", Path: "group/sub/repo.git"}, false},
+		{"https with user", "https://me@git.example.com/me/dotfiles",
+			URL{Transport: TransportHTTPS, User: "me", Host: "git.example.com", Path: "me/dotfiles"}, false},
+		{"file", "file:///srv/git/dotfiles.git",
+			URL{Transport: TransportFile, Path: "/srv/git/dotfiles.git"}, false},
+		{"local path", "/srv/git/dotfiles.git",
+			URL{Transport: TransportFile, Path: "/srv/git/dotfiles.git", Local: true}, false},
+		{"relative path with colon after slash", "./backups/a:b.git",
+			URL{Transport: TransportFile, Path: "backups/a:b.git", Local: true}, false},
+		{"unknown transport", "ftp://example.com/repo.git", URL{}, true},
+		{"empty", "", URL{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseURL(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *got != tt.want {
+				t.Errorf("ParseURL() = %+v, want %+v", *got, tt.want)
+			}
+			if !tt.want.Local || tt.input == got.Path {
+				if got.String() != tt.input {
+					t.Errorf("String() = %v, want %v", got.String(), tt.input)
+				}
+			}
+		})
+	}
+}
+
+func TestSameRepository(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"ssh and https", "git@github.com:user/repo.git", "https://github.com/user/repo.git", true},
+		{"without .git suffix", "https://github.com/user/repo", "git@github.com:user/repo.git", true},
+		{"ssh port and https", "ssh://git@gitea.example.com:2222/me/dotfiles.git",
+			"https://gitea.example.com/me/dotfiles", true},
+		{"host case", "git@GitLab.com:group/repo.git", "https://gitlab.com/group/repo.git", true},
+		{"file and local path", "file:///srv/dotfiles.git", "/srv/dotfiles.git", true},
+		{"different repository same host", "https://github.com/user/repo.git", "https://github.com/user/other.git",
+			false},
+		{"same path on another host", "git@github.com:user/repo.git", "git@gitlab.com:user/repo.git", false},
+		{"remote and local", "git@github.com:user/repo.git", "/user/repo.git", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SameRepository(tt.a, tt.b); got != tt.want {
+				t.Errorf("SameRepository(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		transport string
+		want      string
+		wantErr   bool
+	}{
+		{"SSH to HTTPS", "git@github.com:user/repo.git", TransportHTTPS, "https://github.com/user/repo.git", false},
+		{"HTTPS to SSH", "https://github.com/user/repo.git", TransportSSH, "git@github.com:user/repo.git", false},
+		{"any host", "https://gitlab.example.com/group/sub/repo", TransportSSH,
+			"git@gitlab.example.com:group/sub/repo", false},
+		{"ssh port is dropped", "ssh://git@gitea.example.com:2222/me/dotfiles.git", TransportHTTPS,
+			"https://gitea.example.com/me/dotfiles.git", false},
+		{"local repository", "/srv/dotfiles.git", TransportSSH, "", true},
+		{"unknown transport", "git@github.com:user/repo.git", "ftp", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseURL(tt.input)
+			if err != nil {
+				t.Fatalf("ParseURL() error = %v", err)
+			}
+			got, err := parsed.WithTransport(tt.transport)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("WithTransport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package internal
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
@@ -41,67 +40,26 @@ func CreateSymlink(symPath, filePath string) error {
 	}
 	return nil
 }
-func ConfirmWithUser(msg string) bool {
-	var confirm string
-	for {
-		fmt.Print(msg)
-		_, err := fmt.Scanln(&confirm)
-		if err != nil {
-			// If there's an error reading input, default to "no"
-			fmt.Println("Error reading input, defaulting to 'no'")
-			return false
-		}
-		confirm = strings.ToLower(strings.TrimSpace(confirm))
-		if confirm == "y" || confirm == "n" {
-			break
-		}
-		fmt.Println("Please enter y or n")
-	}
-
-	if confirm == "y" {
-		return true
-	} else {
-		return false
-	}
-}
 
-func NormaliseRepoURL(url string) string {
-	if strings.HasPrefix(url, "git@") {
-		parts := strings.SplitN(url, ":", 2)
-		if len(parts) == 2 {
-			return "https://github.com/" + parts[1]
-		}
-	} else if strings.HasPrefix(url, "https:") {
-		parts := strings.SplitN(url, "https://github.com/", 2)
-		if len(parts) == 2 {
-			return "git@github.com:" + parts[1]
-		}
+// OpenEditor opens path in $VISUAL or $EDITOR (falling back to vi) attached to the terminal
+func OpenEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
 	}
-
-	return url
-}
-
-func NormaliseRepoSuffix(url string) string {
-	if strings.HasSuffix(url, ".git") {
-		url = strings.TrimSuffix(url, ".git")
-	} else {
-		url = url + ".git"
+	if editor == "" {
+		editor = "vi"
 	}
 
-	return url
-}
-
-func SameSuffix(s1, s2 string) bool {
-	return strings.HasSuffix(s1, ".git") && strings.HasSuffix(s2, ".git")
-}
-
-func SamePrefix(s1, s2 string) bool {
-	i := 0
-	for i < len(s1) && i < len(s2) && s1[i] == s2[i] {
-		i++
+	parts := strings.Fields(editor)
+	cmd := Command(parts[0], append(parts[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %v failed: %w", editor, err)
 	}
-
-	return i > 0
+	return nil
 }
 
 func ResolvePath(input string) (string, error) {
@@ -117,14 +75,19 @@ func ResolvePath(input string) (string, error) {
 	return filepath.Join(home, input), nil
 }
 
+// ShrinkPath writes a path in the home directory as ~ or ~/..., a path that only starts with the same letters,
+// like /home/alice with home /home/al, is left alone
 func ShrinkPath(path string) string {
 	home, err := os.UserHomeDir()
-	if err != nil {
+	if err != nil || home == "" || home == string(filepath.Separator) {
 		return path
 	}
 
-	if strings.HasPrefix(path, home) {
-		return strings.Replace(path, home, "~", 1)
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + path[len(home):]
 	}
 
 	return path
@@ -148,34 +111,58 @@ func FollowSymlink(symPath string) (string, error) {
 	return folderpath, nil
 }
 
-func LogVerbose(msg string, args ...interface{}) {
-	if Verbose {
-		fmt.Printf(msg+"\n", args...)
+// StateDir is where dotman keeps machine local state, $XDG_STATE_HOME/dotman or ~/.local/state/dotman
+func StateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "dotman")
 	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "state", "dotman")
 }
 
-func Run(name string, args ...string) (int, error) {
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	err := cmd.Run()
+// MachineName identifies this machine in the repository, $DOTMAN_MACHINE overrides the hostname
+func MachineName() string {
+	if name := os.Getenv("DOTMAN_MACHINE"); name != "" {
+		return name
+	}
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
 
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		return exitErr.ExitCode(), err
+func LogVerbose(msg string, args ...interface{}) {
+	if Verbose {
+		fmt.Printf(msg+"\n", args...)
 	}
+}
 
+// WriteFileAtomic writes data to a temporary file next to path and renames it into place,
+// so an interrupted write never leaves a truncated file behind
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
 	if err != nil {
-		return -1, err
+		return err
 	}
+	defer os.Remove(tmp.Name())
 
-	return 0, nil
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
-func RunOutput(name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	out, err := cmd.Output()
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		return string(out), exitErr
-	}
-	return string(out), err
+// IsTerminal reports whether f is connected to a terminal rather than a pipe or file
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
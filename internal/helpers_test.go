@@ -109,6 +109,11 @@ func TestShrinkPath(t *testing.T) {
 			input:    home,
 			expected: "~",
 		},
+		{
+			name:     "sibling starting with the home directory name",
+			input:    home + "ice/.bashrc",
+			expected: home + "ice/.bashrc",
+		},
 	}
 
 	for _, tt := range tests {
@@ -179,64 +184,3 @@ func TestFollowSymlink(t *testing.T) {
 		t.Errorf("FollowSymlink() = %v, want %v", result, targetFile)
 	}
 }
-
-func TestNormaliseRepoURL(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "SSH to HTTPS",
-			input:    "git@github.com:user/repo.git",
-			expected: "https://github.com/user/repo.git",
-		},
-		{
-			name:     "HTTPS to SSH",
-			input:    "https://github.com/user/repo.git",
-			expected: "git@github.com:user/repo.git",
-		},
-		{
-			name:     "unchanged URL",
-			input:    "https://gitlab.com/user/repo.git",
-			expected: "https://gitlab.com/user/repo.git",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := NormaliseRepoURL(tt.input)
-			if result != tt.expected {
-				t.Errorf("NormaliseRepoURL() = %v, want %v", result, tt.expected)
-			}
-		})
-	}
-}
-
-func TestNormaliseRepoSuffix(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "add .git suffix",
-			input:    "https://github.com/user/repo",
-			expected: "https://github.com/user/repo.git",
-		},
-		{
-			name:     "remove .git suffix",
-			input:    "https://github.com/user/repo.git",
-			expected: "https://github.com/user/repo",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := NormaliseRepoSuffix(tt.input)
-			if result != tt.expected {
-				t.Errorf("NormaliseRepoSuffix() = %v, want %v", result, tt.expected)
-			}
-		})
-	}
-}
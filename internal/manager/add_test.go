@@ -21,7 +21,7 @@ func TestAddFile(t *testing.T) {
 	testutils.CreateTestFile(t, infoPath, "{}")
 
 	// Test adding file
-	err := AddFile(testFile, repoDir, false)
+	err := AddFile(testutils.NewMockUserInput(), testFile, repoDir, false)
 	if err != nil {
 		t.Errorf("AddFile() error = %v", err)
 	}
@@ -51,7 +51,7 @@ func TestAddFileForce(t *testing.T) {
 	testutils.CreateTestFile(t, testFile, "zsh configuration")
 
 	// Add file first time
-	err := AddFile(testFile, repoDir, false)
+	err := AddFile(testutils.NewMockUserInput(), testFile, repoDir, false)
 	if err != nil {
 		t.Errorf("AddFile() error = %v", err)
 	}
@@ -66,7 +66,7 @@ func TestAddFileForce(t *testing.T) {
 	testutils.CreateTestFile(t, testFile2, "new zsh configuration")
 
 	// Try to add again with force
-	err = AddFile(testFile2, repoDir, true)
+	err = AddFile(testutils.NewMockUserInput(), testFile2, repoDir, true)
 	if err != nil {
 		t.Errorf("AddFile() with force error = %v", err)
 	}
@@ -89,7 +89,7 @@ func TestAddFileAlreadyExists(t *testing.T) {
 	testutils.CreateTestFile(t, testFile, "zsh configuration")
 
 	// Add file first time
-	err := AddFile(testFile, repoDir, false)
+	err := AddFile(testutils.NewMockUserInput(), testFile, repoDir, false)
 	if err != nil {
 		t.Errorf("AddFile() error = %v", err)
 	}
@@ -99,7 +99,7 @@ func TestAddFileAlreadyExists(t *testing.T) {
 	testutils.CreateTestFile(t, testFile2, "new zsh configuration")
 
 	// Try to add again without force - should fail
-	err = AddFile(testFile2, repoDir, false)
+	err = AddFile(testutils.NewMockUserInput(), testFile2, repoDir, false)
 	if err == nil {
 		t.Error("Expected error when adding file that already exists without force")
 	}
@@ -112,7 +112,7 @@ func TestAddFileNonExistentSource(t *testing.T) {
 	// Try to add non-existent file
 	nonExistentFile := filepath.Join(testDir, "nonexistent.txt")
 
-	err := AddFile(nonExistentFile, repoDir, false)
+	err := AddFile(testutils.NewMockUserInput(), nonExistentFile, repoDir, false)
 	if err == nil {
 		t.Error("Expected error when adding non-existent file")
 	}
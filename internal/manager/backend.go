@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"slices"
+
+	"github.com/ZonCen/dotman/internal/git"
+)
+
+// gitBackend is the git implementation every manager function goes through
+var gitBackend git.Backend = git.ExecBackend{}
+
+// primaryRemote is the remote sync pulls from, pushRemotes are pushed to, the primary always first
+var (
+	primaryRemote = git.DefaultRemote
+	pushRemotes   = []string{git.DefaultRemote}
+)
+
+// SetGitBackend selects the git implementation used by the manager, tests use it to install a fake
+func SetGitBackend(backend git.Backend) {
+	gitBackend = backend
+}
+
+// GitBackend returns the git implementation in use
+func GitBackend() git.Backend {
+	return gitBackend
+}
+
+// SetRemotes selects the remote changes are pulled from and the other remotes every push is mirrored to.
+// An empty primary keeps origin
+func SetRemotes(primary string, mirrors []string) {
+	if primary == "" {
+		primary = git.DefaultRemote
+	}
+	primaryRemote = primary
+	pushRemotes = []string{primary}
+	for _, mirror := range mirrors {
+		if mirror != "" && !slices.Contains(pushRemotes, mirror) {
+			pushRemotes = append(pushRemotes, mirror)
+		}
+	}
+}
+
+// PrimaryRemote returns the remote changes are pulled from
+func PrimaryRemote() string {
+	return primaryRemote
+}
@@ -0,0 +1,174 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/git/gittest"
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func useFake(t *testing.T) *gittest.Fake {
+	fake := gittest.NewFake()
+	previous := gitBackend
+	SetGitBackend(fake)
+	t.Cleanup(func() { SetGitBackend(previous) })
+	return fake
+}
+
+func TestSyncRepoWithFakeBackend(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	testutils.CreateTestFile(t, filepath.Join(testDir, "info.json"), "{}")
+
+	tests := []struct {
+		name      string
+		errors    map[string]error
+		notRepo   bool
+		wantErr   bool
+		wantCalls []string
+	}{
+		{
+			name:      "commits pulls and pushes in order",
+			wantCalls: []string{"Pull", "Add", "Commit", "Push"},
+		},
+		{
+			name:      "push failure is reported",
+			errors:    map[string]error{"Push": errors.New("rejected")},
+			wantErr:   true,
+			wantCalls: []string{"Pull", "Commit", "Push"},
+		},
+		{
+			name:      "not a repository stops before touching anything",
+			notRepo:   true,
+			wantErr:   true,
+			wantCalls: []string{"IsRepo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFake(t)
+			fake.Errors = tt.errors
+			fake.NotRepo = tt.notRepo
+
+			err := SyncRepo(testDir, SyncOptions{Download: true, Upload: true})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SyncRepo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.errors["Push"] != nil && !errors.Is(err, tt.errors["Push"]) {
+				t.Errorf("SyncRepo() error = %v, want it to wrap %v", err, tt.errors["Push"])
+			}
+
+			methods := fake.Methods()
+			next := 0
+			for _, method := range methods {
+				if next < len(tt.wantCalls) && method == tt.wantCalls[next] {
+					next++
+				}
+			}
+			if next != len(tt.wantCalls) {
+				t.Errorf("calls = %v, want %v in that order", strings.Join(methods, ", "), tt.wantCalls)
+			}
+			if tt.notRepo && len(methods) != 1 {
+				t.Errorf("calls = %v, want only IsRepo", methods)
+			}
+		})
+	}
+}
+
+func TestSyncRepoPullFailureIsNotDivergence(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	testutils.CreateTestFile(t, filepath.Join(testDir, "info.json"), "{}")
+
+	// A fast-forward only pull can fail for other reasons than diverged history
+	pullErr := &internal.CommandError{Command: "git", Args: []string{"pull"}, ExitCode: 1,
+		Stderr: "error: Your local changes to the following files would be overwritten by merge:\n\t.zshrc"}
+	fake := useFake(t)
+	fake.Errors = map[string]error{"Pull": pullErr}
+
+	err := SyncRepo(testDir, SyncOptions{Download: true, Upload: true, Strategy: git.StrategyFFOnly})
+	if !errors.Is(err, pullErr) {
+		t.Fatalf("SyncRepo() error = %v, want the pull error", err)
+	}
+	if errors.Is(err, ErrGitDiverged) {
+		t.Errorf("SyncRepo() error = %v, want it not to be reported as diverged", err)
+	}
+}
+
+func TestSyncRepoInterrupted(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	testutils.CreateTestFile(t, filepath.Join(testDir, "info.json"), "{}")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	internal.SetContext(ctx)
+	defer internal.SetContext(context.Background())
+
+	// Interrupted while the pull is merging: the merge is backed out and nothing is pushed
+	fake := useFake(t)
+	fake.OnCall = func(method string) {
+		if method == "Pull" {
+			cancel()
+			fake.Operation = git.OperationMerge
+		}
+	}
+	fake.Errors = map[string]error{"Pull": context.Canceled}
+
+	err := SyncRepo(testDir, SyncOptions{Download: true, Upload: true})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("SyncRepo() error = %v, want ErrInterrupted", err)
+	}
+	methods := strings.Join(fake.Methods(), ",")
+	if !strings.Contains(methods, "MergeAbort") {
+		t.Errorf("calls = %v, want the merge to be aborted", methods)
+	}
+	if strings.Contains(methods, "Push") {
+		t.Errorf("calls = %v, want no push after an interrupt", methods)
+	}
+
+	// Once interrupted a new step is not started
+	fake = useFake(t)
+	err = SyncRepo(testDir, SyncOptions{Download: true, Upload: true})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("SyncRepo() error = %v, want ErrInterrupted", err)
+	}
+	if strings.Contains(strings.Join(fake.Methods(), ","), "Pull") {
+		t.Errorf("calls = %v, want no pull after an interrupt", fake.Methods())
+	}
+}
+
+func TestSyncRepoAsksBeforePullingOverLocalChanges(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	testutils.CreateTestFile(t, filepath.Join(testDir, "info.json"), "{}")
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	// Without anyone to answer the question the sync fails instead of quietly skipping the pull
+	fake := useFake(t)
+	fake.StatusOutput = " M .zshrc\n"
+	err := SyncRepo(testDir, SyncOptions{Download: true})
+	if !errors.Is(err, prompt.ErrInputRequired) {
+		t.Fatalf("SyncRepo() error = %v, want ErrInputRequired", err)
+	}
+	if strings.Contains(strings.Join(fake.Methods(), ","), "Pull") {
+		t.Errorf("calls = %v, want no pull", fake.Methods())
+	}
+
+	fake = useFake(t)
+	fake.StatusOutput = " M .zshrc\n"
+	opts := SyncOptions{Download: true, Prompter: testutils.NewMockUserInput("y")}
+	if err := SyncRepo(testDir, opts); err != nil {
+		t.Fatalf("SyncRepo() error = %v", err)
+	}
+	if !strings.Contains(strings.Join(fake.Methods(), ","), "Pull") {
+		t.Errorf("calls = %v, want a pull once confirmed", fake.Methods())
+	}
+}
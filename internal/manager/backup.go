@@ -0,0 +1,207 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// backupIDFormat names backup directories so they sort by the time they were taken
+const backupIDFormat = "20060102-150405"
+
+// Backup is a set of local files that were moved aside so entries could be linked in their place
+type Backup struct {
+	ID      string        `json:"id"`
+	Created time.Time     `json:"created"`
+	Entries []BackupEntry `json:"entries"`
+	dir     string
+	names   map[string]int
+}
+
+// BackupEntry is one file or directory in a backup
+type BackupEntry struct {
+	Entry string `json:"entry"`
+	// Target is where the file was, Stored its name inside the backup directory
+	Target string `json:"target"`
+	Stored string `json:"stored"`
+	// Repo is the repository file that was linked at Target instead
+	Repo string `json:"repo"`
+}
+
+func backupsDir() string {
+	return filepath.Join(internal.StateDir(), "backups")
+}
+
+func newBackup() (*Backup, error) {
+	now := time.Now()
+	id := now.Format(backupIDFormat)
+	dir := filepath.Join(backupsDir(), id)
+	// Two deploys within the same second get their own directory
+	for i := 2; internal.FolderExist(dir); i++ {
+		id = fmt.Sprintf("%v-%d", now.Format(backupIDFormat), i)
+		dir = filepath.Join(backupsDir(), id)
+	}
+	if err := internal.CreateFolder(filepath.Join(dir, "files")); err != nil {
+		return nil, err
+	}
+	return &Backup{ID: id, Created: now.UTC().Truncate(time.Second), dir: dir, names: map[string]int{}}, nil
+}
+
+// add moves target into the backup and records it, the backup is saved right away so it can always be restored
+func (b *Backup) add(entry, target, repo string) error {
+	stored := filepath.Base(target)
+	if n := b.names[stored]; n > 0 {
+		stored = fmt.Sprintf("%v.%d", stored, n+1)
+	}
+	b.names[filepath.Base(target)]++
+
+	dest := filepath.Join(b.dir, "files", stored)
+	internal.LogVerbose("Backing up %v to %v", target, dest)
+	if err := moveAside(target, dest); err != nil {
+		return fmt.Errorf("could not back up %v: %w", target, err)
+	}
+	b.Entries = append(b.Entries, BackupEntry{Entry: entry, Target: target, Stored: stored, Repo: repo})
+	return b.save()
+}
+
+func (b *Backup) save() error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup: %w", err)
+	}
+	if err := internal.WriteFileAtomic(filepath.Join(b.dir, "backup.json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to save backup: %w", err)
+	}
+	return nil
+}
+
+// ListBackups returns the backups taken on this machine, oldest first
+func ListBackups() ([]Backup, error) {
+	dirs, err := os.ReadDir(backupsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read backups: %w", err)
+	}
+
+	backups := []Backup{}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		backup, err := readBackup(filepath.Join(backupsDir(), dir.Name()))
+		if err != nil {
+			internal.LogVerbose("Skipping %v: %v", dir.Name(), err)
+			continue
+		}
+		backups = append(backups, *backup)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].ID < backups[j].ID })
+	return backups, nil
+}
+
+func readBackup(dir string) (*Backup, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "backup.json"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read backup: %w", err)
+	}
+	backup := &Backup{}
+	if err := json.Unmarshal(data, backup); err != nil {
+		return nil, fmt.Errorf("could not parse backup: %w", err)
+	}
+	backup.dir = dir
+	return backup, nil
+}
+
+// RestoreBackup puts the files of a backup back where they were, an empty id restores the latest backup.
+// A target that is still the link dotman created is replaced, anything else is only overwritten with force
+func RestoreBackup(id string, force bool) error {
+	backup, err := findBackup(id)
+	if err != nil {
+		return err
+	}
+
+	remaining := []BackupEntry{}
+	failed := map[string]string{}
+	for _, entry := range backup.Entries {
+		if err := restoreEntry(backup.dir, entry, force); err != nil {
+			failed[entry.Entry] = err.Error()
+			remaining = append(remaining, entry)
+			continue
+		}
+		fmt.Printf("Restored %v\n", internal.ShrinkPath(entry.Target))
+	}
+
+	backup.Entries = remaining
+	if len(remaining) > 0 {
+		if err := backup.save(); err != nil {
+			return err
+		}
+		return fmt.Errorf("could not restore: %v", failed)
+	}
+	if err := os.RemoveAll(backup.dir); err != nil {
+		return fmt.Errorf("could not remove backup %v: %w", backup.ID, err)
+	}
+	return nil
+}
+
+// findBackup returns the backup with id, the latest one for an empty id. Only the backups ListBackups finds are
+// looked at, so an id like ../.. can not point outside of the backups directory
+func findBackup(id string) (*Backup, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("there are no backups in %v", backupsDir())
+	}
+	if id == "" {
+		return &backups[len(backups)-1], nil
+	}
+	for i := range backups {
+		if filepath.Base(backups[i].dir) == id {
+			return &backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("there is no backup %v, 'dotman restore-backup --list' shows them", id)
+}
+
+func restoreEntry(dir string, entry BackupEntry, force bool) error {
+	if _, err := os.Lstat(entry.Target); err == nil {
+		linked, _ := checkSamePath(entry.Target, entry.Repo)
+		if !linked && !force {
+			return fmt.Errorf("%v exists and is not linked by dotman, use --force to overwrite it", entry.Target)
+		}
+		internal.LogVerbose("Removing %v", entry.Target)
+		if err := os.RemoveAll(entry.Target); err != nil {
+			return fmt.Errorf("could not remove %v: %w", entry.Target, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not check %v: %w", entry.Target, err)
+	}
+	if err := internal.CreateFolder(filepath.Dir(entry.Target)); err != nil {
+		return err
+	}
+	return moveAside(filepath.Join(dir, "files", entry.Stored), entry.Target)
+}
+
+// moveAside renames src to dest, copying when they are on different filesystems
+func moveAside(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dest); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return fmt.Errorf("could not remove %v: %w", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestDiffSegments(t *testing.T) {
+	tests := []struct {
+		name   string
+		local  string
+		repo   string
+		hunks  int
+		merged string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", 0, "a\nb\n"},
+		{"changed line", "a\nb\nc\n", "a\nx\nc\n", 1, "a\nb\nc\n"},
+		{"added and removed", "a\nb\n", "b\nc\n", 2, "a\nb\n"},
+		{"empty local", "", "a\n", 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := diffSegments(tt.local, tt.repo)
+			if got := countHunks(segments); got != tt.hunks {
+				t.Errorf("countHunks() = %v, want %v", got, tt.hunks)
+			}
+			// Keeping the local side of every hunk gives the local file back
+			for i, segment := range segments {
+				if segment.Conflict {
+					segments[i] = conflictSegment{Lines: segment.Head}
+				}
+			}
+			if got := renderSegments(segments); got != tt.merged {
+				t.Errorf("renderSegments() = %q, want %q", got, tt.merged)
+			}
+		})
+	}
+}
+
+func TestDeployExistingTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		answers    []string
+		wantLinked bool
+		wantRepo   string
+	}{
+		{"back up and replace", []string{"b"}, true, "repo\n"},
+		{"keep local", []string{"k"}, false, "repo\n"},
+		{"merge taking the local hunk", []string{"m", "m"}, true, "local\n"},
+		{"merge skipped", []string{"m", "s"}, false, "repo\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir := testutils.TestDir(t)
+			defer testutils.CleanupTestDir(t, testDir)
+			t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+			info := files.FileInfo{Symlink: filepath.Join(testDir, "home", ".bashrc"),
+				Path: filepath.Join(testDir, "repo", ".bashrc")}
+			testutils.CreateTestFile(t, info.Symlink, "local\n")
+			testutils.CreateTestFile(t, info.Path, "repo\n")
+
+			d := newDeployer(testutils.NewMockUserInput(tt.answers...))
+			linked, err := d.link(".bashrc", info)
+			if err != nil || linked != tt.wantLinked {
+				t.Fatalf("link() = %v, %v, want %v", linked, err, tt.wantLinked)
+			}
+			testutils.AssertFileContent(t, info.Path, tt.wantRepo)
+			if !tt.wantLinked {
+				testutils.AssertFileContent(t, info.Symlink, "local\n")
+				if d.backup != nil {
+					t.Errorf("backup = %+v, want nothing backed up", d.backup)
+				}
+				return
+			}
+			testutils.AssertSymlink(t, info.Symlink, info.Path)
+
+			// The local file comes back from the backup and the link is gone
+			if err := RestoreBackup("", false); err != nil {
+				t.Fatalf("RestoreBackup() error = %v", err)
+			}
+			testutils.AssertFileContent(t, info.Symlink, "local\n")
+			if backups, err := ListBackups(); err != nil || len(backups) != 0 {
+				t.Errorf("ListBackups() = %v, %v, want the restored backup removed", backups, err)
+			}
+		})
+	}
+}
+
+func TestDeployExistingTargetNonInteractive(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	info := files.FileInfo{Symlink: filepath.Join(testDir, ".gitconfig"), Path: filepath.Join(testDir, "repo.gitconfig")}
+	testutils.CreateTestFile(t, info.Path, "[user]\n")
+
+	// The same content is replaced without asking
+	testutils.CreateTestFile(t, info.Symlink, "[user]\n")
+	d := newDeployer(prompt.NonInteractive{})
+	if linked, err := d.link(".gitconfig", info); err != nil || !linked {
+		t.Fatalf("link() identical = %v, %v, want linked", linked, err)
+	}
+
+	// A different file needs an answer
+	if err := os.Remove(info.Symlink); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	testutils.CreateTestFile(t, info.Symlink, "[core]\n")
+	if _, err := d.link(".gitconfig", info); !errors.Is(err, prompt.ErrInputRequired) {
+		t.Errorf("link() error = %v, want ErrInputRequired", err)
+	}
+	testutils.AssertFileContent(t, info.Symlink, "[core]\n")
+}
+
+func TestRestoreBackupKeepsUnmanagedFiles(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	info := files.FileInfo{Symlink: filepath.Join(testDir, ".vimrc"), Path: filepath.Join(testDir, "repo.vimrc")}
+	testutils.CreateTestFile(t, info.Path, "set nu\n")
+	testutils.CreateTestFile(t, info.Symlink, "set rnu\n")
+	d := newDeployer(testutils.NewMockUserInput("b"))
+	if _, err := d.link(".vimrc", info); err != nil {
+		t.Fatalf("link() error = %v", err)
+	}
+
+	// Something else took the place of the link since
+	if err := os.Remove(info.Symlink); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	testutils.CreateTestFile(t, info.Symlink, "new\n")
+	if err := RestoreBackup(d.backup.ID, false); err == nil {
+		t.Fatal("RestoreBackup() succeeded over a file dotman did not link")
+	}
+	testutils.AssertFileContent(t, info.Symlink, "new\n")
+
+	if err := RestoreBackup(d.backup.ID, true); err != nil {
+		t.Fatalf("RestoreBackup() with force error = %v", err)
+	}
+	testutils.AssertFileContent(t, info.Symlink, "set rnu\n")
+}
+
+func TestRestoreBackupRejectsUnknownID(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	// A backup.json outside of the backups directory must not be restored, nor removed afterwards
+	stateDir := internal.StateDir()
+	testutils.CreateTestFile(t, filepath.Join(stateDir, "backup.json"), `{"id": "evil", "entries": []}`)
+	testutils.CreateTestFile(t, filepath.Join(backupsDir(), "20260101-000000", "backup.json"),
+		`{"id": "20260101-000000", "entries": []}`)
+
+	for _, id := range []string{"..", "../..", "20260101"} {
+		if err := RestoreBackup(id, true); err == nil {
+			t.Errorf("RestoreBackup(%q) succeeded, want an unknown backup", id)
+		}
+	}
+	testutils.AssertFileExists(t, filepath.Join(stateDir, "backup.json"))
+	testutils.AssertFileExists(t, filepath.Join(backupsDir(), "20260101-000000", "backup.json"))
+}
@@ -0,0 +1,250 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/prompt"
+)
+
+// CreateBranch creates a host branch from the current one, switches to it and publishes it to the push remotes
+func CreateBranch(folderPath, name string) error {
+	if gitBackend.LocalBranchExists(folderPath, name) {
+		return fmt.Errorf("branch %v already exists, use 'dotman branch switch %v'", name, name)
+	}
+	if err := requireClean(folderPath); err != nil {
+		return err
+	}
+
+	internal.LogVerbose("Creating branch %v", name)
+	if err := gitBackend.CreateBranch(folderPath, name); err != nil {
+		return fmt.Errorf("could not create branch %v: %w", name, err)
+	}
+
+	internal.LogVerbose("Publishing %v", name)
+	if err := pushAll(folderPath, name).err(); err != nil {
+		return fmt.Errorf("could not push branch %v: %w", name, err)
+	}
+
+	return nil
+}
+
+// SwitchBranch checks out another branch and deploys the differences between both manifests
+func SwitchBranch(p prompt.Prompter, folderPath, name string) error {
+	if err := requireClean(folderPath); err != nil {
+		return err
+	}
+
+	before, err := gitBackend.RevParse(folderPath, "HEAD")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := checkoutBranch(folderPath, name); err != nil {
+		return err
+	}
+
+	if _, err := deployFrom(p, folderPath, before); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SharedBranch returns the branch merge and pick write to when none is given: the configured branch, unless that
+// is the branch being merged, otherwise the default branch of the primary remote. An empty from is the current branch
+func SharedBranch(folderPath, configured, from string) (string, error) {
+	if from == "" {
+		current, err := gitBackend.CurrentBranch(folderPath)
+		if err != nil {
+			return "", fmt.Errorf("%w", err)
+		}
+		from = current
+	}
+	if configured != "" && configured != from {
+		return configured, nil
+	}
+
+	internal.LogVerbose("Looking up the default branch of %v", primaryRemote)
+	refs, err := gitBackend.ListRemote(folderPath, primaryRemote)
+	if err != nil {
+		return "", fmt.Errorf("could not list %v, choose the shared branch with --into: %w", primaryRemote,
+			explainGitError(err))
+	}
+	shared, err := defaultBranch(refs)
+	if err != nil {
+		return "", err
+	}
+	if shared == from {
+		return "", fmt.Errorf("%v is the default branch of %v, choose the shared branch with --into", from,
+			primaryRemote)
+	}
+	return shared, nil
+}
+
+// MergeBranch merges a host branch back into the shared branch and pushes the result
+func MergeBranch(folderPath, from, into string) error {
+	current, err := prepareBranchWork(folderPath, &from, into)
+	if err != nil {
+		return err
+	}
+
+	internal.LogVerbose("Merging %v into %v", from, into)
+	if err := gitBackend.Merge(folderPath, from); err != nil {
+		if internal.Interrupted() {
+			err = recoverInterrupted(folderPath, err)
+			_ = internal.Uninterruptible(func() error {
+				returnToBranch(folderPath, current)
+				return nil
+			})
+			return err
+		}
+		if !stillInProgress(folderPath) {
+			returnToBranch(folderPath, current)
+			return fmt.Errorf("could not merge %v into %v: %w", from, into, err)
+		}
+		if err := settleConflicts(folderPath); err != nil {
+			fmt.Printf("Merge into %v stopped on conflicts, switch back with 'dotman branch switch %v' when done\n",
+				into, current)
+			return err
+		}
+	}
+
+	return publishAndReturn(folderPath, into, current)
+}
+
+// PickEntries copies individual entries, file and manifest entry, from a host branch onto the shared branch
+func PickEntries(folderPath, from, into string, entries []string) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("no entries given")
+	}
+
+	current, err := prepareBranchWork(folderPath, &from, into)
+	if err != nil {
+		return err
+	}
+
+	source := manifestAt(folderPath, from)
+	infoPath := filepath.Join(folderPath, "info.json")
+	target, err := files.ReadFile(infoPath)
+	if err != nil {
+		returnToBranch(folderPath, current)
+		return fmt.Errorf("could not read info.json: %w", err)
+	}
+
+	paths := []string{}
+	for _, name := range entries {
+		info, ok := source[name]
+		if !ok || info.Tombstone != nil {
+			returnToBranch(folderPath, current)
+			return fmt.Errorf("%v is not tracked on %v", name, from)
+		}
+		rel, err := filepath.Rel(folderPath, info.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			returnToBranch(folderPath, current)
+			return fmt.Errorf("%v is not stored inside the repository", name)
+		}
+		paths = append(paths, rel)
+		target[name] = info
+	}
+
+	internal.LogVerbose("Taking %v from %v", strings.Join(paths, ", "), from)
+	if err := gitBackend.CheckoutPaths(folderPath, from, paths...); err != nil {
+		returnToBranch(folderPath, current)
+		return fmt.Errorf("could not take files from %v: %w", from, err)
+	}
+	if err := files.SaveStatus(infoPath, target); err != nil {
+		returnToBranch(folderPath, current)
+		return fmt.Errorf("%w", err)
+	}
+	message := fmt.Sprintf("dotman pick %v from %v", strings.Join(entries, ", "), from)
+	if err := commitChanges(folderPath, message); err != nil {
+		returnToBranch(folderPath, current)
+		return err
+	}
+
+	return publishAndReturn(folderPath, into, current)
+}
+
+// prepareBranchWork checks out into, up to date with the primary remote, and returns the branch to come back to
+func prepareBranchWork(folderPath string, from *string, into string) (string, error) {
+	if err := requireClean(folderPath); err != nil {
+		return "", err
+	}
+
+	current, err := gitBackend.CurrentBranch(folderPath)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+	if *from == "" {
+		*from = current
+	}
+	if *from == into {
+		return "", fmt.Errorf("can not merge %v into itself", into)
+	}
+
+	if err := checkoutBranch(folderPath, into); err != nil {
+		return "", err
+	}
+
+	exists, err := gitBackend.RemoteBranchExists(folderPath, primaryRemote, into)
+	if err == nil && exists {
+		internal.LogVerbose("Updating %v from %v", into, primaryRemote)
+		if err := gitBackend.Pull(folderPath, primaryRemote, into, git.StrategyFFOnly, false); err != nil {
+			returnToBranch(folderPath, current)
+			return "", fmt.Errorf("could not update %v from %v: %w", into, primaryRemote, explainGitError(err))
+		}
+	}
+
+	return current, nil
+}
+
+func publishAndReturn(folderPath, branch, current string) error {
+	internal.LogVerbose("Pushing %v", branch)
+	if err := pushAll(folderPath, branch).err(); err != nil {
+		returnToBranch(folderPath, current)
+		return fmt.Errorf("could not push %v: %w", branch, err)
+	}
+	returnToBranch(folderPath, current)
+	return nil
+}
+
+// checkoutBranch switches to branch, creating it from the primary remote when it only exists there
+func checkoutBranch(folderPath, branch string) error {
+	internal.LogVerbose("Checking out %v", branch)
+	if gitBackend.LocalBranchExists(folderPath, branch) {
+		if err := gitBackend.Checkout(folderPath, branch); err != nil {
+			return fmt.Errorf("could not checkout %v: %w", branch, err)
+		}
+		return nil
+	}
+
+	if err := gitBackend.Fetch(folderPath, primaryRemote); err != nil {
+		return fmt.Errorf("could not fetch %v: %w", primaryRemote, explainGitError(err))
+	}
+	if err := gitBackend.FirstCheckout(folderPath, primaryRemote, branch); err != nil {
+		return fmt.Errorf("branch %v does not exist locally or on %v: %w", branch, primaryRemote, err)
+	}
+	return nil
+}
+
+func returnToBranch(folderPath, branch string) {
+	internal.LogVerbose("Switching back to %v", branch)
+	if err := gitBackend.Checkout(folderPath, branch); err != nil {
+		fmt.Printf("[warning] could not switch back to %v: %v\n", branch, err)
+	}
+}
+
+func requireClean(folderPath string) error {
+	output, err := gitBackend.Status(folderPath)
+	if err != nil {
+		return fmt.Errorf("failed to collect status: %w", err)
+	}
+	if strings.TrimSpace(output) != "" {
+		return fmt.Errorf("there are uncommitted changes in %v, run 'dotman sync' first", folderPath)
+	}
+	return nil
+}
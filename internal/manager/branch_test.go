@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestHostBranchMergeAndPick(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	repo := filepath.Join(testDir, "dotfiles")
+	testutils.CloneGitRemote(t, remote, repo)
+
+	if err := CreateBranch(repo, "testbox"); err != nil {
+		t.Fatalf("CreateBranch() error = %v", err)
+	}
+
+	// Syncing the configured branch must refuse to run on another branch
+	if err := SyncRepo(repo, SyncOptions{Download: true, Upload: true, Branch: "main"}); err == nil {
+		t.Error("Expected error when the checked out branch is not the configured one")
+	}
+
+	testutils.CreateTestFile(t, filepath.Join(repo, ".zshrc"), "export EDITOR=hx\n")
+	testutils.CreateTestFile(t, filepath.Join(repo, ".vimrc"), "set number\n")
+	testutils.CreateTestFile(t, filepath.Join(repo, "info.json"), `{
+  ".zshrc": {"symlink": "~/.zshrc", "path": "`+filepath.Join(repo, ".zshrc")+`"},
+  ".vimrc": {"symlink": "~/.vimrc", "path": "`+filepath.Join(repo, ".vimrc")+`"}
+}`)
+	if err := SyncRepo(repo, SyncOptions{Download: true, Upload: true, Branch: "testbox"}); err != nil {
+		t.Fatalf("SyncRepo() on host branch error = %v", err)
+	}
+	if !strings.Contains(testutils.RunGit(t, remote, "branch"), "testbox") {
+		t.Error("Expected host branch to be pushed to origin")
+	}
+
+	if err := PickEntries(repo, "", "main", []string{".vimrc"}); err != nil {
+		t.Fatalf("PickEntries() error = %v", err)
+	}
+	if current, _ := gitBackend.CurrentBranch(repo); current != "testbox" {
+		t.Errorf("Expected to be back on testbox, got %v", current)
+	}
+	mainManifest := testutils.RunGit(t, remote, "show", "main:info.json")
+	if !strings.Contains(mainManifest, ".vimrc") || strings.Contains(mainManifest, ".zshrc") {
+		t.Errorf("Expected only .vimrc to be picked into main, got %v", mainManifest)
+	}
+	testutils.RunGit(t, remote, "show", "main:.vimrc")
+
+	if err := MergeBranch(repo, "", "main"); err != nil {
+		t.Fatalf("MergeBranch() error = %v", err)
+	}
+	mainManifest = testutils.RunGit(t, remote, "show", "main:info.json")
+	if !strings.Contains(mainManifest, ".zshrc") {
+		t.Errorf("Expected .zshrc to be merged into main, got %v", mainManifest)
+	}
+}
+
+func TestSharedBranch(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	testutils.RunGit(t, remote, "branch", "-m", "main", "master")
+	repo := filepath.Join(testDir, "dotfiles")
+	testutils.CloneGitRemote(t, remote, repo)
+
+	if err := CreateBranch(repo, "testbox"); err != nil {
+		t.Fatalf("CreateBranch() error = %v", err)
+	}
+
+	// Syncing the host branch itself, so the default branch of the remote is the shared one
+	if into, err := SharedBranch(repo, "testbox", ""); err != nil || into != "master" {
+		t.Errorf("SharedBranch() = %v, %v, want master", into, err)
+	}
+	if into, err := SharedBranch(repo, "stable", ""); err != nil || into != "stable" {
+		t.Errorf("SharedBranch() = %v, %v, want the configured stable", into, err)
+	}
+	if _, err := SharedBranch(repo, "", "master"); err == nil {
+		t.Error("Expected error when merging the default branch without --into")
+	}
+}
@@ -0,0 +1,364 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/prompt"
+)
+
+// ManifestChanges lists the info.json entries that differ between two revisions
+type ManifestChanges struct {
+	Added   []string
+	Removed []string
+	Moved   []string
+}
+
+func (c ManifestChanges) empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Moved) == 0
+}
+
+// diffManifest compares the entries before and after a pull, tombstoned entries are left to applyTombstones
+func diffManifest(before, after map[string]files.FileInfo) ManifestChanges {
+	changes := ManifestChanges{}
+	for name, info := range after {
+		old, ok := before[name]
+		if info.Tombstone != nil || (ok && old.Tombstone != nil) {
+			continue
+		}
+		if !ok {
+			changes.Added = append(changes.Added, name)
+		} else if old.Symlink != info.Symlink || old.Path != info.Path {
+			changes.Moved = append(changes.Moved, name)
+		}
+	}
+	for name, info := range before {
+		if _, ok := after[name]; !ok && info.Tombstone == nil {
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Moved)
+
+	return changes
+}
+
+// manifestAt reads info.json as it was in the given revision, treating a missing file as empty
+func manifestAt(folderPath, rev string) map[string]files.FileInfo {
+	content, err := gitBackend.ShowFile(folderPath, rev, "info.json")
+	if err != nil {
+		internal.LogVerbose("No info.json at %v, treating it as empty", rev)
+		return map[string]files.FileInfo{}
+	}
+	manifest, err := files.ParseFile([]byte(content))
+	if err != nil {
+		internal.LogVerbose("Could not parse info.json at %v, treating it as empty: %v", rev, err)
+		return map[string]files.FileInfo{}
+	}
+	return manifest
+}
+
+// deployFrom links, unlinks and re-links entries that changed in info.json since rev
+func deployFrom(p prompt.Prompter, folderPath, rev string) (ManifestChanges, error) {
+	before := manifestAt(folderPath, rev)
+	after, err := files.ReadFile(filepath.Join(folderPath, "info.json"))
+	if err != nil {
+		return ManifestChanges{}, fmt.Errorf("could not read info.json: %w", err)
+	}
+
+	changes := diffManifest(before, after)
+	if changes.empty() {
+		internal.LogVerbose("No manifest changes to deploy")
+		return changes, nil
+	}
+
+	d := newDeployer(p)
+	defer d.report()
+	return changes, d.deployChanges(before, after, changes)
+}
+
+// deployer links entries into place. A target that already exists is compared with the repository version and
+// the user decides what happens to it, local files that get replaced are moved to a backup first
+type deployer struct {
+	prompter prompt.Prompter
+	backup   *Backup
+}
+
+func newDeployer(p prompt.Prompter) *deployer {
+	return &deployer{prompter: p}
+}
+
+func (d *deployer) deployChanges(before, after map[string]files.FileInfo, changes ManifestChanges) error {
+	errors := make(map[string]string)
+
+	for _, name := range changes.Removed {
+		if err := unlinkEntry(before[name]); err != nil {
+			errors[name] = err.Error()
+			continue
+		}
+		fmt.Printf("Unlinked %v\n", name)
+		if err := releaseTarget(before[name].Symlink); err != nil {
+			errors[name] = err.Error()
+		}
+	}
+
+	for _, name := range changes.Moved {
+		if err := unlinkEntry(before[name]); err != nil {
+			errors[name] = err.Error()
+			continue
+		}
+		if err := releaseTarget(before[name].Symlink); err != nil {
+			errors[name] = err.Error()
+			continue
+		}
+		linked, err := d.link(name, after[name])
+		if err != nil {
+			errors[name] = err.Error()
+			continue
+		}
+		if linked {
+			fmt.Printf("Re-linked %v -> %v\n", name, internal.ShrinkPath(after[name].Symlink))
+		}
+	}
+
+	for _, name := range changes.Added {
+		linked, err := d.link(name, after[name])
+		if err != nil {
+			errors[name] = err.Error()
+			continue
+		}
+		if linked {
+			fmt.Printf("Linked %v -> %v\n", name, internal.ShrinkPath(after[name].Symlink))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("could not deploy manifest changes: %v", errors)
+	}
+	return nil
+}
+
+// link creates the symlink for an entry, leaving an already correct link alone. It reports false when the
+// local file was kept instead
+func (d *deployer) link(name string, info files.FileInfo) (bool, error) {
+	if !internal.FileExist(info.Path) {
+		return false, fmt.Errorf("file %v does not exist", info.Path)
+	}
+	if ok, _ := checkSamePath(info.Symlink, info.Path); ok {
+		internal.LogVerbose("%v already points to %v", info.Symlink, info.Path)
+		return true, nil
+	}
+	overridden, err := overriddenTargets()
+	if err != nil {
+		return false, err
+	}
+	if layer, ok := overridden[info.Symlink]; ok {
+		fmt.Printf("Skipped %v, layer %v overrides %v\n", name, layer, internal.ShrinkPath(info.Symlink))
+		return false, nil
+	}
+	if err := checkUnclaimed(info.Symlink); err != nil {
+		return false, err
+	}
+	lower, err := linksLowerLayer(info.Symlink)
+	if err != nil {
+		return false, err
+	}
+	if lower != "" {
+		// The link of a lower layer is replaced without asking, the layer keeps its file
+		internal.LogVerbose("Overriding the link of layer %v at %v", lower, info.Symlink)
+		if err := os.Remove(info.Symlink); err != nil {
+			return false, fmt.Errorf("could not remove the link to layer %v: %w", lower, err)
+		}
+	} else if _, err := os.Lstat(info.Symlink); err == nil {
+		replace, err := d.resolveExisting(name, info)
+		if err != nil || !replace {
+			return false, err
+		}
+	}
+	if err := ensureParent(info.Symlink); err != nil {
+		return false, err
+	}
+	internal.LogVerbose("Creating symlink %v -> %v", info.Symlink, info.Path)
+	if err := internal.CreateSymlink(info.Symlink, info.Path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// resolveExisting shows how the file at the target differs from the repository and asks whether to back it up
+// and replace it, keep it, or merge it into the repository first. It reports true once the target is free
+func (d *deployer) resolveExisting(name string, info files.FileInfo) (bool, error) {
+	target := internal.ShrinkPath(info.Symlink)
+	local, localErr := readRegular(info.Symlink)
+	repo, repoErr := readRegular(info.Path)
+	if localErr == nil && repoErr == nil && local == repo {
+		fmt.Printf("%v already has the content from the repository, replacing it with a link\n", target)
+		return true, d.backUp(name, info)
+	}
+
+	fmt.Printf("\n%v already exists and differs from %v in the repository\n", target, name)
+	msg := "[b]ack up and replace, [k]eep local and skip: "
+	choices := []string{"b", "k"}
+	var segments []conflictSegment
+	if localErr == nil && repoErr == nil {
+		segments = diffSegments(local, repo)
+		fmt.Println("  mine is the local file, theirs the repository version")
+		printThreeWay(segments, git.OperationMerge)
+		msg = "[b]ack up and replace, [k]eep local and skip, [m]erge into the repository: "
+		choices = append(choices, "m")
+	} else {
+		fmt.Println("  One of them is not a regular file, so there is no diff to show")
+	}
+
+	choice, err := d.prompter.Choose("deploy.existing", msg, choices...)
+	if err != nil {
+		return false, err
+	}
+	switch choice {
+	case "b":
+		return true, d.backUp(name, info)
+	case "m":
+		merged, err := resolveHunks(d.prompter, info.Path, segments, git.OperationMerge)
+		if err != nil || !merged {
+			return false, err
+		}
+		fmt.Printf("Merged %v into the repository, commit it with the next 'dotman sync'\n", target)
+		return true, d.backUp(name, info)
+	}
+	fmt.Printf("Kept %v, %v is not linked\n", target, name)
+	return false, nil
+}
+
+// backUp moves the target of an entry into this deploy's backup, creating the backup on first use
+func (d *deployer) backUp(name string, info files.FileInfo) error {
+	if d.backup == nil {
+		backup, err := newBackup()
+		if err != nil {
+			return err
+		}
+		d.backup = backup
+	}
+	return d.backup.add(name, info.Symlink, info.Path)
+}
+
+// report tells where replaced files went and how to get them back
+func (d *deployer) report() {
+	if d.backup == nil || len(d.backup.Entries) == 0 {
+		return
+	}
+	fmt.Printf("Backed up %d existing file(s) to %v, put them back with 'dotman restore-backup %v'\n",
+		len(d.backup.Entries), internal.ShrinkPath(d.backup.dir), d.backup.ID)
+}
+
+// readRegular returns the content of a regular file, symlinks and directories are not compared
+func readRegular(path string) (string, error) {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+	if !stat.Mode().IsRegular() {
+		return "", fmt.Errorf("%v is not a regular file", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read %v: %w", path, err)
+	}
+	return string(content), nil
+}
+
+// maxDiffCells caps the line diff, larger files are shown and merged as a single hunk
+const maxDiffCells = 1 << 22
+
+// diffSegments splits two versions of a file into common lines and hunks where they differ, with the local
+// lines as Head and the repository lines as Other so they can be resolved like a merge conflict
+func diffSegments(local, repo string) []conflictSegment {
+	a, b := splitLines(local), splitLines(repo)
+	if len(a)*len(b) > maxDiffCells {
+		return []conflictSegment{{Conflict: true, Head: a, Other: b}}
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	segments := []conflictSegment{}
+	current := conflictSegment{}
+	flush := func(conflict bool) {
+		if current.Conflict != conflict && (len(current.Lines) > 0 || current.Conflict) {
+			segments = append(segments, current)
+			current = conflictSegment{}
+		}
+		current.Conflict = conflict
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush(false)
+			current.Lines = append(current.Lines, a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			flush(true)
+			current.Head = append(current.Head, a[i])
+			i++
+		default:
+			flush(true)
+			current.Other = append(current.Other, b[j])
+			j++
+		}
+	}
+	if current.Conflict || len(current.Lines) > 0 {
+		segments = append(segments, current)
+	}
+	return segments
+}
+
+// releaseTarget hands an unlinked target to the layer below when one tracks it, otherwise the empty
+// directories dotman created for it are removed
+func releaseTarget(target string) error {
+	linked, err := linkLowerLayer(target)
+	if err != nil || linked {
+		return err
+	}
+	return removeCreatedDirs(target)
+}
+
+// unlinkEntry removes the symlink of an entry, but only if it still points into the repository
+func unlinkEntry(info files.FileInfo) error {
+	isSym, err := internal.IsSymlink(info.Symlink)
+	if err != nil || !isSym {
+		internal.LogVerbose("%v is not a symlink, leaving it alone", info.Symlink)
+		return nil
+	}
+	target, err := internal.FollowSymlink(info.Symlink)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if target != info.Path {
+		internal.LogVerbose("%v points to %v, leaving it alone", info.Symlink, target)
+		return nil
+	}
+	internal.LogVerbose("Removing symlink %v", info.Symlink)
+	if err := os.Remove(info.Symlink); err != nil {
+		return fmt.Errorf("could not remove symlink: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// DefaultDirMode is the mode of parent directories dotman creates for links when nothing else is configured
+const DefaultDirMode os.FileMode = 0755
+
+// dirMode is the mode of created parent directories, dirModes overrides it for a directory and everything below
+var (
+	dirMode  = DefaultDirMode
+	dirModes = map[string]os.FileMode{}
+)
+
+// SetDirModes selects the modes of parent directories created for links, per directory modes apply to the
+// directory and the directories below it
+func SetDirModes(mode os.FileMode, perDir map[string]os.FileMode) {
+	if mode == 0 {
+		mode = DefaultDirMode
+	}
+	dirMode = mode
+	dirModes = map[string]os.FileMode{}
+	for dir, m := range perDir {
+		dirModes[filepath.Clean(dir)] = m
+	}
+}
+
+// modeFor returns the mode of the closest configured directory at or above dir
+func modeFor(dir string) os.FileMode {
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		if mode, ok := dirModes[current]; ok {
+			return mode
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return dirMode
+		}
+	}
+}
+
+func createdDirsPath() string {
+	return filepath.Join(internal.StateDir(), "created_dirs.json")
+}
+
+// readCreatedDirs returns the directories dotman created on this machine and the links that live in them
+func readCreatedDirs() (map[string][]string, error) {
+	created := map[string][]string{}
+	data, err := os.ReadFile(createdDirsPath())
+	if os.IsNotExist(err) {
+		return created, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read created directories: %w", err)
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		return nil, fmt.Errorf("could not parse %v: %w", createdDirsPath(), err)
+	}
+	return created, nil
+}
+
+func saveCreatedDirs(created map[string][]string) error {
+	if len(created) == 0 {
+		if err := os.Remove(createdDirsPath()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not clear created directories: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(created, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal created directories: %w", err)
+	}
+	if err := internal.CreateFolder(filepath.Dir(createdDirsPath())); err != nil {
+		return err
+	}
+	if err := internal.WriteFileAtomic(createdDirsPath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to save created directories: %w", err)
+	}
+	return nil
+}
+
+// ensureParent creates the missing parent directories of link and records them, together with every directory
+// dotman created before that link now lives in
+func ensureParent(link string) error {
+	missing := []string{}
+	for dir := filepath.Dir(link); !internal.FolderExist(dir); dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil {
+			return fmt.Errorf("%v exists and is not a directory", dir)
+		}
+		missing = append(missing, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	created, err := readCreatedDirs()
+	if err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		dir, mode := missing[i], modeFor(missing[i])
+		internal.LogVerbose("Creating %v with mode %v", dir, mode)
+		if err := os.Mkdir(dir, mode); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("could not create %v: %w", dir, err)
+		}
+		// Mkdir is subject to the umask, the configured mode is applied as is
+		if err := os.Chmod(dir, mode); err != nil {
+			return fmt.Errorf("could not set the mode of %v: %w", dir, err)
+		}
+		created[dir] = []string{}
+	}
+
+	changed := len(missing) > 0
+	for dir, links := range created {
+		if isWithin(link, dir) && !slices.Contains(links, link) {
+			created[dir] = append(links, link)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return saveCreatedDirs(created)
+}
+
+// removeCreatedDirs forgets link and removes the directories dotman created for it once no other link needs
+// them and they are empty, deepest first. Directories the user put files in are left alone
+func removeCreatedDirs(link string) error {
+	created, err := readCreatedDirs()
+	if err != nil {
+		return err
+	}
+
+	dirs := []string{}
+	for dir, links := range created {
+		if !slices.Contains(links, link) {
+			continue
+		}
+		links = slices.DeleteFunc(links, func(l string) bool { return l == link })
+		created[dir] = links
+		if len(links) == 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		delete(created, dir)
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			internal.LogVerbose("Keeping %v, it is not empty", dir)
+			continue
+		}
+		internal.LogVerbose("Removing %v, dotman created it", dir)
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("could not remove %v: %w", dir, err)
+		}
+	}
+	return saveCreatedDirs(created)
+}
+
+func isWithin(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func useDirModes(t *testing.T, mode os.FileMode, perDir map[string]os.FileMode) {
+	previous, previousPerDir := dirMode, dirModes
+	SetDirModes(mode, perDir)
+	t.Cleanup(func() { dirMode, dirModes = previous, previousPerDir })
+}
+
+func TestModeFor(t *testing.T) {
+	useDirModes(t, 0750, map[string]os.FileMode{"/home/me/.ssh": 0700})
+
+	tests := []struct {
+		dir  string
+		want os.FileMode
+	}{
+		{"/home/me/.config/alacritty", 0750},
+		{"/home/me/.ssh", 0700},
+		{"/home/me/.ssh/config.d", 0700},
+		{"/home/me/.sshd", 0750},
+	}
+	for _, tt := range tests {
+		if got := modeFor(tt.dir); got != tt.want {
+			t.Errorf("modeFor(%v) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestLinkCreatesParentDirectories(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	home := filepath.Join(testDir, "home")
+	config := filepath.Join(home, ".config")
+	useDirModes(t, 0750, map[string]os.FileMode{filepath.Join(config, "secret"): 0700})
+	testutils.CreateTestFile(t, filepath.Join(home, ".bashrc"), "")
+
+	alacritty := files.FileInfo{Symlink: filepath.Join(config, "alacritty", "alacritty.toml"),
+		Path: filepath.Join(testDir, "repo", "alacritty.toml")}
+	secret := files.FileInfo{Symlink: filepath.Join(config, "secret", "token"),
+		Path: filepath.Join(testDir, "repo", "token")}
+	testutils.CreateTestFile(t, alacritty.Path, "")
+	testutils.CreateTestFile(t, secret.Path, "")
+
+	d := newDeployer(prompt.NonInteractive{})
+	for name, info := range map[string]files.FileInfo{"alacritty.toml": alacritty, "token": secret} {
+		if _, err := d.link(name, info); err != nil {
+			t.Fatalf("link(%v) error = %v", name, err)
+		}
+		testutils.AssertSymlink(t, info.Symlink, info.Path)
+	}
+	for dir, want := range map[string]os.FileMode{config: 0750, filepath.Dir(secret.Symlink): 0700} {
+		stat, err := os.Stat(dir)
+		if err != nil {
+			t.Fatalf("Stat(%v) error = %v", dir, err)
+		}
+		if stat.Mode().Perm() != want {
+			t.Errorf("%v mode = %v, want %v", dir, stat.Mode().Perm(), want)
+		}
+	}
+
+	// .config is still needed by the other link, alacritty/ is removed with its link
+	if err := unlinkEntry(alacritty); err != nil {
+		t.Fatalf("unlinkEntry() error = %v", err)
+	}
+	if err := removeCreatedDirs(alacritty.Symlink); err != nil {
+		t.Fatalf("removeCreatedDirs() error = %v", err)
+	}
+	testutils.AssertFileNotExists(t, filepath.Dir(alacritty.Symlink))
+	testutils.AssertFileExists(t, config)
+
+	// A file the user put in a created directory keeps it
+	testutils.CreateTestFile(t, filepath.Join(config, "secret", "notes"), "")
+	if err := unlinkEntry(secret); err != nil {
+		t.Fatalf("unlinkEntry() error = %v", err)
+	}
+	if err := removeCreatedDirs(secret.Symlink); err != nil {
+		t.Fatalf("removeCreatedDirs() error = %v", err)
+	}
+	testutils.AssertFileExists(t, filepath.Join(config, "secret", "notes"))
+	if created, err := readCreatedDirs(); err != nil || len(created) != 0 {
+		t.Errorf("readCreatedDirs() = %v, %v, want nothing recorded", created, err)
+	}
+
+	// Directories dotman did not create are never removed
+	testutils.AssertFileExists(t, home)
+}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// Failures of git commands that dotman can explain, check for them with errors.Is
+var (
+	ErrGitAuth     = errors.New("authentication with the remote failed, check your SSH key or credentials")
+	ErrGitRejected = errors.New("the remote has commits this machine does not have, " +
+		"run 'dotman sync' to pull them first")
+	ErrGitDiverged = errors.New("local and remote history have diverged " +
+		"(try --strategy rebase or --strategy merge)")
+	ErrGitNoUpstream = errors.New("the branch has no upstream on the remote, " +
+		"run 'dotman sync' to publish it")
+	ErrGitRemoteNotFound = errors.New("the remote repository was not found, check its URL with 'dotman init --force'")
+	ErrGitNetwork        = errors.New("could not reach the remote, check your network connection")
+	ErrGitHostKey        = errors.New("the remote's SSH host key is unknown or changed, " +
+		"check it and add it to ~/.ssh/known_hosts")
+)
+
+// gitErrorPatterns maps pieces of git's stderr to the failure they indicate, first match wins
+var gitErrorPatterns = []struct {
+	kind     error
+	patterns []string
+}{
+	{ErrGitAuth, []string{"permission denied", "authentication failed", "could not read username",
+		"could not read password", "terminal prompts disabled", "invalid username or password",
+		"returned error: 401", "returned error: 403"}},
+	{ErrGitRemoteNotFound, []string{"repository not found", "does not appear to be a git repository",
+		"returned error: 404"}},
+	{ErrGitHostKey, []string{"host key verification failed", "remote host identification has changed"}},
+	// "unable to access" and "could not read from remote repository" alone are not enough, git prints them after
+	// HTTP, certificate and SSH errors as well
+	{ErrGitNetwork, []string{"could not resolve host", "connection refused", "connection timed out",
+		"network is unreachable", "no route to host", "failed to connect", "couldn't connect to server",
+		"connection reset", "no such host", "i/o timeout", "dial tcp"}},
+	{ErrGitRejected, []string{"[rejected]", "fetch first", "failed to push some refs"}},
+	{ErrGitDiverged, []string{"not possible to fast-forward", "diverging branches", "divergent branches"}},
+	{ErrGitNoUpstream, []string{"no upstream branch", "has no upstream", "no tracking information"}},
+}
+
+// explainGitError adds an actionable explanation to a failed git command based on what it wrote to stderr,
+// or the error message for backends that do not run git, errors it does not recognise are returned unchanged
+func explainGitError(err error) error {
+	if err == nil {
+		return nil
+	}
+	stderr := strings.ToLower(err.Error())
+	var cmdErr *internal.CommandError
+	if errors.As(err, &cmdErr) {
+		stderr = strings.ToLower(cmdErr.Stderr)
+	}
+	for _, known := range gitErrorPatterns {
+		for _, pattern := range known.patterns {
+			if strings.Contains(stderr, pattern) {
+				return fmt.Errorf("%w: %w", known.kind, err)
+			}
+		}
+	}
+	return err
+}
+
+// isOffline reports whether a fetch, pull or push failed because the remote could not be reached at all,
+// as opposed to the remote refusing it
+func isOffline(err error) bool {
+	if errors.Is(err, ErrGitNetwork) {
+		return true
+	}
+	var cmdErr *internal.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.TimedOut > 0
+}
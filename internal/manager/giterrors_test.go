@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+func TestExplainGitError(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   error
+	}{
+		{"ssh key", "git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.",
+			ErrGitAuth},
+		{"https credentials", "fatal: could not read Username for 'https://github.com': terminal prompts disabled",
+			ErrGitAuth},
+		{"rejected push", " ! [rejected]        main -> main (fetch first)\nerror: failed to push some refs",
+			ErrGitRejected},
+		{"diverged", "fatal: Not possible to fast-forward, aborting.", ErrGitDiverged},
+		{"no upstream", "fatal: The current branch main has no upstream branch.", ErrGitNoUpstream},
+		{"missing repository", "ERROR: Repository not found.", ErrGitRemoteNotFound},
+		{"offline", "ssh: Could not resolve hostname github.com: Name or service not known\n" +
+			"fatal: Could not read from remote repository.", ErrGitNetwork},
+		{"https offline", "fatal: unable to access 'https://github.com/u/dotfiles.git/': " +
+			"Could not resolve host: github.com", ErrGitNetwork},
+		{"https forbidden", "fatal: unable to access 'https://github.com/u/dotfiles.git/': " +
+			"The requested URL returned error: 403", ErrGitAuth},
+		{"https missing", "fatal: unable to access 'https://example.com/u/dotfiles.git/': " +
+			"The requested URL returned error: 404", ErrGitRemoteNotFound},
+		{"certificate", "fatal: unable to access 'https://example.com/u/dotfiles.git/': " +
+			"SSL certificate problem: self-signed certificate", nil},
+		{"unknown host key", "Host key verification failed.\nfatal: Could not read from remote repository.",
+			ErrGitHostKey},
+		{"ssh unreachable", "ssh: connect to host github.com port 22: No route to host\n" +
+			"fatal: Could not read from remote repository.", ErrGitNetwork},
+		{"unknown", "fatal: something else", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmdErr := &internal.CommandError{Command: "git", Args: []string{"push"}, ExitCode: 128, Stderr: tt.stderr}
+			err := explainGitError(cmdErr)
+			// Only an unreachable remote is kept as pending, anything else has to surface
+			if isOffline(err) != (tt.want == ErrGitNetwork) {
+				t.Errorf("isOffline(%v) = %v", err, isOffline(err))
+			}
+			if tt.want == nil {
+				if err != cmdErr {
+					t.Errorf("explainGitError() = %v, want the error unchanged", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("explainGitError() = %v, want %v", err, tt.want)
+			}
+			if !errors.As(err, &cmdErr) {
+				t.Error("explainGitError() lost the command error")
+			}
+		})
+	}
+}
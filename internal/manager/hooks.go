@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/hooks"
+	"github.com/ZonCen/dotman/internal/prompt"
+)
+
+// runHooks runs the repository hooks for an event and stops at the first one that fails
+func runHooks(p prompt.Prompter, folderPath, event string, env map[string]string) error {
+	cfg, err := hooks.Load(folderPath)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	for _, command := range cfg.For(event) {
+		if _, err := hooks.Run(p, folderPath, event, command, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPostHooks runs hooks for an operation that already happened, so failures are only reported
+func runPostHooks(p prompt.Prompter, folderPath, event string, env map[string]string) {
+	if err := runHooks(p, folderPath, event, env); err != nil {
+		fmt.Printf("[warning] %v\n", err)
+	}
+}
+
+// runEntryHooks runs the hooks configured for each of the given entries
+func runEntryHooks(p prompt.Prompter, folderPath string, names []string) {
+	cfg, err := hooks.Load(folderPath)
+	if err != nil {
+		fmt.Printf("[warning] %v\n", err)
+		return
+	}
+	if len(cfg.Entries) == 0 {
+		return
+	}
+	fileInfo, err := files.ReadFile(filepath.Join(folderPath, "info.json"))
+	if err != nil {
+		fmt.Printf("[warning] could not read info.json: %v\n", err)
+		return
+	}
+
+	for _, name := range names {
+		info := fileInfo[name]
+		env := map[string]string{
+			"DOTMAN_ENTRY":   name,
+			"DOTMAN_SYMLINK": info.Symlink,
+			"DOTMAN_PATH":    info.Path,
+		}
+		for _, command := range cfg.Entries[name] {
+			if _, err := hooks.Run(p, folderPath, "entry", command, env); err != nil {
+				fmt.Printf("[warning] %v\n", err)
+			}
+		}
+	}
+}
+
+// afterPull runs post-pull hooks, deploys manifest changes and runs the hooks of every entry that changed
+func afterPull(folderPath, before string, opts SyncOptions) error {
+	after, err := gitBackend.RevParse(folderPath, "HEAD")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if after == before {
+		internal.LogVerbose("Nothing new was pulled")
+		return nil
+	}
+
+	runPostHooks(opts.prompter(), folderPath, hooks.PostPull, map[string]string{"DOTMAN_FROM": before, "DOTMAN_TO": after})
+
+	changed := map[string]bool{}
+	if paths, err := gitBackend.ChangedFiles(folderPath, before, after); err == nil {
+		names := entryNames(folderPath)
+		for _, path := range paths {
+			if name, ok := entryFor(names, path); ok {
+				changed[name] = true
+			}
+		}
+	}
+
+	if opts.NoDeploy {
+		internal.LogVerbose("Skipping deploy of manifest changes")
+	} else {
+		internal.LogVerbose("Deploying manifest changes since %v", before)
+		changes, err := deployFrom(opts.prompter(), folderPath, before)
+		if err != nil {
+			return err
+		}
+		if !changes.empty() {
+			runPostHooks(opts.prompter(), folderPath, hooks.PostDeploy, nil)
+		}
+		for _, name := range append(changes.Added, changes.Moved...) {
+			changed[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	runEntryHooks(opts.prompter(), folderPath, names)
+
+	return nil
+}
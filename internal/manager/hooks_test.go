@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestSyncRepoRunsDirectoryEntryHooks(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	machineA := filepath.Join(testDir, "machineA")
+	machineB := filepath.Join(testDir, "machineB")
+	testutils.CloneGitRemote(t, remote, machineA)
+	testutils.CloneGitRemote(t, remote, machineB)
+
+	log := filepath.Join(testDir, "hooks.log")
+	nvimLink := filepath.Join(testDir, "home", ".config", "nvim")
+	testutils.CreateTestFile(t, filepath.Join(testDir, "home", ".config", ".keep"), "")
+	testutils.CreateTestFile(t, filepath.Join(machineA, "nvim", "init.lua"), "vim.o.number = true\n")
+	testutils.CreateTestFile(t, filepath.Join(machineA, "hooks.yaml"), `entries:
+  nvim:
+    - echo "$DOTMAN_ENTRY" >> `+log+`
+`)
+	testutils.CreateTestFile(t, filepath.Join(machineA, "info.json"), `{
+  "nvim": {"symlink": "`+nvimLink+`", "path": "`+filepath.Join(machineB, "nvim")+`"}
+}`)
+	if err := SyncRepo(machineA, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() on machineA error = %v", err)
+	}
+	opts := SyncOptions{Download: true, Upload: true, Prompter: prompt.Fixed(true)}
+	if err := SyncRepo(machineB, opts); err != nil {
+		t.Fatalf("SyncRepo() on machineB error = %v", err)
+	}
+	testutils.AssertFileContent(t, log, "nvim\n")
+	if err := os.Remove(log); err != nil {
+		t.Fatal(err)
+	}
+
+	// A change to a file inside the directory runs the hooks of the directory entry
+	testutils.CreateTestFile(t, filepath.Join(machineA, "nvim", "init.lua"), "vim.o.number = false\n")
+	if err := SyncRepo(machineA, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() on machineA error = %v", err)
+	}
+	if err := SyncRepo(machineB, opts); err != nil {
+		t.Fatalf("SyncRepo() on machineB error = %v", err)
+	}
+	testutils.AssertFileContent(t, log, "nvim\n")
+}
@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/ignore"
+)
+
+// ignorePatterns are the ignore patterns of this machine's config, on top of the .dotmanignore of the repository
+var ignorePatterns []string
+
+// SetIgnorePatterns sets the patterns from the ignore setting of the config
+func SetIgnorePatterns(patterns []string) {
+	ignorePatterns = patterns
+}
+
+// loadIgnore returns the matcher of the repository in folderPath
+func loadIgnore(folderPath string) (*ignore.Matcher, error) {
+	return ignore.Load(folderPath, ignorePatterns)
+}
+
+// checkNotIgnored fails when the entry name of a file to add to the repository in folderPath is ignored
+func checkNotIgnored(folderPath, name string, isDir bool) error {
+	matcher, err := loadIgnore(folderPath)
+	if err != nil {
+		return err
+	}
+	if matcher.Match(name, isDir) {
+		return fmt.Errorf("%v is ignored by %v or the ignore setting", name, ignore.File)
+	}
+	return nil
+}
+
+// excludeIgnored keeps git from staging the files the repository in folderPath ignores. Only the local exclude
+// file is written, a change to .gitignore before pulling would make the history diverge
+func excludeIgnored(folderPath string) error {
+	matcher, err := loadIgnore(folderPath)
+	if err != nil {
+		return err
+	}
+	return matcher.WriteExclude(folderPath)
+}
+
+// applyIgnore keeps .gitignore in step with the ignore patterns of the repository in folderPath and stops
+// tracking committed files that the shared patterns ignore now, they stay in the repository folder. Both end up in
+// the next commit. The patterns of this machine's config never untrack a file, that would delete it on every other
+// machine
+func applyIgnore(folderPath string) error {
+	matcher, err := loadIgnore(folderPath)
+	if err != nil {
+		return err
+	}
+	if err := matcher.WriteExclude(folderPath); err != nil {
+		return err
+	}
+	if err := matcher.WriteGitignore(folderPath); err != nil {
+		return err
+	}
+
+	tracked, err := gitBackend.ListFiles(folderPath, "HEAD")
+	if err != nil {
+		internal.LogVerbose("Nothing committed yet, no ignored files to untrack: %v", err)
+		return nil
+	}
+	ignored := []string{}
+	for _, path := range tracked {
+		if matcher.MatchShared(path, false) {
+			ignored = append(ignored, path)
+		}
+	}
+	if len(ignored) == 0 {
+		return nil
+	}
+	if err := gitBackend.Untrack(folderPath, ignored...); err != nil {
+		return fmt.Errorf("could not untrack ignored files: %w", err)
+	}
+	fmt.Printf("Stopped tracking %d ignored file(s): %v\n", len(ignored), strings.Join(ignored, ", "))
+	return nil
+}
+
+// reportIgnored tells which files of a directory added to the repository in folderPath are ignored, they stay in
+// the directory but are not synced
+func reportIgnored(folderPath, dir string) error {
+	matcher, err := loadIgnore(folderPath)
+	if err != nil {
+		return err
+	}
+	ignored, err := matcher.Ignored(folderPath, dir)
+	if err != nil {
+		return err
+	}
+	if len(ignored) > 0 {
+		fmt.Printf("%d file(s) in %v are ignored and not synced: %v\n", len(ignored), filepath.Base(dir),
+			strings.Join(ignored, ", "))
+	}
+	return matcher.WriteExclude(folderPath)
+}
@@ -0,0 +1,114 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestSyncRepoIgnoresFiles(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	machine := filepath.Join(testDir, "machine")
+	testutils.CloneGitRemote(t, remote, machine)
+
+	// A lock file committed before it was ignored, and a swap file and plugin cache that never should be
+	testutils.CreateTestFile(t, filepath.Join(machine, "nvim", "lazy-lock.json"), "{}")
+	testutils.RunGit(t, machine, "add", "-A")
+	testutils.RunGit(t, machine, "commit", "-m", "lock file")
+	testutils.CreateTestFile(t, filepath.Join(machine, ".dotmanignore"), "# plugins\nlazy-lock.json\n")
+	testutils.CreateTestFile(t, filepath.Join(machine, "nvim", ".init.lua.swp"), "swap")
+	testutils.CreateTestFile(t, filepath.Join(machine, "nvim", "plugin", "cache", "x"), "cache")
+	testutils.CreateTestFile(t, filepath.Join(machine, "nvim", "init.lua"), "vim.o.number = true\n")
+	testutils.CreateTestFile(t, filepath.Join(machine, ".gitignore"), "secrets\n")
+
+	previous := ignorePatterns
+	SetIgnorePatterns([]string{"cache/"})
+	t.Cleanup(func() { SetIgnorePatterns(previous) })
+
+	if err := SyncRepo(machine, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() error = %v", err)
+	}
+
+	tracked := strings.Fields(testutils.RunGit(t, machine, "ls-files"))
+	want := []string{".dotmanignore", ".gitignore", "info.json", "machines.json", "nvim/init.lua"}
+	if !slices.Equal(tracked, want) {
+		t.Errorf("tracked files = %v, want %v", tracked, want)
+	}
+	testutils.AssertFileExists(t, filepath.Join(machine, "nvim", "lazy-lock.json"))
+
+	// The machine's own patterns stay out of the committed .gitignore, the lines already in it are kept
+	gitignore, err := os.ReadFile(filepath.Join(machine, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(gitignore)
+	if !strings.HasPrefix(content, "secrets\n") || !strings.Contains(content, "lazy-lock.json\n") ||
+		strings.Contains(content, "cache/") {
+		t.Errorf(".gitignore = %q, want secrets and the shared patterns only", gitignore)
+	}
+	exclude, err := os.ReadFile(filepath.Join(machine, ".git", "info", "exclude"))
+	if err != nil || !strings.Contains(string(exclude), "cache/\n") {
+		t.Errorf(".git/info/exclude = %q, %v, want the local patterns", exclude, err)
+	}
+}
+
+func TestSyncRepoLocalIgnoreKeepsTrackedFiles(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	machine := filepath.Join(testDir, "machine")
+	testutils.CloneGitRemote(t, remote, machine)
+	testutils.CreateTestFile(t, filepath.Join(machine, "work", "gitconfig"), "[user]\n")
+	testutils.RunGit(t, machine, "add", "-A")
+	testutils.RunGit(t, machine, "commit", "-m", "work config")
+
+	// Only this machine ignores work/, every other machine keeps syncing it
+	previous := ignorePatterns
+	SetIgnorePatterns([]string{"work/"})
+	t.Cleanup(func() { SetIgnorePatterns(previous) })
+
+	if err := SyncRepo(machine, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() error = %v", err)
+	}
+	tracked := strings.Fields(testutils.RunGit(t, remote, "ls-tree", "-r", "--name-only", "main"))
+	if !slices.Contains(tracked, "work/gitconfig") {
+		t.Errorf("tracked files on the remote = %v, want work/gitconfig kept", tracked)
+	}
+}
+
+func TestAddFileIgnored(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	home := filepath.Join(testDir, "home")
+	dotfiles := filepath.Join(testDir, "dotfiles")
+	testutils.CreateTestFile(t, filepath.Join(dotfiles, "info.json"), "{}")
+	testutils.CreateTestFile(t, filepath.Join(dotfiles, ".dotmanignore"), "lazy-lock.json\n")
+
+	swap := filepath.Join(home, ".vimrc.swp")
+	testutils.CreateTestFile(t, swap, "swap")
+	err := AddFile(prompt.NonInteractive{}, swap, dotfiles, false)
+	if err == nil || !strings.Contains(err.Error(), "is ignored") {
+		t.Errorf("AddFile() error = %v, want the swap file ignored", err)
+	}
+	testutils.AssertFileNotExists(t, filepath.Join(dotfiles, ".vimrc.swp"))
+
+	// A directory is added with its ignored files, they stay in it but are not synced
+	nvim := filepath.Join(home, "nvim")
+	testutils.CreateTestFile(t, filepath.Join(nvim, "init.lua"), "vim.o.number = true\n")
+	testutils.CreateTestFile(t, filepath.Join(nvim, "lazy-lock.json"), "{}")
+	if err := AddFile(prompt.NonInteractive{}, nvim, dotfiles, false); err != nil {
+		t.Fatalf("AddFile() error = %v", err)
+	}
+	testutils.AssertSymlink(t, nvim, filepath.Join(dotfiles, "nvim"))
+	testutils.AssertFileExists(t, filepath.Join(dotfiles, "nvim", "lazy-lock.json"))
+}
@@ -0,0 +1,358 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/ignore"
+	"github.com/ZonCen/dotman/internal/importer"
+	"github.com/ZonCen/dotman/internal/prompt"
+)
+
+// ImportDotfiles converts dotfiles kept by stow, chezmoi or a bare repository at source into entries of the
+// repository at folderPath and links them from target, usually the home directory. The old layout is left as it
+// was, live files that get replaced by links are moved to a backup first
+func ImportDotfiles(p prompt.Prompter, folderPath, from, source, target string) error {
+	var (
+		found   []importer.File
+		skipped []importer.Skipped
+		err     error
+	)
+	switch from {
+	case importer.FromStow:
+		found, skipped, err = importer.Stow(source, target)
+	case importer.FromChezmoi:
+		found, skipped, err = importer.Chezmoi(source, target)
+	case importer.FromBare, importer.FromYadm:
+		var tracked []string
+		tracked, err = gitBackend.ListFiles(source, "HEAD")
+		found = importer.Bare(target, tracked)
+	default:
+		return fmt.Errorf("unknown layout %q, use %v, %v, %v or %v", from, importer.FromStow, importer.FromChezmoi,
+			importer.FromBare, importer.FromYadm)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read %v: %w", source, err)
+	}
+	internal.LogVerbose("Found %d file(s) to import from %v", len(found), source)
+
+	if !internal.FolderExist(folderPath) {
+		return fmt.Errorf("%v does not exist, run 'dotman init' first", folderPath)
+	}
+	infoPath := filepath.Join(folderPath, "info.json")
+	if !internal.FileExist(infoPath) {
+		if err := files.SaveStatus(infoPath, map[string]files.FileInfo{}); err != nil {
+			return err
+		}
+	}
+	fileInfo, err := files.ReadFile(infoPath)
+	if err != nil {
+		return fmt.Errorf("could not read info.json: %w", err)
+	}
+
+	matcher, err := loadIgnore(folderPath)
+	if err != nil {
+		return err
+	}
+
+	imp := &importRun{
+		deployer:   newDeployer(p),
+		ignore:     matcher,
+		folderPath: folderPath,
+		source:     source,
+		target:     target,
+		fileInfo:   fileInfo,
+		imported:   map[string]files.FileInfo{},
+	}
+	defer imp.report()
+	for _, file := range found {
+		name, err := imp.importFile(file)
+		if errors.Is(err, errKeptSourceLink) {
+			fmt.Printf("Kept %v linked to %v, not imported\n", internal.ShrinkPath(file.Target),
+				internal.ShrinkPath(source))
+			continue
+		}
+		if err != nil {
+			skipped = append(skipped, importer.Skipped{Path: file.Target, Reason: err.Error()})
+			continue
+		}
+		fmt.Printf("Imported %v -> %v\n", name, internal.ShrinkPath(file.Target))
+	}
+
+	if len(imp.imported) > 0 {
+		if err := files.AddFiles(infoPath, imp.imported); err != nil {
+			return fmt.Errorf("could not save imported entries: %w", err)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Printf("Skipped %d file(s):\n", len(skipped))
+		for _, skip := range skipped {
+			fmt.Printf("  %v: %v\n", internal.ShrinkPath(skip.Path), skip.Reason)
+		}
+	}
+	fmt.Printf("Imported %d file(s) from %v, it is left untouched and can be removed once everything works\n",
+		len(imp.imported), internal.ShrinkPath(source))
+	return nil
+}
+
+// importRun is one import, it keeps the entries added so far so names stay unique
+type importRun struct {
+	*deployer
+	ignore     *ignore.Matcher
+	folderPath string
+	source     string
+	target     string
+	fileInfo   map[string]files.FileInfo
+	imported   map[string]files.FileInfo
+}
+
+// importFile copies a file into the repository and links it in place, returning the entry name
+func (r *importRun) importFile(file importer.File) (string, error) {
+	for name, info := range r.fileInfo {
+		if info.Symlink == file.Target && info.Tombstone == nil {
+			return "", fmt.Errorf("already tracked as %v", name)
+		}
+	}
+	name, repoPath, err := r.entryName(file.Target)
+	if err != nil {
+		return "", err
+	}
+	if r.ignore.Match(name, false) {
+		return "", fmt.Errorf("ignored by %v or the ignore setting", ignore.File)
+	}
+
+	// Linking inside a directory stow folded into a single link would write into the stow package
+	if err := r.unfold(file.Target); err != nil {
+		return "", err
+	}
+	live, err := r.liveState(file.Target)
+	if err != nil {
+		return "", err
+	}
+
+	internal.LogVerbose("Copying %v into %v", file.Target, repoPath)
+	if err := internal.CreateFolder(filepath.Dir(repoPath)); err != nil {
+		return "", err
+	}
+	if err := r.copyContent(file, live, repoPath); err != nil {
+		_ = os.Remove(repoPath)
+		return "", err
+	}
+
+	// The old layout keeps its copy, only its link is swapped for ours
+	sourceLink := ""
+	if live == liveSourceLink {
+		if sourceLink, err = os.Readlink(file.Target); err != nil {
+			_ = os.Remove(repoPath)
+			return "", fmt.Errorf("could not read %v: %w", file.Target, err)
+		}
+		if err := os.Remove(file.Target); err != nil {
+			_ = os.Remove(repoPath)
+			return "", fmt.Errorf("could not remove %v: %w", file.Target, err)
+		}
+	}
+	info := files.FileInfo{Symlink: file.Target, Path: repoPath, Status: "ok"}
+	linked, err := r.link(name, info)
+	if err == nil && !linked && sourceLink != "" {
+		err = errKeptSourceLink
+	}
+	if err != nil {
+		_ = os.Remove(repoPath)
+		if restoreErr := restoreSourceLink(file.Target, sourceLink); restoreErr != nil {
+			return "", fmt.Errorf("%w, %w", err, restoreErr)
+		}
+		return "", err
+	}
+	r.imported[name] = info
+	return name, nil
+}
+
+// errKeptSourceLink is returned when a file was not linked on purpose, for example because a layer above
+// overrides it, it keeps the link into the old layout
+var errKeptSourceLink = errors.New("kept the link into the old layout, not imported")
+
+// restoreSourceLink puts the link of the old layout back at target when linking it failed, so the machine keeps
+// running with it
+func restoreSourceLink(target, sourceLink string) error {
+	if sourceLink == "" {
+		return nil
+	}
+	if _, err := os.Lstat(target); err == nil {
+		return nil
+	}
+	internal.LogVerbose("Restoring the link %v -> %v", target, sourceLink)
+	if err := os.Symlink(sourceLink, target); err != nil {
+		return fmt.Errorf("could not restore the link to %v: %w", sourceLink, err)
+	}
+	return nil
+}
+
+// entryName names an entry after the file like add does, falling back to its path below target when the
+// name is taken
+func (r *importRun) entryName(target string) (string, string, error) {
+	candidates := []string{filepath.Base(target)}
+	if rel, err := filepath.Rel(r.target, target); err == nil && !strings.HasPrefix(rel, "..") {
+		candidates = append(candidates, filepath.ToSlash(rel))
+	}
+	for _, name := range candidates {
+		_, tracked := r.fileInfo[name]
+		_, imported := r.imported[name]
+		repoPath := filepath.Join(r.folderPath, filepath.FromSlash(name))
+		if _, err := os.Lstat(repoPath); err == nil || tracked || imported {
+			continue
+		}
+		return name, repoPath, nil
+	}
+	return "", "", fmt.Errorf("%v is already taken in the repository", candidates[len(candidates)-1])
+}
+
+// States of the file at an import target
+const (
+	liveMissing = iota
+	liveFile
+	liveSourceLink
+)
+
+func (r *importRun) liveState(target string) (int, error) {
+	stat, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return liveMissing, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	switch {
+	case stat.Mode().IsRegular():
+		return liveFile, nil
+	case stat.Mode()&os.ModeSymlink != 0:
+		if r.inSource(target) {
+			return liveSourceLink, nil
+		}
+		resolved, _ := os.Readlink(target)
+		return 0, fmt.Errorf("is a symlink to %v", resolved)
+	}
+	return 0, fmt.Errorf("is not a regular file")
+}
+
+// copyContent writes the file into the repository, the live file wins over the old layout since that is what
+// the machine runs with
+func (r *importRun) copyContent(file importer.File, live int, repoPath string) error {
+	if live == liveFile {
+		return copyFile(file.Target, repoPath)
+	}
+	if file.Source != "" {
+		if err := copyFile(file.Source, repoPath); err != nil {
+			return err
+		}
+		return os.Chmod(repoPath, file.Mode)
+	}
+	if file.GitPath == "" {
+		return fmt.Errorf("has no content to import")
+	}
+	content, err := gitBackend.ShowFile(r.source, "HEAD", file.GitPath)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if err := os.WriteFile(repoPath, []byte(content), file.Mode); err != nil {
+		return fmt.Errorf("could not write %v: %w", repoPath, err)
+	}
+	return nil
+}
+
+// inSource reports whether path resolves to something inside the old layout
+func (r *importRun) inSource(path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	source, err := filepath.EvalSymlinks(r.source)
+	if err != nil {
+		return false
+	}
+	return resolved == source || isWithin(resolved, source)
+}
+
+// unfold replaces directory links above target that point into the old layout with real directories holding a
+// link per entry, as stow does when it unfolds a tree
+func (r *importRun) unfold(target string) error {
+	rel, err := filepath.Rel(r.target, filepath.Dir(target))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+
+	dir := r.target
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		stat, err := os.Lstat(dir)
+		if err != nil {
+			return nil
+		}
+		if stat.Mode()&os.ModeSymlink == 0 || !r.inSource(dir) {
+			continue
+		}
+
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		resolvedStat, err := os.Stat(resolved)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		entries, err := os.ReadDir(resolved)
+		if err != nil {
+			return fmt.Errorf("could not read %v: %w", resolved, err)
+		}
+		link, err := os.Readlink(dir)
+		if err != nil {
+			return fmt.Errorf("could not read %v: %w", dir, err)
+		}
+		internal.LogVerbose("Unfolding %v, it links to %v", dir, resolved)
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("could not unfold %v: %w", dir, err)
+		}
+		if err := unfoldDir(dir, resolved, resolvedStat.Mode().Perm(), entries); err != nil {
+			return restoreFolded(dir, link, err)
+		}
+	}
+	return nil
+}
+
+// unfoldDir creates dir with a link for each entry of the directory resolved it was folded into
+func unfoldDir(dir, resolved string, perm os.FileMode, entries []os.DirEntry) error {
+	if err := os.Mkdir(dir, perm); err != nil {
+		return fmt.Errorf("could not unfold %v: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if err := os.Symlink(filepath.Join(resolved, entry.Name()), filepath.Join(dir, entry.Name())); err != nil {
+			return fmt.Errorf("could not unfold %v: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// restoreFolded puts the directory link back when unfolding it failed halfway. Only the links created so far and
+// the then empty directory are removed, they point into the old layout so its files are left alone
+func restoreFolded(dir, link string, err error) error {
+	internal.LogVerbose("Restoring the link %v -> %v", dir, link)
+	entries, readErr := os.ReadDir(dir)
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink != 0 {
+			_ = os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+	if readErr == nil {
+		if removeErr := os.Remove(dir); removeErr != nil {
+			return fmt.Errorf("%w, and could not remove it to restore the link to %v: %w", err, link, removeErr)
+		}
+	}
+	if linkErr := os.Symlink(link, dir); linkErr != nil {
+		return fmt.Errorf("%w, and could not restore the link to %v: %w", err, link, linkErr)
+	}
+	return err
+}
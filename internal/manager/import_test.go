@@ -0,0 +1,224 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/importer"
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func importedEntries(t *testing.T, repo string) map[string]files.FileInfo {
+	fileInfo, err := files.ReadFile(filepath.Join(repo, "info.json"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return fileInfo
+}
+
+func TestImportStow(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	home := filepath.Join(testDir, "home")
+	repo := filepath.Join(testDir, "repo")
+	stow := filepath.Join(home, "stow")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testutils.CreateTestFile(t, filepath.Join(stow, "zsh", ".zshrc"), "zsh\n")
+	testutils.CreateTestFile(t, filepath.Join(stow, "nvim", ".config", "nvim", "init.lua"), "lua\n")
+	testutils.CreateTestFile(t, filepath.Join(stow, "nvim", ".config", "nvim", "lazy.lua"), "lazy\n")
+	// As stowed: a link per file at the top, .config/nvim folded into a single directory link
+	if err := os.MkdirAll(filepath.Join(home, ".config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	testutils.CreateTestSymlink(t, filepath.Join(home, ".zshrc"), filepath.Join(stow, "zsh", ".zshrc"))
+	testutils.CreateTestSymlink(t, filepath.Join(home, ".config", "nvim"), filepath.Join(stow, "nvim", ".config", "nvim"))
+
+	if err := ImportDotfiles(prompt.NonInteractive{}, repo, importer.FromStow, stow, home); err != nil {
+		t.Fatalf("ImportDotfiles() error = %v", err)
+	}
+
+	fileInfo := importedEntries(t, repo)
+	for name, content := range map[string]string{".zshrc": "zsh\n", "init.lua": "lua\n", "lazy.lua": "lazy\n"} {
+		info, ok := fileInfo[name]
+		if !ok {
+			t.Fatalf("entry %v missing, have %v", name, fileInfo)
+		}
+		testutils.AssertFileContent(t, info.Path, content)
+		testutils.AssertSymlink(t, info.Symlink, info.Path)
+	}
+	if stat, err := os.Lstat(filepath.Join(home, ".config", "nvim")); err != nil || !stat.IsDir() {
+		t.Errorf("~/.config/nvim = %v, %v, want the folded link replaced by a directory", stat, err)
+	}
+	// The stow directory is left as it was
+	testutils.AssertFileContent(t, filepath.Join(stow, "nvim", ".config", "nvim", "init.lua"), "lua\n")
+	testutils.AssertFileContent(t, filepath.Join(stow, "zsh", ".zshrc"), "zsh\n")
+
+	// Importing again leaves the tracked files alone
+	if err := ImportDotfiles(prompt.NonInteractive{}, repo, importer.FromStow, stow, home); err != nil {
+		t.Fatalf("ImportDotfiles() again error = %v", err)
+	}
+	if got := importedEntries(t, repo); len(got) != 3 {
+		t.Errorf("entries after a second import = %v, want 3", got)
+	}
+}
+
+func TestImportStowKeepsLinkWhenLinkingFails(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	home := filepath.Join(testDir, "home")
+	repo := filepath.Join(testDir, "repo")
+	stow := filepath.Join(home, "stow")
+	zshrc := filepath.Join(home, ".zshrc")
+	testutils.CreateTestFile(t, filepath.Join(repo, "info.json"), "{}")
+	testutils.CreateTestFile(t, filepath.Join(stow, "zsh", ".zshrc"), "zsh\n")
+	testutils.CreateTestSymlink(t, zshrc, filepath.Join(stow, "zsh", ".zshrc"))
+
+	// Another repository tracks the target, so linking it fails
+	personal := filepath.Join(testDir, "personal", "info.json")
+	testutils.CreateTestFile(t, personal, `{".zshrc": {"symlink": "`+zshrc+`", "path": "`+
+		filepath.Join(testDir, "personal", ".zshrc")+`"}}`)
+	previous := otherRepos
+	SetOtherRepos(map[string]string{"personal": personal})
+	t.Cleanup(func() { SetOtherRepos(previous) })
+
+	if err := ImportDotfiles(prompt.NonInteractive{}, repo, importer.FromStow, stow, home); err != nil {
+		t.Fatalf("ImportDotfiles() error = %v", err)
+	}
+	testutils.AssertSymlink(t, zshrc, filepath.Join(stow, "zsh", ".zshrc"))
+	testutils.AssertFileNotExists(t, filepath.Join(repo, ".zshrc"))
+	if got := importedEntries(t, repo); len(got) != 0 {
+		t.Errorf("entries = %v, want none", got)
+	}
+}
+
+func TestImportStowKeepsLinkOverriddenByLayer(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	home := filepath.Join(testDir, "home")
+	team := filepath.Join(testDir, "team")
+	personal := filepath.Join(testDir, "personal")
+	stow := filepath.Join(home, "stow")
+	zshrc := filepath.Join(home, ".zshrc")
+	testutils.CreateTestFile(t, filepath.Join(team, "info.json"), "{}")
+	testutils.CreateTestFile(t, filepath.Join(stow, "zsh", ".zshrc"), "zsh\n")
+	testutils.CreateTestSymlink(t, zshrc, filepath.Join(stow, "zsh", ".zshrc"))
+	testutils.CreateTestFile(t, filepath.Join(personal, "info.json"), `{".zshrc": {"symlink": "`+zshrc+`", "path": "`+
+		filepath.Join(personal, ".zshrc")+`"}}`)
+
+	// Importing into team, the layer below personal, leaves the stow link alone without failing
+	stack := []Layer{{Name: "team", InfoPath: filepath.Join(team, "info.json")},
+		{Name: "personal", InfoPath: filepath.Join(personal, "info.json")}}
+	previous, previousLayers, previousRepo := otherRepos, layers, currentRepo
+	t.Cleanup(func() {
+		SetOtherRepos(previous)
+		SetLayers(previousRepo, previousLayers)
+	})
+	SetOtherRepos(map[string]string{"personal": stack[1].InfoPath})
+	SetLayers("team", stack)
+
+	if err := ImportDotfiles(prompt.NonInteractive{}, team, importer.FromStow, stow, home); err != nil {
+		t.Fatalf("ImportDotfiles() error = %v", err)
+	}
+	testutils.AssertSymlink(t, zshrc, filepath.Join(stow, "zsh", ".zshrc"))
+	testutils.AssertFileNotExists(t, filepath.Join(team, ".zshrc"))
+	if got := importedEntries(t, team); len(got) != 0 {
+		t.Errorf("entries = %v, want none", got)
+	}
+}
+
+func TestRestoreFolded(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	// Unfolding ~/.config/nvim failed after linking one of its files
+	source := filepath.Join(testDir, "stow", "nvim", ".config", "nvim")
+	testutils.CreateTestFile(t, filepath.Join(source, "init.lua"), "lua\n")
+	dir := filepath.Join(testDir, "home", ".config", "nvim")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	testutils.CreateTestSymlink(t, filepath.Join(dir, "init.lua"), filepath.Join(source, "init.lua"))
+
+	failure := errors.New("could not unfold")
+	if err := restoreFolded(dir, source, failure); !errors.Is(err, failure) {
+		t.Fatalf("restoreFolded() error = %v, want the unfold error", err)
+	}
+	testutils.AssertSymlink(t, dir, source)
+	testutils.AssertFileContent(t, filepath.Join(source, "init.lua"), "lua\n")
+}
+
+func TestImportChezmoiBacksUpLiveFile(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	home := filepath.Join(testDir, "home")
+	repo := filepath.Join(testDir, "repo")
+	source := filepath.Join(testDir, "chezmoi")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testutils.CreateTestFile(t, filepath.Join(source, "dot_gitconfig"), "source\n")
+	testutils.CreateTestFile(t, filepath.Join(source, "private_dot_ssh", "config"), "ssh\n")
+	testutils.CreateTestFile(t, filepath.Join(source, "private_dot_netrc"), "netrc\n")
+	// chezmoi applied a copy that was edited since
+	testutils.CreateTestFile(t, filepath.Join(home, ".gitconfig"), "live\n")
+
+	if err := ImportDotfiles(prompt.NonInteractive{}, repo, importer.FromChezmoi, source, home); err != nil {
+		t.Fatalf("ImportDotfiles() error = %v", err)
+	}
+
+	fileInfo := importedEntries(t, repo)
+	testutils.AssertFileContent(t, fileInfo[".gitconfig"].Path, "live\n")
+	testutils.AssertSymlink(t, filepath.Join(home, ".gitconfig"), fileInfo[".gitconfig"].Path)
+	testutils.AssertFileContent(t, fileInfo["config"].Path, "ssh\n")
+	testutils.AssertSymlink(t, filepath.Join(home, ".ssh", "config"), fileInfo["config"].Path)
+	if stat, err := os.Stat(fileInfo[".netrc"].Path); err != nil || stat.Mode().Perm() != 0600 {
+		t.Errorf("Stat(.netrc) = %v, %v, want mode 0600 from private_", stat, err)
+	}
+
+	// The live file was backed up before it was replaced
+	if backups, err := ListBackups(); err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups() = %v, %v, want one backup", backups, err)
+	}
+	testutils.AssertFileContent(t, filepath.Join(source, "dot_gitconfig"), "source\n")
+}
+
+func TestImportBare(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	home := filepath.Join(testDir, "home")
+	repo := filepath.Join(testDir, "repo")
+	bare := filepath.Join(testDir, "dotfiles.git")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fake := useFake(t)
+	fake.Tracked = []string{".bashrc", ".vimrc"}
+	fake.Files = map[string]string{"HEAD:.vimrc": "committed\n"}
+	testutils.CreateTestFile(t, filepath.Join(home, ".bashrc"), "live\n")
+
+	if err := ImportDotfiles(prompt.NonInteractive{}, repo, importer.FromBare, bare, home); err != nil {
+		t.Fatalf("ImportDotfiles() error = %v", err)
+	}
+
+	fileInfo := importedEntries(t, repo)
+	testutils.AssertFileContent(t, fileInfo[".bashrc"].Path, "live\n")
+	// A file deleted from the worktree is restored from the last commit
+	testutils.AssertFileContent(t, fileInfo[".vimrc"].Path, "committed\n")
+	testutils.AssertSymlink(t, filepath.Join(home, ".vimrc"), fileInfo[".vimrc"].Path)
+}
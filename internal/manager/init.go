@@ -3,19 +3,31 @@ package manager
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/ZonCen/dotman/internal"
 	"github.com/ZonCen/dotman/internal/files"
 	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/prompt"
 )
 
-func Init(folderPath, repository, branch string, force bool) error {
+// defaultNewBranch is the branch created when the remote is empty and no branch was asked for
+const defaultNewBranch = "main"
+
+// Init prepares folderPath as the dotfiles repository for repository and returns the branch it uses.
+// An empty branch picks the default branch of the remote
+func Init(p prompt.Prompter, folderPath, repository, branch string, force bool) (string, error) {
 	internal.LogVerbose("Checking if %v exist", folderPath)
 	if !internal.FolderExist(folderPath) {
-		if internal.ConfirmWithUser("FolderPath does not exists, do you want to create one? ") {
+		create, err := p.Confirm("init.create-folder", "FolderPath does not exists, do you want to create one? ")
+		if err != nil {
+			return "", err
+		}
+		if create {
 			err := internal.CreateFolder(folderPath)
 			if err != nil {
-				return fmt.Errorf("error creating folder %w", err)
+				return "", fmt.Errorf("error creating folder %w", err)
 			}
 		}
 	}
@@ -23,99 +35,218 @@ func Init(folderPath, repository, branch string, force bool) error {
 	internal.LogVerbose("Checking if repository is empty or not: %v", repository)
 	if repository != "" {
 		internal.LogVerbose("Checking if %v is inside a working tree", folderPath)
-		ok, _ := git.CheckIfRepo(folderPath)
-		if ok == 128 {
+		isRepo, err := gitBackend.IsRepo(folderPath)
+		if err != nil {
+			return "", fmt.Errorf("%w", err)
+		}
+		if !isRepo {
 			internal.LogVerbose("Running initialization of the repository at %v", folderPath)
-			if internal.ConfirmWithUser("The folder has not been initialized to git, do you want to initialize it? ") {
+			initialize, err := p.Confirm("init.git-init",
+				"The folder has not been initialized to git, do you want to initialize it? ")
+			if err != nil {
+				return "", err
+			}
+			if initialize {
 				internal.LogVerbose("Running git init")
-				_, err := git.Init(folderPath)
+				err := gitBackend.Init(folderPath)
 				if err != nil {
-					return fmt.Errorf("could not initialize repository: %w", err)
+					return "", fmt.Errorf("could not initialize repository: %w", err)
 				}
 				fmt.Println("Folder has been initialized")
 			}
-			urls, err := checkRemote(folderPath, repository)
+			urls, err := checkRemote(p, folderPath, repository)
 			if err != nil {
-				return fmt.Errorf("%w", err)
+				return "", fmt.Errorf("%w", err)
 			}
 
-			if urls != "" && internal.ConfirmWithUser("Do you want to run git fetch, checkout and pull? ") {
-				internal.LogVerbose("Running git fetch origin")
-				_, err := git.FetchOrigin(folderPath)
-				if err != nil {
-					return fmt.Errorf("could not fetch Origin: %w", err)
-				}
-				internal.LogVerbose("Running git checkout -b %v --track origin/%v", branch, branch)
-				_, err = git.FirstCheckout(folderPath, branch)
+			checkout := false
+			if urls != "" {
+				checkout, err = p.Confirm("init.checkout", "Do you want to run git fetch, checkout and pull? ")
 				if err != nil {
-					return fmt.Errorf("could not checkout: %w", err)
+					return "", err
 				}
-				internal.LogVerbose("Running git pull --ff-only")
-				_, err = git.Pull(folderPath)
+			}
+			if checkout {
+				branch, err = checkoutRemote(folderPath, branch)
 				if err != nil {
-					return fmt.Errorf("could not pull from repository: %w", err)
+					return "", err
 				}
-				fmt.Println("Files has been downloaded")
 			}
-		} else if ok == 0 {
-			currentURL, err := checkRemote(folderPath, repository)
+		} else {
+			currentURL, err := checkRemote(p, folderPath, repository)
 			if err != nil {
-				return fmt.Errorf("%w", err)
+				return "", fmt.Errorf("%w", err)
 			}
-			internal.LogVerbose("Checking if %v and %v has the same prefix or are the same", currentURL, repository)
-			if !internal.SamePrefix(currentURL, repository) {
-				internal.LogVerbose("Changing %v to match %v", currentURL, repository)
-				currentURL = internal.NormaliseRepoURL(currentURL)
-				internal.LogVerbose("Checking if %v and %v has the same suffix", currentURL, repository)
-				if !internal.SameSuffix(currentURL, repository) {
-					internal.LogVerbose("Changing suffix of %v to match %v", currentURL, repository)
-					currentURL = internal.NormaliseRepoSuffix(currentURL)
-				}
-				internal.LogVerbose("Checking if %v and %v is similar, and if force (%v) is active", currentURL, repository, force)
-				if currentURL == repository && !force {
-					fmt.Println("Folder already initialized to correct git")
-				} else if currentURL == repository && force {
-					if internal.ConfirmWithUser("Folder initialized to another repository. Do you want to change it? (y/N)") {
-						internal.LogVerbose("Changing repository to %v", repository)
-						_, err := git.ChangeRemote(folderPath, repository)
-						if err != nil {
-							return fmt.Errorf("error changing remote: %w", err)
-						}
-					}
-				}
-			} else if currentURL == repository {
-				fmt.Println("URLs are already the same")
-			} else {
-				return fmt.Errorf("unknown error when checking Repository")
+			if err := reconcileRemote(p, folderPath, currentURL, repository, force); err != nil {
+				return "", err
 			}
 		}
-		if internal.ConfirmWithUser("Do you want to add the symlinks to the correct paths? ") {
+		link, err := p.Confirm("init.symlinks", "Do you want to add the symlinks to the correct paths? ")
+		if err != nil {
+			return "", err
+		}
+		if link {
 			internal.LogVerbose("Adding symlinks to the correct paths")
-			err := addSymlinks(folderPath)
+			err := addSymlinks(p, folderPath)
 			if err != nil {
-				return fmt.Errorf("could not add symlinks: %w", err)
+				return "", fmt.Errorf("could not add symlinks: %w", err)
 			}
 			internal.LogVerbose("Symlinks has been added")
 		}
 	} else {
-		return fmt.Errorf("repository is empty")
+		return "", fmt.Errorf("repository is empty")
 	}
 
+	if branch == "" {
+		// A repository without commits has no branch to report yet
+		if current, err := gitBackend.CurrentBranch(folderPath); err == nil && current != "HEAD" {
+			branch = current
+		}
+	}
+	return branch, nil
+}
+
+// reconcileRemote compares the remote the folder already has with the requested one. The same repository over
+// another transport is only switched with force, a different repository is refused unless forced
+func reconcileRemote(p prompt.Prompter, folderPath, currentURL, repository string, force bool) error {
+	if currentURL == "" {
+		return nil
+	}
+	internal.LogVerbose("Comparing %v with %v", currentURL, repository)
+	if currentURL == repository {
+		fmt.Println("URLs are already the same")
+		return nil
+	}
+
+	if git.SameRepository(currentURL, repository) {
+		if !force {
+			fmt.Printf("Folder already initialized to correct git (%v), use --force to switch to %v\n",
+				currentURL, repository)
+			return nil
+		}
+		change, err := p.Confirm("init.switch-transport",
+			fmt.Sprintf("Do you want to change %v to %v? (y/N)", currentURL, repository))
+		if err != nil || !change {
+			return err
+		}
+	} else {
+		if !force {
+			return fmt.Errorf("folder is initialized to another repository (%v), use --force to change it to %v",
+				currentURL, repository)
+		}
+		change, err := p.Confirm("init.change-remote",
+			"Folder initialized to another repository. Do you want to change it? (y/N)")
+		if err != nil || !change {
+			return err
+		}
+	}
+
+	internal.LogVerbose("Changing repository to %v", repository)
+	if err := gitBackend.ChangeRemote(folderPath, primaryRemote, repository); err != nil {
+		return fmt.Errorf("error changing remote: %w", err)
+	}
 	return nil
 }
 
-func checkRemote(folderPath, repository string) (string, error) {
+// checkoutRemote checks out branch from the primary remote, an empty branch uses the remote's default branch.
+// An empty remote is bootstrapped with a first commit holding info.json instead
+func checkoutRemote(folderPath, branch string) (string, error) {
+	internal.LogVerbose("Listing the branches of %v", primaryRemote)
+	refs, err := gitBackend.ListRemote(folderPath, primaryRemote)
+	if err != nil {
+		return "", fmt.Errorf("could not list %v: %w", primaryRemote, explainGitError(err))
+	}
+	if refs.Empty() {
+		return bootstrapRemote(folderPath, branch)
+	}
+
+	if branch == "" {
+		if branch, err = defaultBranch(refs); err != nil {
+			return "", err
+		}
+		internal.LogVerbose("Using %v, the default branch of %v", branch, primaryRemote)
+	}
+	if !slices.Contains(refs.Branches, branch) {
+		return "", fmt.Errorf("branch %v does not exist on %v, choose one of %v with --branch",
+			branch, primaryRemote, strings.Join(refs.Branches, ", "))
+	}
+
+	internal.LogVerbose("Running git fetch %v", primaryRemote)
+	if err := gitBackend.Fetch(folderPath, primaryRemote); err != nil {
+		return "", fmt.Errorf("could not fetch %v: %w", primaryRemote, explainGitError(err))
+	}
+	internal.LogVerbose("Running git checkout -b %v --track %v/%v", branch, primaryRemote, branch)
+	if err := gitBackend.FirstCheckout(folderPath, primaryRemote, branch); err != nil {
+		return "", fmt.Errorf("could not checkout: %w", err)
+	}
+	internal.LogVerbose("Running git pull --ff-only")
+	if err := gitBackend.Pull(folderPath, primaryRemote, branch, git.StrategyFFOnly, false); err != nil {
+		return "", fmt.Errorf("could not pull from repository: %w", explainGitError(err))
+	}
+	fmt.Println("Files has been downloaded")
+	return branch, nil
+}
+
+// defaultBranch picks the branch a remote's HEAD points at, falling back to its only branch, main or master
+func defaultBranch(refs git.RemoteRefs) (string, error) {
+	if refs.Head != "" && slices.Contains(refs.Branches, refs.Head) {
+		return refs.Head, nil
+	}
+	if len(refs.Branches) == 1 {
+		return refs.Branches[0], nil
+	}
+	for _, name := range []string{"main", "master"} {
+		if slices.Contains(refs.Branches, name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("could not tell the default branch of %v, choose one of %v with --branch",
+		primaryRemote, strings.Join(refs.Branches, ", "))
+}
+
+// bootstrapRemote publishes the first commit, with an empty info.json, to a remote that has no branches yet
+func bootstrapRemote(folderPath, branch string) (string, error) {
+	if branch == "" {
+		branch = defaultNewBranch
+	}
+	fmt.Printf("%v is empty, publishing a first commit on %v\n", primaryRemote, branch)
+
+	infoPath := filepath.Join(folderPath, "info.json")
+	if !internal.FileExist(infoPath) {
+		internal.LogVerbose("Creating an empty %v", infoPath)
+		if err := files.SaveStatus(infoPath, map[string]files.FileInfo{}); err != nil {
+			return "", fmt.Errorf("%w", err)
+		}
+	}
+	if err := commitChanges(folderPath, "dotman init"); err != nil {
+		return "", err
+	}
+	if err := gitBackend.RenameBranch(folderPath, branch); err != nil {
+		return "", fmt.Errorf("could not name the branch %v: %w", branch, err)
+	}
+	internal.LogVerbose("Pushing %v to %v and setting it as upstream", branch, primaryRemote)
+	if err := gitBackend.Push(folderPath, primaryRemote, branch, true); err != nil {
+		return "", fmt.Errorf("could not push the first commit: %w", explainGitError(err))
+	}
+	return branch, nil
+}
+
+func checkRemote(p prompt.Prompter, folderPath, repository string) (string, error) {
 	internal.LogVerbose("Checking if we can locate Remote URLs at %v", folderPath)
-	urls, err := git.GetRemoteURL(folderPath)
+	urls, err := gitBackend.GetRemoteURL(folderPath, primaryRemote)
 	if err != nil {
-		if internal.ConfirmWithUser("Folder has no remote, do you want to add the remote repository? ") {
-			internal.LogVerbose("Running git remote add origin %v", repository)
-			_, err := git.AddRemote(folderPath, repository)
+		add, err := p.Confirm("init.add-remote", "Folder has no remote, do you want to add the remote repository? ")
+		if err != nil {
+			return "", err
+		}
+		if add {
+			internal.LogVerbose("Running git remote add %v %v", primaryRemote, repository)
+			err := gitBackend.AddRemote(folderPath, primaryRemote, repository)
 			if err != nil {
-				return "", fmt.Errorf("could not add origin to the repository: %w", err)
+				return "", fmt.Errorf("could not add %v to the repository: %w", primaryRemote, err)
 			}
 			internal.LogVerbose("Checking so remotes has been added at %v", folderPath)
-			urls, err = git.GetRemoteURL(folderPath)
+			urls, err = gitBackend.GetRemoteURL(folderPath, primaryRemote)
 			if err != nil {
 				return "", fmt.Errorf("could not find remote urls: %w", err)
 			}
@@ -125,20 +256,29 @@ func checkRemote(folderPath, repository string) (string, error) {
 	return urls, nil
 }
 
-func addSymlinks(folderPath string) error {
+// addSymlinks links every entry of a freshly initialized repository, asking what to do with files that
+// already exist on this machine
+func addSymlinks(p prompt.Prompter, folderPath string) error {
 	errors := make(map[string]string)
-	files, err := files.ReadFile(filepath.Join(folderPath, "info.json"))
+	fileInfo, err := files.ReadFile(filepath.Join(folderPath, "info.json"))
 	if err != nil {
 		return fmt.Errorf("could not read files: %w", err)
 	}
-	for _, file := range files {
-		if internal.FileExist(file.Path) {
-			err := internal.CreateSymlink(file.Symlink, file.Path)
-			if err != nil {
-				errors[file.Symlink] = err.Error()
-			}
-		} else {
-			return fmt.Errorf("file %v does not exist", file.Path)
+	names := make([]string, 0, len(fileInfo))
+	for name := range fileInfo {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	d := newDeployer(p)
+	defer d.report()
+	for _, name := range names {
+		info := fileInfo[name]
+		if info.Tombstone != nil {
+			continue
+		}
+		if _, err := d.link(name, info); err != nil {
+			errors[name] = err.Error()
 		}
 	}
 	if len(errors) > 0 {
@@ -0,0 +1,114 @@
+package manager
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestReconcileRemote(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    string
+		repository string
+		wantErr    bool
+	}{
+		{"identical", "git@github.com:user/repo.git", "git@github.com:user/repo.git", false},
+		{"same repository over https", "git@github.com:user/repo.git", "https://github.com/user/repo", false},
+		{"same repository on another host with ssh port", "ssh://git@gitea.example.com:2222/me/dotfiles.git",
+			"https://gitea.example.com/me/dotfiles.git", false},
+		{"different repository", "git@gitlab.com:user/repo.git", "git@gitlab.com:user/other.git", true},
+		{"no remote was added", "", "git@github.com:user/repo.git", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFake(t)
+			err := reconcileRemote(prompt.NonInteractive{}, "/repo", tt.current, tt.repository, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileRemote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Contains(strings.Join(fake.Methods(), ","), "ChangeRemote") {
+				t.Errorf("calls = %v, want the remote left alone without --force", fake.Calls)
+			}
+		})
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	tests := []struct {
+		name    string
+		refs    git.RemoteRefs
+		want    string
+		wantErr bool
+	}{
+		{"head", git.RemoteRefs{Head: "trunk", Branches: []string{"main", "trunk"}}, "trunk", false},
+		{"only branch", git.RemoteRefs{Branches: []string{"dotfiles"}}, "dotfiles", false},
+		{"head points nowhere", git.RemoteRefs{Head: "master", Branches: []string{"dev", "main"}}, "main", false},
+		{"ambiguous", git.RemoteRefs{Branches: []string{"dev", "work"}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := defaultBranch(tt.refs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("defaultBranch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("defaultBranch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckoutRemoteBootstrapsEmptyRemote(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	testutils.SetupGitRemote(t, testDir)
+
+	// A brand new remote whose default branch is trunk
+	remote := filepath.Join(testDir, "empty.git")
+	testutils.RunGit(t, testDir, "init", "--bare", "-b", "trunk", remote)
+
+	first := filepath.Join(testDir, "first")
+	testutils.CreateTestFile(t, filepath.Join(first, ".zshrc"), "export EDITOR=vim\n")
+	testutils.RunGit(t, first, "init")
+	testutils.RunGit(t, first, "remote", "add", "origin", remote)
+
+	branch, err := checkoutRemote(first, "")
+	if err != nil {
+		t.Fatalf("checkoutRemote() on an empty remote error = %v", err)
+	}
+	if branch != defaultNewBranch {
+		t.Errorf("checkoutRemote() = %v, want %v", branch, defaultNewBranch)
+	}
+	testutils.AssertFileExists(t, filepath.Join(first, "info.json"))
+	upstream := testutils.RunGit(t, first, "rev-parse", "--abbrev-ref", "main@{upstream}")
+	if strings.TrimSpace(upstream) != "origin/main" {
+		t.Errorf("upstream = %v, want origin/main", upstream)
+	}
+
+	// The remote's default branch is taken from its HEAD once it exists
+	testutils.RunGit(t, first, "push", "origin", "main:trunk")
+	second := filepath.Join(testDir, "second")
+	testutils.CreateTestFile(t, filepath.Join(second, ".keep"), "")
+	testutils.RunGit(t, second, "init")
+	testutils.RunGit(t, second, "remote", "add", "origin", remote)
+
+	branch, err = checkoutRemote(second, "")
+	if err != nil {
+		t.Fatalf("checkoutRemote() error = %v", err)
+	}
+	if branch != "trunk" {
+		t.Errorf("checkoutRemote() = %v, want trunk, the remote's HEAD", branch)
+	}
+	testutils.AssertFileContent(t, filepath.Join(second, ".zshrc"), "export EDITOR=vim\n")
+
+	if _, err := checkoutRemote(second, "missing"); err == nil {
+		t.Error("checkoutRemote() of a missing branch succeeded, want an error")
+	}
+}
@@ -0,0 +1,173 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+)
+
+// Layer is a repository in the stack of layers, a layer overrides the targets of every layer before it
+type Layer struct {
+	Name     string
+	InfoPath string
+}
+
+var (
+	// layers is the stack from the base to the top, empty when no layers are configured
+	layers []Layer
+	// currentRepo names the repository commands work on
+	currentRepo string
+)
+
+// SetLayers stacks the layers from the base to the top for commands working on the repository current
+func SetLayers(current string, stack []Layer) {
+	currentRepo = current
+	layers = stack
+}
+
+// LayerEntry is a target tracked by the layers and the layer it is deployed from
+type LayerEntry struct {
+	Target string
+	Layer  string
+	// Overrides lists the lower layers that track the target as well
+	Overrides []string
+	// Linked is set when the target links to the file of Layer
+	Linked bool
+	path   string
+}
+
+// LayerEntries lists every target of the layers with the layer that wins it, sorted by target
+func LayerEntries() ([]LayerEntry, error) {
+	byTarget := map[string]*LayerEntry{}
+	for _, layer := range layers {
+		entries, err := trackedEntries(layer.InfoPath)
+		if err != nil {
+			return nil, fmt.Errorf("layer %v: %w", layer.Name, err)
+		}
+		for target, info := range entries {
+			entry, ok := byTarget[target]
+			if !ok {
+				entry = &LayerEntry{Target: target}
+				byTarget[target] = entry
+			} else {
+				entry.Overrides = append(entry.Overrides, entry.Layer)
+			}
+			entry.Layer, entry.path = layer.Name, info.Path
+		}
+	}
+
+	result := make([]LayerEntry, 0, len(byTarget))
+	for _, entry := range byTarget {
+		entry.Linked, _ = checkSamePath(entry.Target, entry.path)
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Target < result[j].Target })
+	return result, nil
+}
+
+// layerRank returns the position of repo in the stack, -1 when it is not a layer
+func layerRank(repo string) int {
+	return slices.IndexFunc(layers, func(layer Layer) bool { return layer.Name == repo })
+}
+
+// overrides reports whether upper is a layer above the layer lower
+func overrides(upper, lower string) bool {
+	u, l := layerRank(upper), layerRank(lower)
+	return u >= 0 && l >= 0 && u > l
+}
+
+// overriddenTargets maps the targets a layer above the repository in use tracks to the topmost such layer
+func overriddenTargets() (map[string]string, error) {
+	overridden := map[string]string{}
+	for _, layer := range layers {
+		if !overrides(layer.Name, currentRepo) {
+			continue
+		}
+		entries, err := trackedEntries(layer.InfoPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not check layer %v: %w", layer.Name, err)
+		}
+		for target := range entries {
+			overridden[target] = layer.Name
+		}
+	}
+	return overridden, nil
+}
+
+// lowerEntry returns the entry of the topmost layer below the repository in use that tracks target, an empty
+// layer when there is none
+func lowerEntry(target string) (string, files.FileInfo, error) {
+	for i := len(layers) - 1; i >= 0; i-- {
+		layer := layers[i]
+		if !overrides(currentRepo, layer.Name) {
+			continue
+		}
+		entries, err := trackedEntries(layer.InfoPath)
+		if err != nil {
+			return "", files.FileInfo{}, fmt.Errorf("could not check layer %v: %w", layer.Name, err)
+		}
+		if info, ok := entries[target]; ok {
+			return layer.Name, info, nil
+		}
+	}
+	return "", files.FileInfo{}, nil
+}
+
+// linksLowerLayer reports the lower layer target links to, empty when it is not a link into one
+func linksLowerLayer(target string) (string, error) {
+	layer, info, err := lowerEntry(target)
+	if err != nil || layer == "" {
+		return "", err
+	}
+	if ok, _ := checkSamePath(target, info.Path); !ok {
+		return "", nil
+	}
+	return layer, nil
+}
+
+// linkLowerLayer gives a target the repository in use no longer tracks back to the topmost layer below that
+// tracks it. It reports whether it linked one
+func linkLowerLayer(target string) (bool, error) {
+	layer, info, err := lowerEntry(target)
+	if err != nil || layer == "" {
+		return false, err
+	}
+	if _, err := os.Lstat(target); err == nil {
+		internal.LogVerbose("%v exists, not linking it to layer %v", target, layer)
+		return false, nil
+	}
+	if !internal.FileExist(info.Path) {
+		return false, fmt.Errorf("file %v of layer %v does not exist", info.Path, layer)
+	}
+	if err := ensureParent(target); err != nil {
+		return false, err
+	}
+	internal.LogVerbose("Creating symlink %v -> %v", target, info.Path)
+	if err := internal.CreateSymlink(target, info.Path); err != nil {
+		return false, err
+	}
+	fmt.Printf("Linked %v from layer %v\n", internal.ShrinkPath(target), layer)
+	return true, nil
+}
+
+// copyLowerLayer replaces a link into a lower layer with a copy of its file, so adding the target starts an
+// override from the version of that layer
+func copyLowerLayer(target string) error {
+	layer, err := linksLowerLayer(target)
+	if err != nil || layer == "" {
+		return err
+	}
+	source, err := internal.FollowSymlink(target)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	internal.LogVerbose("Replacing the link to layer %v at %v with a copy of %v", layer, target, source)
+	if err := os.Remove(target); err != nil {
+		return fmt.Errorf("could not remove the link to layer %v: %w", layer, err)
+	}
+	return copyFile(source, target)
+}
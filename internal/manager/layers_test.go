@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestLayerOverrides(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	home := filepath.Join(testDir, "home")
+	team := filepath.Join(testDir, "team")
+	personal := filepath.Join(testDir, "personal")
+	for _, dir := range []string{home, team, personal} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitconfig := filepath.Join(home, ".gitconfig")
+	teamFile := filepath.Join(team, ".gitconfig")
+	testutils.CreateTestFile(t, teamFile, "[user]\n")
+	testutils.CreateTestSymlink(t, gitconfig, teamFile)
+	if err := files.SaveStatus(filepath.Join(team, "info.json"), map[string]files.FileInfo{
+		".gitconfig": {Symlink: gitconfig, Path: teamFile, Status: "ok"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.SaveStatus(filepath.Join(personal, "info.json"), map[string]files.FileInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	stack := []Layer{{Name: "team", InfoPath: filepath.Join(team, "info.json")},
+		{Name: "personal", InfoPath: filepath.Join(personal, "info.json")}}
+
+	previous, previousLayers, previousRepo := otherRepos, layers, currentRepo
+	t.Cleanup(func() {
+		SetOtherRepos(previous)
+		SetLayers(previousRepo, previousLayers)
+	})
+
+	// Working on personal, adding the target starts an override from the team version
+	SetOtherRepos(map[string]string{"team": stack[0].InfoPath})
+	SetLayers("personal", stack)
+	if err := AddFile(prompt.NonInteractive{}, gitconfig, personal, false); err != nil {
+		t.Fatalf("AddFile() error = %v", err)
+	}
+	testutils.AssertSymlink(t, gitconfig, filepath.Join(personal, ".gitconfig"))
+	testutils.AssertFileContent(t, filepath.Join(personal, ".gitconfig"), "[user]\n")
+	testutils.AssertFileContent(t, teamFile, "[user]\n")
+
+	entries, err := LayerEntries()
+	if err != nil {
+		t.Fatalf("LayerEntries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Layer != "personal" || !entries[0].Linked ||
+		strings.Join(entries[0].Overrides, ",") != "team" {
+		t.Errorf("LayerEntries() = %+v, want .gitconfig linked from personal over team", entries)
+	}
+	infoPaths := map[string]string{"team": stack[0].InfoPath, "personal": stack[1].InfoPath}
+	if conflicts, err := TargetConflicts(infoPaths); err != nil || len(conflicts) != 0 {
+		t.Errorf("TargetConflicts() = %+v, %v, want none for layers", conflicts, err)
+	}
+
+	// Working on team, the override is left alone
+	SetOtherRepos(map[string]string{"personal": stack[1].InfoPath})
+	SetLayers("team", stack)
+	linked, err := newDeployer(prompt.NonInteractive{}).link(".gitconfig",
+		files.FileInfo{Symlink: gitconfig, Path: teamFile})
+	if err != nil || linked {
+		t.Errorf("link() = %v, %v, want the target skipped", linked, err)
+	}
+	testutils.AssertSymlink(t, gitconfig, filepath.Join(personal, ".gitconfig"))
+	if err := CheckStatus(stack[0].InfoPath); err != nil {
+		t.Fatalf("CheckStatus() error = %v", err)
+	}
+	fileInfo, err := files.ReadFile(stack[0].InfoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := fileInfo[".gitconfig"]; len(info.Errors) > 0 {
+		t.Errorf("CheckStatus() reported %v for an overridden entry", info.Errors)
+	}
+
+	// Removing the override hands the target back to team
+	SetOtherRepos(map[string]string{"team": stack[0].InfoPath})
+	SetLayers("personal", stack)
+	if err := RemoveFile(".gitconfig", stack[1].InfoPath, files.RetireRestore, false); err != nil {
+		t.Fatalf("RemoveFile() error = %v", err)
+	}
+	testutils.AssertSymlink(t, gitconfig, teamFile)
+}
@@ -21,7 +21,11 @@ func ListFiles(folderpath string) {
 		return
 	}
 	internal.LogVerbose("Presenting files in %v", folderpath)
-	for filename := range entries {
+	for filename, info := range entries {
+		if info.Tombstone != nil {
+			internal.LogVerbose("Skipping %v, it has been removed", filename)
+			continue
+		}
 		fmt.Println(filename)
 	}
 }
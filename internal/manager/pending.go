@@ -0,0 +1,177 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// ErrPending is returned by a sync that committed locally but could not reach the remote,
+// the rest is retried by the next sync
+var ErrPending = errors.New("the remote could not be reached, changes are committed locally and " +
+	"will be synced by the next 'dotman sync'")
+
+// PendingSync records the parts of a sync that could not reach the remote
+type PendingSync struct {
+	Branch string `json:"branch"`
+	// Push lists the remotes that still have to receive the local commits
+	Push   []string  `json:"push,omitempty"`
+	Pull   bool      `json:"pull,omitempty"`
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func pendingPath() string {
+	return filepath.Join(internal.StateDir(), "pending.json")
+}
+
+// readAllPending returns the pending syncs of every repository on this machine, keyed by repository path
+func readAllPending() (map[string]PendingSync, error) {
+	pending := map[string]PendingSync{}
+	data, err := os.ReadFile(pendingPath())
+	if os.IsNotExist(err) {
+		return pending, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read pending syncs: %w", err)
+	}
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("could not parse %v: %w", pendingPath(), err)
+	}
+	return pending, nil
+}
+
+func saveAllPending(pending map[string]PendingSync) error {
+	if len(pending) == 0 {
+		if err := os.Remove(pendingPath()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not clear pending syncs: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending syncs: %w", err)
+	}
+	if err := internal.CreateFolder(filepath.Dir(pendingPath())); err != nil {
+		return err
+	}
+	if err := internal.WriteFileAtomic(pendingPath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to save pending syncs: %w", err)
+	}
+	return nil
+}
+
+// ReadPending returns what is left of the last sync of the repository, nil when nothing is pending
+func ReadPending(folderPath string) (*PendingSync, error) {
+	pending, err := readAllPending()
+	if err != nil {
+		return nil, err
+	}
+	state, ok := pending[pendingKey(folderPath)]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// markPending records that the pull and/or the push to some remotes did not reach the remote
+func markPending(folderPath, branch string, pull bool, push []string, reason error) error {
+	pending, err := readAllPending()
+	if err != nil {
+		return err
+	}
+	key := pendingKey(folderPath)
+	state, ok := pending[key]
+	if !ok || state.Branch != branch {
+		state = PendingSync{Branch: branch, Since: time.Now().UTC().Truncate(time.Second)}
+	}
+	state.Pull = state.Pull || pull
+	for _, remote := range push {
+		if !slices.Contains(state.Push, remote) {
+			state.Push = append(state.Push, remote)
+		}
+	}
+	state.Reason = firstLine(reason.Error())
+	pending[key] = state
+	return saveAllPending(pending)
+}
+
+// settlePending forgets the parts of a pending sync that a sync just completed and reports what was flushed
+func settlePending(folderPath string, pulled bool, pushed []string) error {
+	pending, err := readAllPending()
+	if err != nil {
+		return err
+	}
+	key := pendingKey(folderPath)
+	state, ok := pending[key]
+	if !ok {
+		return nil
+	}
+
+	if pulled && state.Pull {
+		state.Pull = false
+		fmt.Println("Pulled the remote changes that were pending")
+	}
+	remaining := []string{}
+	for _, remote := range state.Push {
+		if slices.Contains(pushed, remote) {
+			fmt.Printf("Pushed the local commits that were pending for %v\n", remote)
+		} else {
+			remaining = append(remaining, remote)
+		}
+	}
+	state.Push = remaining
+	if state.Pull || len(state.Push) > 0 {
+		pending[key] = state
+	} else {
+		delete(pending, key)
+	}
+	return saveAllPending(pending)
+}
+
+// PrintPending reports a sync that is still waiting for the remote, if any
+func PrintPending(folderPath string) error {
+	state, err := ReadPending(folderPath)
+	if err != nil || state == nil {
+		return err
+	}
+
+	parts := []string{}
+	for _, remote := range state.Push {
+		if commits, err := gitBackend.Log(folderPath, remote+"/"+state.Branch, "HEAD"); err == nil {
+			parts = append(parts, fmt.Sprintf("%d local commit(s) to push to %v", len(commits), remote))
+		} else {
+			parts = append(parts, "local commits to push to "+remote)
+		}
+	}
+	if state.Pull {
+		parts = append(parts, "remote changes to pull")
+	}
+
+	fmt.Printf("Sync of %v pending since %v: %v\n", state.Branch, state.Since.Local().Format(time.DateTime),
+		strings.Join(parts, " and "))
+	if state.Reason != "" {
+		fmt.Printf("  Last error: %v\n", state.Reason)
+	}
+	fmt.Println("  Run 'dotman sync' once the remote is reachable")
+	return nil
+}
+
+func pendingKey(folderPath string) string {
+	if abs, err := filepath.Abs(folderPath); err == nil {
+		return abs
+	}
+	return filepath.Clean(folderPath)
+}
+
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return line
+}
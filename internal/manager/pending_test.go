@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func offlineError(args ...string) error {
+	return &internal.CommandError{Command: "git", Args: args, ExitCode: 128,
+		Stderr: "ssh: Could not resolve hostname github.com: Name or service not known\n" +
+			"fatal: Could not read from remote repository."}
+}
+
+func TestSyncRepoOffline(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	testutils.CreateTestFile(t, filepath.Join(testDir, "info.json"), "{}")
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	tests := []struct {
+		name     string
+		errors   map[string]error
+		wantPull bool
+		noCall   string
+	}{
+		{
+			name:   "push fails offline after pulling",
+			errors: map[string]error{"Push": offlineError("push")},
+		},
+		{
+			name:     "remote unreachable before pulling",
+			errors:   map[string]error{"RemoteBranchExists": offlineError("ls-remote")},
+			wantPull: true,
+			noCall:   "Push",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFake(t)
+			fake.Errors = tt.errors
+
+			err := SyncRepo(testDir, SyncOptions{Download: true, Upload: true})
+			if !errors.Is(err, ErrPending) || !errors.Is(err, ErrGitNetwork) {
+				t.Fatalf("SyncRepo() error = %v, want ErrPending caused by ErrGitNetwork", err)
+			}
+			methods := strings.Join(fake.Methods(), ",")
+			if !strings.Contains(methods, "Commit") {
+				t.Errorf("calls = %v, want changes committed locally", methods)
+			}
+			if tt.noCall != "" && strings.Contains(methods, tt.noCall) {
+				t.Errorf("calls = %v, want no %v once the remote is unreachable", methods, tt.noCall)
+			}
+
+			state, err := ReadPending(testDir)
+			if err != nil || state == nil {
+				t.Fatalf("ReadPending() = %v, %v, want pending state", state, err)
+			}
+			if len(state.Push) != 1 || state.Pull != tt.wantPull || state.Branch != "main" || state.Reason == "" {
+				t.Errorf("ReadPending() = %+v, want push pending and pull pending %v", state, tt.wantPull)
+			}
+
+			// The next sync that reaches the remote flushes it
+			fake = useFake(t)
+			if err := SyncRepo(testDir, SyncOptions{Download: true, Upload: true}); err != nil {
+				t.Fatalf("SyncRepo() error = %v", err)
+			}
+			if state, err := ReadPending(testDir); err != nil || state != nil {
+				t.Errorf("ReadPending() = %+v, %v, want nothing pending after a successful sync", state, err)
+			}
+		})
+	}
+}
+
+func TestSettlePendingKeepsUnfinishedParts(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	if err := markPending(testDir, "main", true, []string{"origin", "backup"}, offlineError("fetch")); err != nil {
+		t.Fatalf("markPending() error = %v", err)
+	}
+	// An upload only sync that reached origin leaves the pull and the backup pending
+	if err := settlePending(testDir, false, []string{"origin"}); err != nil {
+		t.Fatalf("settlePending() error = %v", err)
+	}
+	state, err := ReadPending(testDir)
+	if err != nil || state == nil {
+		t.Fatalf("ReadPending() = %v, %v, want the rest still pending", state, err)
+	}
+	if strings.Join(state.Push, ",") != "backup" || !state.Pull {
+		t.Errorf("ReadPending() = %+v, want the pull and the push to backup pending", state)
+	}
+}
+
+func TestSyncRepoRejectedPushIsNotPending(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	testutils.CreateTestFile(t, filepath.Join(testDir, "info.json"), "{}")
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	fake := useFake(t)
+	fake.Errors = map[string]error{"Push": &internal.CommandError{Command: "git", Args: []string{"push"},
+		ExitCode: 1, Stderr: " ! [rejected]        main -> main (fetch first)"}}
+
+	err := SyncRepo(testDir, SyncOptions{Download: true, Upload: true})
+	if !errors.Is(err, ErrGitRejected) || errors.Is(err, ErrPending) {
+		t.Fatalf("SyncRepo() error = %v, want ErrGitRejected and nothing pending", err)
+	}
+	if state, _ := ReadPending(testDir); state != nil {
+		t.Errorf("ReadPending() = %+v, want nothing pending", state)
+	}
+}
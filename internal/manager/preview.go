@@ -0,0 +1,186 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/git"
+)
+
+// SyncPreview is what a sync would do, collected after fetching without touching the working tree
+type SyncPreview struct {
+	Branch string
+	// Local holds uncommitted changes as git status --porcelain lines
+	Local    []string
+	Outgoing []git.Commit
+	Incoming []git.Commit
+	// OutgoingEntries and IncomingEntries name the entries whose files change, paths outside any entry
+	// are listed relative to the repository
+	OutgoingEntries []string
+	IncomingEntries []string
+	// Deploy holds the manifest changes that would be linked, unlinked and re-linked after pulling
+	Deploy ManifestChanges
+	// Retire holds entries removed on another machine that this machine would retire
+	Retire []string
+}
+
+// PreviewSync fetches the primary remote and compares it with the local branch, including uncommitted changes
+func PreviewSync(folderPath, branch string) (*SyncPreview, error) {
+	preview := &SyncPreview{Branch: branch}
+
+	output, err := gitBackend.Status(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect status: %w", err)
+	}
+	preview.Local = git.ListChanges(output)
+
+	internal.LogVerbose("Fetching %v to compare with %v", primaryRemote, branch)
+	if err := gitBackend.Fetch(folderPath, primaryRemote); err != nil {
+		return nil, fmt.Errorf("could not fetch %v: %w", primaryRemote, explainGitError(err))
+	}
+
+	exists, err := gitBackend.RemoteBranchExists(folderPath, primaryRemote, branch)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if !exists {
+		internal.LogVerbose("%v does not exist on %v yet, everything is outgoing", branch, primaryRemote)
+		preview.Outgoing, err = gitBackend.Log(folderPath, "", "HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		return preview, nil
+	}
+
+	remote := primaryRemote + "/" + branch
+	base, err := gitBackend.MergeBase(folderPath, "HEAD", remote)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if preview.Outgoing, err = gitBackend.Log(folderPath, remote, "HEAD"); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if preview.Incoming, err = gitBackend.Log(folderPath, "HEAD", remote); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	local, err := files.ReadFile(filepath.Join(folderPath, "info.json"))
+	if err != nil {
+		local = map[string]files.FileInfo{}
+	}
+	incoming := manifestAt(folderPath, remote)
+	names := pathEntries(folderPath, local, incoming)
+
+	outgoingPaths, err := gitBackend.ChangedFiles(folderPath, base, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	for _, line := range preview.Local {
+		if len(line) > 3 {
+			outgoingPaths = append(outgoingPaths, strings.Trim(line[3:], `"`))
+		}
+	}
+	preview.OutgoingEntries = entriesFor(names, outgoingPaths)
+
+	incomingPaths, err := gitBackend.ChangedFiles(folderPath, base, remote)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	preview.IncomingEntries = entriesFor(names, incomingPaths)
+
+	if len(preview.Incoming) > 0 {
+		preview.Deploy = diffManifest(manifestAt(folderPath, base), incoming)
+	}
+	machine := internal.MachineName()
+	for name, info := range incoming {
+		if info.Tombstone != nil && !info.Tombstone.AppliedOn(machine) {
+			preview.Retire = append(preview.Retire, name)
+		}
+	}
+	sort.Strings(preview.Retire)
+
+	return preview, nil
+}
+
+// pathEntries maps repository relative paths to entry names from every given manifest
+func pathEntries(folderPath string, manifests ...map[string]files.FileInfo) map[string]string {
+	names := map[string]string{}
+	for _, manifest := range manifests {
+		for name, info := range manifest {
+			rel, err := filepath.Rel(folderPath, info.Path)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			names[filepath.ToSlash(rel)] = name
+		}
+	}
+	return names
+}
+
+// bookkeepingFiles are dotman's own files, their changes are shown as manifest changes instead
+var bookkeepingFiles = map[string]bool{"info.json": true, "machines.json": true}
+
+// entriesFor returns the entries the paths belong to, a file inside a tracked directory counts for that directory
+func entriesFor(names map[string]string, paths []string) []string {
+	seen := map[string]bool{}
+	entries := []string{}
+	for _, path := range paths {
+		if bookkeepingFiles[path] {
+			continue
+		}
+		entry := path
+		for candidate := path; candidate != "." && candidate != "/"; candidate = filepath.ToSlash(filepath.Dir(candidate)) {
+			if name, ok := names[candidate]; ok {
+				entry = name
+				break
+			}
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+// printPreview shows a preview the way sync reports its work
+func printPreview(preview *SyncPreview) {
+	fmt.Printf("[dry-run] Syncing %v, nothing will be changed\n", preview.Branch)
+
+	printList("Uncommitted changes that would be committed", preview.Local)
+	printCommits("Outgoing commits that would be pushed", preview.Outgoing)
+	printList("Entries changed locally", preview.OutgoingEntries)
+	printCommits("Incoming commits that would be pulled", preview.Incoming)
+	printList("Entries changed remotely", preview.IncomingEntries)
+	printList("Entries that would be linked", preview.Deploy.Added)
+	printList("Entries that would be unlinked", preview.Deploy.Removed)
+	printList("Entries that would be re-linked", preview.Deploy.Moved)
+	printList("Entries removed on another machine that would be retired", preview.Retire)
+
+	if len(preview.Local) == 0 && len(preview.Outgoing) == 0 && len(preview.Incoming) == 0 && len(preview.Retire) == 0 {
+		fmt.Println("Already up to date")
+	}
+}
+
+func printList(title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Printf("%v:\n", title)
+	for _, item := range items {
+		fmt.Printf("  %v\n", item)
+	}
+}
+
+func printCommits(title string, commits []git.Commit) {
+	lines := make([]string, len(commits))
+	for i, commit := range commits {
+		lines[i] = fmt.Sprintf("%v %v (%v)", commit.Short(), commit.Subject, commit.Author)
+	}
+	printList(title, lines)
+}
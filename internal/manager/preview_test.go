@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestPreviewSync(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	machineA := filepath.Join(testDir, "machineA")
+	machineB := filepath.Join(testDir, "machineB")
+	testutils.CloneGitRemote(t, remote, machineA)
+	testutils.CloneGitRemote(t, remote, machineB)
+
+	homeDir := filepath.Join(testDir, "home")
+	testutils.CreateTestFile(t, filepath.Join(homeDir, ".keep"), "")
+	testutils.CreateTestFile(t, filepath.Join(machineA, ".vimrc"), "set number\n")
+	testutils.CreateTestFile(t, filepath.Join(machineA, "info.json"), `{
+  ".vimrc": {"symlink": "`+filepath.Join(homeDir, ".vimrc")+`", "path": "`+filepath.Join(machineB, ".vimrc")+`"}
+}`)
+	if err := SyncRepo(machineA, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() on machineA error = %v", err)
+	}
+
+	testutils.CreateTestFile(t, filepath.Join(machineB, ".zshrc"), "export EDITOR=nano\n")
+	testutils.RunGit(t, machineB, "add", "-A")
+	testutils.RunGit(t, machineB, "commit", "-m", "local change")
+	testutils.CreateTestFile(t, filepath.Join(machineB, ".bashrc"), "set -o vi\n")
+	head := strings.TrimSpace(testutils.RunGit(t, machineB, "rev-parse", "HEAD"))
+
+	preview, err := PreviewSync(machineB, "main")
+	if err != nil {
+		t.Fatalf("PreviewSync() error = %v", err)
+	}
+
+	if len(preview.Local) != 1 || !strings.HasSuffix(preview.Local[0], ".bashrc") {
+		t.Errorf("Local = %v, want the uncommitted .bashrc", preview.Local)
+	}
+	if len(preview.Outgoing) != 1 || preview.Outgoing[0].Subject != "local change" {
+		t.Errorf("Outgoing = %+v, want the local commit", preview.Outgoing)
+	}
+	if len(preview.Incoming) == 0 || preview.Incoming[0].Subject != "dotman sync" {
+		t.Errorf("Incoming = %+v, want machineA's sync", preview.Incoming)
+	}
+	if strings.Join(preview.OutgoingEntries, ",") != ".bashrc,.zshrc" {
+		t.Errorf("OutgoingEntries = %v, want [.bashrc .zshrc]", preview.OutgoingEntries)
+	}
+	// machineA's first sync also committed the ignore patterns
+	if strings.Join(preview.IncomingEntries, ",") != ".gitignore,.vimrc" {
+		t.Errorf("IncomingEntries = %v, want [.gitignore .vimrc]", preview.IncomingEntries)
+	}
+	if strings.Join(preview.Deploy.Added, ",") != ".vimrc" || len(preview.Deploy.Removed) != 0 {
+		t.Errorf("Deploy = %+v, want .vimrc added", preview.Deploy)
+	}
+
+	// Nothing was pulled, committed or linked
+	if now := strings.TrimSpace(testutils.RunGit(t, machineB, "rev-parse", "HEAD")); now != head {
+		t.Errorf("HEAD moved from %v to %v", head, now)
+	}
+	testutils.AssertFileNotExists(t, filepath.Join(machineB, ".vimrc"))
+	testutils.AssertFileNotExists(t, filepath.Join(homeDir, ".vimrc"))
+}
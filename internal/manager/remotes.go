@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/git"
+)
+
+// pushResult is the outcome of pushing a branch to one remote
+type pushResult struct {
+	remote string
+	err    error
+}
+
+type pushResults []pushResult
+
+// pushAll pushes branch to every push remote, the primary first and as the upstream.
+// A remote that fails does not stop the others
+func pushAll(folderPath, branch string) pushResults {
+	results := pushResults{}
+	for _, remote := range pushRemotes {
+		if err := checkInterrupted(); err != nil {
+			results = append(results, pushResult{remote, err})
+			continue
+		}
+		internal.LogVerbose("Pushing %v to %v", branch, remote)
+		err := gitBackend.Push(folderPath, remote, branch, remote == primaryRemote)
+		if err != nil {
+			err = explainGitError(err)
+		}
+		results = append(results, pushResult{remote, err})
+	}
+	return results
+}
+
+// err returns the first failure, nil when every remote was pushed
+func (r pushResults) err() error {
+	for _, result := range r {
+		if result.err != nil {
+			return fmt.Errorf("%v: %w", result.remote, result.err)
+		}
+	}
+	return nil
+}
+
+// split returns the remotes that were pushed, the remotes that could not be reached with the first such error,
+// and the first failure that was not about connectivity
+func (r pushResults) split() (pushed, offline []string, offlineErr, failed error) {
+	for _, result := range r {
+		switch {
+		case result.err == nil:
+			pushed = append(pushed, result.remote)
+		case isOffline(result.err):
+			offline = append(offline, result.remote)
+			if offlineErr == nil {
+				offlineErr = fmt.Errorf("%v: %w", result.remote, result.err)
+			}
+		case failed == nil:
+			failed = fmt.Errorf("%v: %w", result.remote, result.err)
+		}
+	}
+	return pushed, offline, offlineErr, failed
+}
+
+// print reports the outcome per remote when more than one remote is pushed to
+func (r pushResults) print() {
+	if len(r) < 2 {
+		return
+	}
+	for _, result := range r {
+		switch {
+		case result.err == nil:
+			fmt.Printf("  %v: pushed\n", result.remote)
+		case isOffline(result.err):
+			fmt.Printf("  %v: unreachable, push pending\n", result.remote)
+		default:
+			fmt.Printf("  %v: failed: %v\n", result.remote, firstLine(result.err.Error()))
+		}
+	}
+}
+
+// EnsureRemote adds the named remote to the repository when it is missing. An existing remote with another URL
+// is changed when update is set and reported as an error otherwise
+func EnsureRemote(folderPath, name, url string, update bool) error {
+	current, err := gitBackend.GetRemoteURL(folderPath, name)
+	if err != nil {
+		internal.LogVerbose("Adding remote %v %v", name, url)
+		if err := gitBackend.AddRemote(folderPath, name, url); err != nil {
+			return fmt.Errorf("could not add remote %v: %w", name, err)
+		}
+		return nil
+	}
+	if current == url {
+		return nil
+	}
+	if git.SameRepository(current, url) && !update {
+		internal.LogVerbose("Keeping %v for remote %v, it is the same repository as %v", current, name, url)
+		return nil
+	}
+	if !update {
+		return fmt.Errorf("remote %v points to %v instead of %v, run 'dotman init --force' to change it",
+			name, current, url)
+	}
+	internal.LogVerbose("Changing remote %v from %v to %v", name, current, url)
+	if err := gitBackend.ChangeRemote(folderPath, name, url); err != nil {
+		return fmt.Errorf("could not change remote %v: %w", name, err)
+	}
+	return nil
+}
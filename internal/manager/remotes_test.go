@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func useRemotes(t *testing.T, primary string, mirrors ...string) {
+	previousPrimary, previousPush := primaryRemote, pushRemotes
+	SetRemotes(primary, mirrors)
+	t.Cleanup(func() { primaryRemote, pushRemotes = previousPrimary, previousPush })
+}
+
+func TestSyncRepoPushesToEveryRemote(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	remote := testutils.SetupGitRemote(t, testDir)
+	backup := filepath.Join(testDir, "backup.git")
+	testutils.RunGit(t, testDir, "init", "--bare", "-b", "main", backup)
+
+	machine := filepath.Join(testDir, "machine")
+	testutils.CloneGitRemote(t, remote, machine)
+	useRemotes(t, "origin", "backup")
+	if err := EnsureRemote(machine, "backup", backup, false); err != nil {
+		t.Fatalf("EnsureRemote() error = %v", err)
+	}
+	if err := EnsureRemote(machine, "backup", "/elsewhere.git", false); err == nil {
+		t.Error("EnsureRemote() with another URL succeeded, want an error without update")
+	}
+
+	testutils.CreateTestFile(t, filepath.Join(machine, ".zshrc"), "export EDITOR=vim\n")
+	if err := SyncRepo(machine, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() error = %v", err)
+	}
+
+	head := testutils.RunGit(t, machine, "rev-parse", "HEAD")
+	for _, bare := range []string{remote, backup} {
+		if got := testutils.RunGit(t, bare, "rev-parse", "main"); got != head {
+			t.Errorf("main in %v = %v, want %v", filepath.Base(bare), got, head)
+		}
+	}
+	upstream := testutils.RunGit(t, machine, "rev-parse", "--abbrev-ref", "main@{upstream}")
+	if strings.TrimSpace(upstream) != "origin/main" {
+		t.Errorf("upstream = %v, want origin/main", upstream)
+	}
+}
+
+func TestSyncRepoMirrorUnreachable(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	testutils.CreateTestFile(t, filepath.Join(testDir, "info.json"), "{}")
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+	useRemotes(t, "origin", "backup")
+
+	fake := useFake(t)
+	fake.Errors = map[string]error{"Push backup": offlineError("push", "backup")}
+
+	err := SyncRepo(testDir, SyncOptions{Download: true, Upload: true})
+	if !errors.Is(err, ErrPending) {
+		t.Fatalf("SyncRepo() error = %v, want ErrPending", err)
+	}
+	calls := strings.Join(fake.Calls, "\n")
+	if !strings.Contains(calls, "Pull "+testDir+" origin main") {
+		t.Errorf("calls = %v, want a pull from origin", calls)
+	}
+	if !strings.Contains(calls, "Push "+testDir+" origin main true") ||
+		!strings.Contains(calls, "Push "+testDir+" backup main false") {
+		t.Errorf("calls = %v, want origin pushed as upstream and backup mirrored", calls)
+	}
+
+	state, err := ReadPending(testDir)
+	if err != nil || state == nil || strings.Join(state.Push, ",") != "backup" || state.Pull {
+		t.Errorf("ReadPending() = %+v, %v, want only the push to backup pending", state, err)
+	}
+
+	// A mirror that rejects the push fails the sync without blocking the primary
+	fake = useFake(t)
+	fake.Errors = map[string]error{"Push backup": errors.New("remote rejected")}
+	err = SyncRepo(testDir, SyncOptions{Download: true, Upload: true})
+	if err == nil || errors.Is(err, ErrPending) || !strings.Contains(err.Error(), "backup") {
+		t.Errorf("SyncRepo() error = %v, want the backup failure", err)
+	}
+	if !strings.Contains(strings.Join(fake.Calls, "\n"), "Push "+testDir+" origin main true") {
+		t.Errorf("calls = %v, want origin still pushed", fake.Calls)
+	}
+}
@@ -3,24 +3,42 @@ package manager
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/ZonCen/dotman/internal"
 	"github.com/ZonCen/dotman/internal/files"
 )
 
-func RemoveFile(fileName, infoPath string, force bool) error {
+// RemoveFile unlinks an entry on this machine and leaves a tombstone in info.json so every other machine
+// retires it the same way on its next sync
+func RemoveFile(fileName, infoPath, action string, force bool) error {
 	var (
 		symPath  string
 		filePath string
 	)
 
+	if action == "" {
+		action = files.RetireRestore
+	}
+	if action != files.RetireRestore && action != files.RetireDelete {
+		return fmt.Errorf("unknown action %q, use %v or %v", action, files.RetireRestore, files.RetireDelete)
+	}
+
 	fileInfo, err := files.ReadFile(infoPath)
 	if err != nil {
 		return fmt.Errorf("could not read file: %w", err)
 	}
 
-	symPath = fileInfo[fileName].Symlink
-	filePath = fileInfo[fileName].Path
+	info, ok := fileInfo[fileName]
+	if !ok {
+		return fmt.Errorf("%v is not tracked", fileName)
+	}
+	if info.Tombstone != nil {
+		return fmt.Errorf("%v has already been removed by %v", fileName, info.Tombstone.RemovedBy)
+	}
+
+	symPath = info.Symlink
+	filePath = info.Path
 
 	_, err = checkSymlink(symPath)
 	if err != nil && !force {
@@ -43,24 +61,40 @@ func RemoveFile(fileName, infoPath string, force bool) error {
 		return fmt.Errorf("could not remove the file: %w", err)
 	}
 
-	internal.LogVerbose("Moving back %v to original path %v", filePath, symPath)
-	err = os.Rename(filePath, symPath)
+	// A layer below that tracks the target takes it back instead of a copy
+	linked, err := linkLowerLayer(symPath)
 	if err != nil && !force {
-		return fmt.Errorf("could not move the file: %w", err)
+		return fmt.Errorf("could not link the layer below: %w", err)
 	}
 
-	err = removeFromFile(infoPath, fileName)
-	if err != nil {
-		return fmt.Errorf("could not remove from file: %w", err)
+	// The repository keeps its copy until every machine has retired the entry
+	if action == files.RetireRestore && !linked {
+		internal.LogVerbose("Copying back %v to original path %v", filePath, symPath)
+		err = copyFile(filePath, symPath)
+		if err != nil && !force {
+			return fmt.Errorf("could not move the file: %w", err)
+		}
 	}
 
-	return nil
-}
+	if !linked {
+		if err := removeCreatedDirs(symPath); err != nil && !force {
+			return fmt.Errorf("could not clean up directories: %w", err)
+		}
+	}
+
+	internal.LogVerbose("Leaving a %v tombstone for %v", action, fileName)
+	info.Tombstone = newTombstone(action)
+	fileInfo[fileName] = info
+
+	machines, err := files.RegisterMachine(machinesPath(filepath.Dir(infoPath)), internal.MachineName())
+	if err != nil {
+		return fmt.Errorf("could not register machine: %w", err)
+	}
+	collectTombstones(fileInfo, machines)
 
-func removeFromFile(infoPath, filename string) error {
-	err := files.RemoveFiles(infoPath, filename)
+	err = files.SaveStatus(infoPath, fileInfo)
 	if err != nil {
-		return fmt.Errorf("failed to remove from file: %w", err)
+		return fmt.Errorf("could not save file: %w", err)
 	}
 
 	return nil
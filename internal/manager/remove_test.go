@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
 	"github.com/ZonCen/dotman/internal/testutils"
 )
 
@@ -36,7 +37,7 @@ func TestRemoveFile(t *testing.T) {
 }`)
 
 	// Test removing file
-	err := RemoveFile(".zshrc", infoPath, false)
+	err := RemoveFile(".zshrc", infoPath, "", false)
 	if err != nil {
 		t.Errorf("RemoveFile() error = %v", err)
 	}
@@ -74,7 +75,7 @@ func TestRemoveFileForce(t *testing.T) {
 }`)
 
 	// Test removing file with force (should work even with broken symlink)
-	err := RemoveFile(".zshrc", infoPath, true)
+	err := RemoveFile(".zshrc", infoPath, "", true)
 	if err != nil {
 		t.Errorf("RemoveFile() with force error = %v", err)
 	}
@@ -99,55 +100,96 @@ func TestRemoveFileNonExistent(t *testing.T) {
 }`)
 
 	// Test removing non-existent file without force - should fail
-	err := RemoveFile(".nonexistent", infoPath, false)
+	err := RemoveFile(".nonexistent", infoPath, "", false)
 	if err == nil {
 		t.Error("Expected error when removing non-existent file without force")
 	}
 
 	// Test removing non-existent file with force - should work
-	err = RemoveFile(".nonexistent", infoPath, true)
+	err = RemoveFile(".nonexistent", infoPath, "", true)
 	if err != nil {
 		t.Errorf("RemoveFile() with force error = %v", err)
 	}
 }
 
-func TestRemoveFromFile(t *testing.T) {
-	testDir, repoDir, _ := testutils.SetupTestEnvironment(t)
-	defer testutils.CleanupTestEnvironment(t, testDir)
-
-	// Create info.json with multiple entries
-	infoPath := filepath.Join(repoDir, "info.json")
-	testutils.CreateTestFile(t, infoPath, `{
-  ".zshrc": {
-    "symlink": "~/.zshrc",
-    "path": "~/dotfiles/.zshrc",
-    "status": "ok",
-    "errors": null
-  },
-  ".vimrc": {
-    "symlink": "~/.vimrc",
-    "path": "~/dotfiles/.vimrc",
-    "status": "ok",
-    "errors": null
-  }
-}`)
+func TestRemoveFilePropagatesTombstone(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	homeA := filepath.Join(testDir, "a")
+	homeB := filepath.Join(testDir, "b")
+	homeC := filepath.Join(testDir, "c")
+	repoA := filepath.Join(homeA, "dotfiles")
+	repoB := filepath.Join(homeB, "dotfiles")
+	repoC := filepath.Join(homeC, "dotfiles")
+	testutils.CreateTestFile(t, filepath.Join(homeA, ".zshrc"), "zsh configuration")
+	testutils.CreateTestFile(t, filepath.Join(homeB, ".keep"), "")
+	testutils.CreateTestFile(t, filepath.Join(homeC, ".keep"), "")
+	testutils.CloneGitRemote(t, remote, repoA)
+	testutils.CloneGitRemote(t, remote, repoB)
+	testutils.CloneGitRemote(t, remote, repoC)
+
+	onMachine := func(name, home string) {
+		t.Setenv("DOTMAN_MACHINE", name)
+		t.Setenv("HOME", home)
+	}
+	sync := func(repo string) {
+		if err := SyncRepo(repo, SyncOptions{Download: true, Upload: true}); err != nil {
+			t.Fatalf("SyncRepo(%v) error = %v", repo, err)
+		}
+	}
 
-	// Remove one file
-	err := removeFromFile(infoPath, ".zshrc")
-	if err != nil {
-		t.Errorf("removeFromFile() error = %v", err)
+	onMachine("a", homeA)
+	if err := AddFile(testutils.NewMockUserInput(), filepath.Join(homeA, ".zshrc"), repoA, false); err != nil {
+		t.Fatalf("AddFile() error = %v", err)
 	}
+	sync(repoA)
 
-	// Verify only .vimrc remains
-	content, err := testutils.ReadFileContent(infoPath)
-	if err != nil {
-		t.Errorf("Failed to read info.json: %v", err)
+	onMachine("b", homeB)
+	sync(repoB)
+	testutils.AssertSymlink(t, filepath.Join(homeB, ".zshrc"), filepath.Join(repoB, ".zshrc"))
+
+	// machine c pulls the entry without ever deploying it
+	onMachine("c", homeC)
+	if err := SyncRepo(repoC, SyncOptions{Download: true, Upload: true, NoDeploy: true}); err != nil {
+		t.Fatalf("SyncRepo(%v) error = %v", repoC, err)
 	}
 
-	if !testutils.Contains(content, ".vimrc") {
-		t.Error("Expected .vimrc to remain in info.json")
+	onMachine("a", homeA)
+	sync(repoA)
+	if err := RemoveFile(".zshrc", filepath.Join(repoA, "info.json"), files.RetireRestore, false); err != nil {
+		t.Fatalf("RemoveFile() error = %v", err)
+	}
+	// machine b has not retired the entry yet, so the repository keeps the file
+	testutils.AssertFileExists(t, filepath.Join(repoA, ".zshrc"))
+	sync(repoA)
+
+	onMachine("b", homeB)
+	sync(repoB)
+	if isSym, _ := internal.IsSymlink(filepath.Join(homeB, ".zshrc")); isSym {
+		t.Error("Expected .zshrc on machine b to be a local copy after sync")
+	}
+	testutils.AssertFileContent(t, filepath.Join(homeB, ".zshrc"), "zsh configuration")
+	// machine c has not retired the entry yet either
+	testutils.AssertFileExists(t, filepath.Join(repoB, ".zshrc"))
+
+	// A machine that never had the entry linked does not get a copy
+	onMachine("c", homeC)
+	sync(repoC)
+	testutils.AssertFileNotExists(t, filepath.Join(homeC, ".zshrc"))
+	testutils.AssertFileNotExists(t, filepath.Join(repoC, ".zshrc"))
+
+	onMachine("a", homeA)
+	sync(repoA)
+	testutils.AssertFileNotExists(t, filepath.Join(repoA, ".zshrc"))
+	testutils.AssertFileContent(t, filepath.Join(homeA, ".zshrc"), "zsh configuration")
+
+	content, err := testutils.ReadFileContent(filepath.Join(repoA, "info.json"))
+	if err != nil {
+		t.Fatalf("Failed to read info.json: %v", err)
 	}
 	if testutils.Contains(content, ".zshrc") {
-		t.Error("Expected .zshrc to be removed from info.json")
+		t.Error("Expected tombstone to be garbage collected once both machines applied it")
 	}
 }
@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+)
+
+// otherRepos maps the other configured repositories to their info.json. A target one of them tracks is never
+// linked or added to the repository in use
+var otherRepos = map[string]string{}
+
+// SetOtherRepos names the repositories besides the one in use, by their info.json
+func SetOtherRepos(infoPaths map[string]string) {
+	otherRepos = infoPaths
+}
+
+// TargetConflict is a target path that more than one repository links
+type TargetConflict struct {
+	Target string
+	Repos  []string
+}
+
+// TargetConflicts reads the info.json of every repository and returns the targets tracked by more than one.
+// A repository without an info.json tracks nothing yet, and a layer overriding another is no conflict
+func TargetConflicts(infoPaths map[string]string) ([]TargetConflict, error) {
+	claims := map[string][]string{}
+	for repo, infoPath := range infoPaths {
+		entries, err := trackedEntries(infoPath)
+		if err != nil {
+			return nil, fmt.Errorf("repository %v: %w", repo, err)
+		}
+		for target := range entries {
+			claims[target] = append(claims[target], repo)
+		}
+	}
+
+	conflicts := []TargetConflict{}
+	for target, repos := range claims {
+		// Layers overriding each other count as one owner
+		owners, layered := 0, false
+		for _, repo := range repos {
+			if layerRank(repo) >= 0 {
+				layered = true
+			} else {
+				owners++
+			}
+		}
+		if layered {
+			owners++
+		}
+		if owners > 1 {
+			slices.Sort(repos)
+			conflicts = append(conflicts, TargetConflict{Target: target, Repos: repos})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Target < conflicts[j].Target })
+	return conflicts, nil
+}
+
+// claimedBy returns the other repositories that track target, by name
+func claimedBy(target string) ([]string, error) {
+	names := make([]string, 0, len(otherRepos))
+	for name := range otherRepos {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	claimed := []string{}
+	for _, name := range names {
+		entries, err := trackedEntries(otherRepos[name])
+		if err != nil {
+			return nil, fmt.Errorf("could not check repository %v: %w", name, err)
+		}
+		if _, ok := entries[target]; ok {
+			claimed = append(claimed, name)
+		}
+	}
+	return claimed, nil
+}
+
+// checkUnclaimed fails when another repository tracks target, unless it is a layer below the one in use
+func checkUnclaimed(target string) error {
+	repos, err := claimedBy(target)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		switch {
+		case overrides(currentRepo, repo):
+			internal.LogVerbose("%v overrides %v of layer %v", currentRepo, target, repo)
+		case overrides(repo, currentRepo):
+			return fmt.Errorf("%v is overridden by layer %v, change it there", internal.ShrinkPath(target), repo)
+		default:
+			return fmt.Errorf("%v is tracked by repository %v, remove it there first", internal.ShrinkPath(target),
+				repo)
+		}
+	}
+	return nil
+}
+
+// trackedEntries maps the targets of the live entries in an info.json to their entry
+func trackedEntries(infoPath string) (map[string]files.FileInfo, error) {
+	if _, err := os.Stat(infoPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	fileInfo, err := files.ReadFile(infoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %v: %w", infoPath, err)
+	}
+	entries := map[string]files.FileInfo{}
+	for _, info := range fileInfo {
+		if info.Tombstone == nil {
+			entries[info.Symlink] = info
+		}
+	}
+	return entries, nil
+}
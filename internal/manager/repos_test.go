@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/prompt"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestTargetsClaimedByAnotherRepository(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(testDir, "state"))
+
+	home := filepath.Join(testDir, "home")
+	personal := filepath.Join(testDir, "personal")
+	work := filepath.Join(testDir, "work")
+	gitconfig := filepath.Join(home, ".gitconfig")
+	for _, dir := range []string{home, personal, work} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := files.SaveStatus(filepath.Join(personal, "info.json"), map[string]files.FileInfo{
+		".gitconfig": {Symlink: gitconfig, Path: filepath.Join(personal, ".gitconfig"), Status: "ok"},
+		".zshrc":     {Symlink: filepath.Join(home, ".zshrc"), Path: filepath.Join(personal, ".zshrc"), Status: "ok"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.SaveStatus(filepath.Join(work, "info.json"), map[string]files.FileInfo{
+		".gitconfig": {Symlink: gitconfig, Path: filepath.Join(work, ".gitconfig"), Status: "ok"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	infoPaths := map[string]string{"personal": filepath.Join(personal, "info.json"),
+		"work": filepath.Join(work, "info.json"), "tools": filepath.Join(testDir, "tools", "info.json")}
+
+	conflicts, err := TargetConflicts(infoPaths)
+	if err != nil {
+		t.Fatalf("TargetConflicts() error = %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Target != gitconfig ||
+		strings.Join(conflicts[0].Repos, ",") != "personal,work" {
+		t.Errorf("TargetConflicts() = %+v, want .gitconfig in personal and work", conflicts)
+	}
+
+	// Working on work, personal guards its targets
+	previous := otherRepos
+	SetOtherRepos(map[string]string{"personal": infoPaths["personal"]})
+	t.Cleanup(func() { SetOtherRepos(previous) })
+
+	testutils.CreateTestFile(t, filepath.Join(work, ".gitconfig"), "work\n")
+	d := newDeployer(prompt.NonInteractive{})
+	_, err = d.link(".gitconfig", files.FileInfo{Symlink: gitconfig, Path: filepath.Join(work, ".gitconfig")})
+	if err == nil || !strings.Contains(err.Error(), "tracked by repository personal") {
+		t.Errorf("link() error = %v, want the target claimed by personal", err)
+	}
+	testutils.AssertFileNotExists(t, gitconfig)
+
+	testutils.CreateTestFile(t, filepath.Join(home, ".zshrc"), "zsh\n")
+	err = AddFile(prompt.NonInteractive{}, filepath.Join(home, ".zshrc"), work, false)
+	if err == nil || !strings.Contains(err.Error(), "tracked by repository personal") {
+		t.Errorf("AddFile() error = %v, want the target claimed by personal", err)
+	}
+	testutils.AssertFileContent(t, filepath.Join(home, ".zshrc"), "zsh\n")
+}
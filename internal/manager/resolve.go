@@ -0,0 +1,511 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/prompt"
+)
+
+// conflictSegment is either a run of cleanly merged lines or a conflicting hunk
+type conflictSegment struct {
+	Lines    []string
+	Conflict bool
+	Head     []string
+	Base     []string
+	Other    []string
+}
+
+// ResolveConflicts walks through every conflicted file, lets the user pick a side per file or per hunk,
+// stages the result and completes the sync once nothing is left unresolved
+func ResolveConflicts(folderPath string, opts SyncOptions) error {
+	operation, err := gitBackend.InProgress(folderPath)
+	if err != nil {
+		return fmt.Errorf("could not check repository state: %w", err)
+	}
+	if operation == git.OperationNone {
+		return fmt.Errorf("no merge or rebase in progress in %v", folderPath)
+	}
+
+	if _, err := autoResolveManifest(folderPath); err != nil {
+		return err
+	}
+	conflicts, err := gitBackend.ConflictedFiles(folderPath)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	names := entryNames(folderPath)
+	skipped := []string{}
+	for i, path := range conflicts {
+		name, ok := entryFor(names, path)
+		if !ok {
+			name = path
+		}
+		fmt.Printf("\n[%d/%d] Conflict in %v (%v)\n", i+1, len(conflicts), name, path)
+
+		resolved, err := resolveFile(opts.prompter(), folderPath, path, operation)
+		if err != nil {
+			return fmt.Errorf("could not resolve %v: %w", name, err)
+		}
+		if !resolved {
+			skipped = append(skipped, name)
+			continue
+		}
+
+		internal.LogVerbose("Staging %v", path)
+		if err := gitBackend.StagePath(folderPath, path); err != nil {
+			return fmt.Errorf("could not stage %v: %w", path, err)
+		}
+	}
+
+	if len(skipped) > 0 {
+		return fmt.Errorf("still unresolved: %v, run 'dotman resolve' again", strings.Join(skipped, ", "))
+	}
+
+	complete, err := opts.prompter().Confirm("resolve.complete",
+		"All conflicts resolved, do you want to complete the sync? (y/N)")
+	if err != nil {
+		return err
+	}
+	if complete {
+		return ContinueSync(folderPath, opts)
+	}
+	fmt.Println("Run 'dotman sync --continue' to complete the sync")
+
+	return nil
+}
+
+// resolveFile asks how a single file should be resolved and writes the result to the working tree
+func resolveFile(p prompt.Prompter, folderPath, path, operation string) (bool, error) {
+	fullPath := filepath.Join(folderPath, path)
+
+	// During a rebase HEAD is the upstream commit, so the sides are swapped compared to a merge
+	mineStage, theirsStage := git.StageOurs, git.StageTheirs
+	if operation == git.OperationRebase {
+		mineStage, theirsStage = git.StageTheirs, git.StageOurs
+	}
+
+	var segments []conflictSegment
+	if content, err := os.ReadFile(fullPath); err == nil {
+		segments = parseConflicts(string(content))
+	}
+	hunks := countHunks(segments)
+	printThreeWay(segments, operation)
+
+	msg := "Keep [m]ine, take [t]heirs, [e]dit file, [s]kip: "
+	choices := []string{"m", "t", "e", "s"}
+	if hunks > 0 {
+		msg = fmt.Sprintf("Keep [m]ine, take [t]heirs, resolve %d [h]unk(s), [e]dit file, [s]kip: ", hunks)
+		choices = append(choices, "h")
+	}
+
+	choice, err := p.Choose("resolve.file", msg, choices...)
+	if err != nil {
+		return false, err
+	}
+	switch choice {
+	case "m":
+		return takeStage(folderPath, path, mineStage)
+	case "t":
+		return takeStage(folderPath, path, theirsStage)
+	case "e":
+		if err := internal.OpenEditor(fullPath); err != nil {
+			return false, err
+		}
+		if hasConflictMarkers(fullPath) {
+			fmt.Println("Conflict markers are still present, leaving the file unresolved")
+			return false, nil
+		}
+		return true, nil
+	case "h":
+		return resolveHunks(p, fullPath, segments, operation)
+	default:
+		return false, nil
+	}
+}
+
+// resolveHunks asks for a side per conflicting hunk and writes the merged file
+func resolveHunks(p prompt.Prompter, fullPath string, segments []conflictSegment, operation string) (bool, error) {
+	hunks := countHunks(segments)
+	current := 0
+	for i, segment := range segments {
+		if !segment.Conflict {
+			continue
+		}
+		current++
+		mine, theirs := sides(segment, operation)
+		fmt.Printf("Hunk %d/%d\n", current, hunks)
+		printHunk(segment, operation)
+
+		choice, err := p.Choose("resolve.hunk", "Keep [m]ine, take [t]heirs, keep [b]oth, [e]dit hunk, [s]kip file: ",
+			"m", "t", "b", "e", "s")
+		if err != nil {
+			return false, err
+		}
+		switch choice {
+		case "m":
+			segments[i] = conflictSegment{Lines: mine}
+		case "t":
+			segments[i] = conflictSegment{Lines: theirs}
+		case "b":
+			segments[i] = conflictSegment{Lines: append(append([]string{}, mine...), theirs...)}
+		case "e":
+			lines, err := editHunk(fullPath, segment)
+			if err != nil {
+				return false, err
+			}
+			segments[i] = conflictSegment{Lines: lines}
+		default:
+			return false, nil
+		}
+	}
+
+	return true, writeKeepingMode(fullPath, renderSegments(segments))
+}
+
+// editHunk opens a single hunk in the editor, with markers, and returns what the user saved
+func editHunk(fullPath string, segment conflictSegment) ([]string, error) {
+	tmp, err := os.CreateTemp("", "dotman-hunk-*"+filepath.Ext(fullPath))
+	if err != nil {
+		return nil, fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(renderSegments([]conflictSegment{segment})); err != nil {
+		_ = tmp.Close()
+		return nil, fmt.Errorf("could not write temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("could not write temporary file: %w", err)
+	}
+
+	if err := internal.OpenEditor(tmp.Name()); err != nil {
+		return nil, err
+	}
+	if hasConflictMarkers(tmp.Name()) {
+		return nil, fmt.Errorf("conflict markers are still present in the edited hunk")
+	}
+
+	content, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("could not read edited hunk: %w", err)
+	}
+	return splitLines(string(content)), nil
+}
+
+// takeStage replaces the working file with one side of the conflict, deleting it if that side removed it
+func takeStage(folderPath, path string, stage int) (bool, error) {
+	fullPath := filepath.Join(folderPath, path)
+	content, exists, err := gitBackend.ShowStage(folderPath, stage, path)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		internal.LogVerbose("Chosen side deleted %v, removing it", path)
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("could not remove %v: %w", fullPath, err)
+		}
+		return true, nil
+	}
+	return true, writeKeepingMode(fullPath, content)
+}
+
+func writeKeepingMode(path, content string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		return fmt.Errorf("could not write %v: %w", path, err)
+	}
+	return nil
+}
+
+// parseConflicts splits file content on diff3 style conflict markers
+func parseConflicts(content string) []conflictSegment {
+	const (
+		plain = iota
+		head
+		base
+		other
+	)
+
+	segments := []conflictSegment{}
+	current := conflictSegment{}
+	state := plain
+	for _, line := range splitLines(content) {
+		switch {
+		case state == plain && strings.HasPrefix(line, "<<<<<<<"):
+			if len(current.Lines) > 0 {
+				segments = append(segments, current)
+			}
+			current = conflictSegment{Conflict: true}
+			state = head
+		case state == head && strings.HasPrefix(line, "|||||||"):
+			state = base
+		case (state == head || state == base) && strings.HasPrefix(line, "======="):
+			state = other
+		case state == other && strings.HasPrefix(line, ">>>>>>>"):
+			segments = append(segments, current)
+			current = conflictSegment{}
+			state = plain
+		case state == head:
+			current.Head = append(current.Head, line)
+		case state == base:
+			current.Base = append(current.Base, line)
+		case state == other:
+			current.Other = append(current.Other, line)
+		default:
+			current.Lines = append(current.Lines, line)
+		}
+	}
+	if current.Conflict || len(current.Lines) > 0 {
+		segments = append(segments, current)
+	}
+
+	return segments
+}
+
+// renderSegments joins segments back into file content, writing markers for unresolved hunks
+func renderSegments(segments []conflictSegment) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		if !segment.Conflict {
+			b.WriteString(strings.Join(segment.Lines, ""))
+			continue
+		}
+		b.WriteString("<<<<<<< HEAD\n")
+		b.WriteString(strings.Join(segment.Head, ""))
+		if segment.Base != nil {
+			b.WriteString("||||||| base\n")
+			b.WriteString(strings.Join(segment.Base, ""))
+		}
+		b.WriteString("=======\n")
+		b.WriteString(strings.Join(segment.Other, ""))
+		b.WriteString(">>>>>>> incoming\n")
+	}
+	return b.String()
+}
+
+// sides returns the lines of a hunk from the user's point of view
+func sides(segment conflictSegment, operation string) ([]string, []string) {
+	if operation == git.OperationRebase {
+		return segment.Other, segment.Head
+	}
+	return segment.Head, segment.Other
+}
+
+func countHunks(segments []conflictSegment) int {
+	count := 0
+	for _, segment := range segments {
+		if segment.Conflict {
+			count++
+		}
+	}
+	return count
+}
+
+func printThreeWay(segments []conflictSegment, operation string) {
+	current := 0
+	hunks := countHunks(segments)
+	for _, segment := range segments {
+		if !segment.Conflict {
+			continue
+		}
+		current++
+		fmt.Printf("--- hunk %d/%d ---\n", current, hunks)
+		printHunk(segment, operation)
+	}
+}
+
+func printHunk(segment conflictSegment, operation string) {
+	mine, theirs := sides(segment, operation)
+	if segment.Base != nil {
+		printSide("base", segment.Base)
+	}
+	printSide("mine", mine)
+	printSide("theirs", theirs)
+}
+
+func printSide(label string, lines []string) {
+	fmt.Printf("  %s:\n", label)
+	for _, line := range lines {
+		fmt.Printf("    | %s", line)
+		if !strings.HasSuffix(line, "\n") {
+			fmt.Println()
+		}
+	}
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// mergeManifest merges three versions of info.json entry by entry and returns the names changed differently
+// on both sides. Tombstones that only differ in which machines applied them are combined
+func mergeManifest(base, ours, theirs map[string]files.FileInfo) (map[string]files.FileInfo, []string) {
+	merged := map[string]files.FileInfo{}
+	clashes := []string{}
+
+	names := map[string]bool{}
+	for _, manifest := range []map[string]files.FileInfo{base, ours, theirs} {
+		for name := range manifest {
+			names[name] = true
+		}
+	}
+
+	for name := range names {
+		b, inBase := base[name]
+		o, inOurs := ours[name]
+		t, inTheirs := theirs[name]
+
+		switch {
+		case sameEntry(o, inOurs, t, inTheirs):
+			if inOurs {
+				merged[name] = o
+			}
+		case sameEntry(b, inBase, o, inOurs):
+			if inTheirs {
+				merged[name] = t
+			}
+		case sameEntry(b, inBase, t, inTheirs):
+			if inOurs {
+				merged[name] = o
+			}
+		case inOurs && inTheirs && o.Tombstone != nil && t.Tombstone != nil:
+			o.Tombstone.AppliedBy = unionStrings(o.Tombstone.AppliedBy, t.Tombstone.AppliedBy)
+			merged[name] = o
+		default:
+			clashes = append(clashes, name)
+		}
+	}
+	sort.Strings(clashes)
+
+	return merged, clashes
+}
+
+// sameEntry compares what matters of two entries, the locally computed status is ignored
+func sameEntry(a files.FileInfo, inA bool, b files.FileInfo, inB bool) bool {
+	if !inA || !inB {
+		return inA == inB
+	}
+	if a.Symlink != b.Symlink || a.Path != b.Path {
+		return false
+	}
+	if (a.Tombstone == nil) != (b.Tombstone == nil) {
+		return false
+	}
+	if a.Tombstone == nil {
+		return true
+	}
+	return a.Tombstone.Action == b.Tombstone.Action &&
+		slices.Equal(unionStrings(a.Tombstone.AppliedBy, nil), unionStrings(b.Tombstone.AppliedBy, nil))
+}
+
+func unionStrings(a, b []string) []string {
+	set := map[string]bool{}
+	for _, s := range append(append([]string{}, a...), b...) {
+		set[s] = true
+	}
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// manifestStage parses one index stage of a conflicted info.json, a missing stage is an empty manifest
+func manifestStage(folderPath string, stage int) (map[string]files.FileInfo, error) {
+	content, exists, err := gitBackend.ShowStage(folderPath, stage, "info.json")
+	if err != nil || !exists {
+		return map[string]files.FileInfo{}, err
+	}
+	return files.ParseFile([]byte(content))
+}
+
+// autoResolveManifest merges a conflicted info.json entry by entry and stages it when no entry clashes
+func autoResolveManifest(folderPath string) (bool, error) {
+	conflicts, err := gitBackend.ConflictedFiles(folderPath)
+	if err != nil || !slices.Contains(conflicts, "info.json") {
+		return false, err
+	}
+
+	manifests := make([]map[string]files.FileInfo, 0, 3)
+	for _, stage := range []int{git.StageBase, git.StageOurs, git.StageTheirs} {
+		manifest, err := manifestStage(folderPath, stage)
+		if err != nil {
+			return false, fmt.Errorf("could not read info.json from the index: %w", err)
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	merged, clashes := mergeManifest(manifests[0], manifests[1], manifests[2])
+	if len(clashes) > 0 {
+		internal.LogVerbose("info.json needs manual resolution, entries changed on both sides: %v",
+			strings.Join(clashes, ", "))
+		return false, nil
+	}
+
+	internal.LogVerbose("Merged info.json entry by entry")
+	if err := files.SaveStatus(filepath.Join(folderPath, "info.json"), merged); err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	if err := gitBackend.StagePath(folderPath, "info.json"); err != nil {
+		return false, fmt.Errorf("could not stage info.json: %w", err)
+	}
+	return true, nil
+}
+
+// settleConflicts resolves info.json automatically and keeps continuing the operation until it is done,
+// returning a ConflictError as soon as something needs the user
+func settleConflicts(folderPath string) error {
+	for {
+		operation, err := gitBackend.InProgress(folderPath)
+		if err != nil {
+			return fmt.Errorf("could not check repository state: %w", err)
+		}
+		if operation == git.OperationNone {
+			return nil
+		}
+
+		if _, err := autoResolveManifest(folderPath); err != nil {
+			return err
+		}
+		conflicts, err := gitBackend.ConflictedFiles(folderPath)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		if len(conflicts) > 0 {
+			return &ConflictError{Operation: operation, Entries: conflictEntries(folderPath, conflicts)}
+		}
+
+		internal.LogVerbose("No conflicts left, continuing %v", operation)
+		if operation == git.OperationRebase {
+			err = gitBackend.RebaseContinue(folderPath)
+		} else {
+			err = gitBackend.MergeContinue(folderPath)
+		}
+		if err != nil {
+			// Continuing failed without leaving new conflicts behind, so there is nothing more to settle
+			if conflicts, _ := gitBackend.ConflictedFiles(folderPath); len(conflicts) == 0 {
+				return fmt.Errorf("could not continue %v: %w", operation, err)
+			}
+		}
+	}
+}
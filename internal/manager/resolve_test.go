@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal/files"
+	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestParseConflicts(t *testing.T) {
+	content := "alias ll='ls -l'\n" +
+		"<<<<<<< HEAD\n" +
+		"export EDITOR=vim\n" +
+		"||||||| base\n" +
+		"export EDITOR=vi\n" +
+		"=======\n" +
+		"export EDITOR=nano\n" +
+		">>>>>>> origin/main\n" +
+		"export PATH=$PATH:~/bin\n"
+
+	segments := parseConflicts(content)
+	if len(segments) != 3 {
+		t.Fatalf("parseConflicts() returned %d segments, want 3", len(segments))
+	}
+	if countHunks(segments) != 1 {
+		t.Errorf("countHunks() = %d, want 1", countHunks(segments))
+	}
+
+	hunk := segments[1]
+	if hunk.Head[0] != "export EDITOR=vim\n" || hunk.Base[0] != "export EDITOR=vi\n" ||
+		hunk.Other[0] != "export EDITOR=nano\n" {
+		t.Errorf("Unexpected hunk content: %+v", hunk)
+	}
+
+	mine, theirs := sides(hunk, git.OperationRebase)
+	if mine[0] != "export EDITOR=nano\n" || theirs[0] != "export EDITOR=vim\n" {
+		t.Errorf("sides() during rebase should swap HEAD and incoming, got mine=%v theirs=%v", mine, theirs)
+	}
+
+	segments[1] = conflictSegment{Lines: hunk.Other}
+	expected := "alias ll='ls -l'\nexport EDITOR=nano\nexport PATH=$PATH:~/bin\n"
+	if got := renderSegments(segments); got != expected {
+		t.Errorf("renderSegments() = %q, want %q", got, expected)
+	}
+}
+
+func TestTakeStageDuringRebase(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	_, machineB := setupDivergedRepos(t, testDir)
+
+	err := SyncRepo(machineB, SyncOptions{Download: true, Strategy: git.StrategyRebase})
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected ConflictError, got %v", err)
+	}
+
+	// During a rebase the local commit is stage 3, so "mine" must still be the local content
+	resolved, err := takeStage(machineB, ".zshrc", git.StageTheirs)
+	if err != nil || !resolved {
+		t.Fatalf("takeStage() = %v, %v", resolved, err)
+	}
+	testutils.AssertFileContent(t, filepath.Join(machineB, ".zshrc"), "export EDITOR=nano\n")
+
+	if err := gitBackend.StagePath(machineB, ".zshrc"); err != nil {
+		t.Fatalf("StagePath() error = %v", err)
+	}
+	err = ContinueSync(machineB, SyncOptions{Upload: true})
+	if err != nil {
+		t.Fatalf("ContinueSync() error = %v", err)
+	}
+}
+
+func TestMergeManifest(t *testing.T) {
+	zsh := files.FileInfo{Symlink: "/home/u/.zshrc", Path: "/home/u/dotfiles/.zshrc"}
+	vim := files.FileInfo{Symlink: "/home/u/.vimrc", Path: "/home/u/dotfiles/.vimrc"}
+	tmux := files.FileInfo{Symlink: "/home/u/.tmux.conf", Path: "/home/u/dotfiles/.tmux.conf"}
+	movedZsh := files.FileInfo{Symlink: "/home/u/.config/zsh/.zshrc", Path: zsh.Path}
+
+	base := map[string]files.FileInfo{".zshrc": zsh}
+	ours := map[string]files.FileInfo{".zshrc": zsh, ".vimrc": vim}
+	theirs := map[string]files.FileInfo{".zshrc": movedZsh, ".tmux.conf": tmux}
+
+	merged, clashes := mergeManifest(base, ours, theirs)
+	if len(clashes) != 0 {
+		t.Fatalf("mergeManifest() clashes = %v, want none", clashes)
+	}
+	if len(merged) != 3 || merged[".zshrc"].Symlink != movedZsh.Symlink {
+		t.Errorf("mergeManifest() = %v", merged)
+	}
+
+	ours[".zshrc"] = files.FileInfo{Symlink: "/home/u/.zsh/.zshrc", Path: zsh.Path}
+	_, clashes = mergeManifest(base, ours, theirs)
+	if len(clashes) != 1 || clashes[0] != ".zshrc" {
+		t.Errorf("mergeManifest() clashes = %v, want [.zshrc]", clashes)
+	}
+}
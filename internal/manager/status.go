@@ -29,9 +29,21 @@ func CheckStatus(filePath string) error {
 	}
 
 	errorFiles := make(map[string]files.FileInfo)
+	overridden, err := overriddenTargets()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
 
 	internal.LogVerbose("Checking entries")
 	for filename, info := range fileInfo {
+		if info.Tombstone != nil {
+			internal.LogVerbose("Skipping %s, it was removed by %s", filename, info.Tombstone.RemovedBy)
+			continue
+		}
+		if layer, ok := overridden[info.Symlink]; ok {
+			internal.LogVerbose("Skipping %s, layer %s overrides it", filename, layer)
+			continue
+		}
 		internal.LogVerbose("Resetting errors before continue")
 		if len(info.Errors) > 0 {
 			info.Errors = nil
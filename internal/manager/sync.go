@@ -1,91 +1,468 @@
 package manager
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
 	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/hooks"
+	"github.com/ZonCen/dotman/internal/prompt"
 )
 
-func SyncRepo(folderPath string, dryrun, download, upload bool) error {
+// SyncOptions controls which parts of a sync are run and how remote changes are integrated
+type SyncOptions struct {
+	DryRun    bool
+	Download  bool
+	Upload    bool
+	Strategy  string
+	Autostash bool
+	NoDeploy  bool
+	Branch    string
+	// ReadOnly repositories are only pulled, local changes are never committed or pushed and removed entries
+	// are retired without recording it in the repository
+	ReadOnly bool
+	// Prompter answers the questions a sync asks, nil fails every question
+	Prompter prompt.Prompter
+}
+
+func (o SyncOptions) prompter() prompt.Prompter {
+	if o.Prompter == nil {
+		return prompt.NonInteractive{}
+	}
+	return o.Prompter
+}
+
+// ErrInterrupted is returned when dotman was interrupted, the repository is left as it was before the step
+// that was running
+var ErrInterrupted = errors.New("interrupted")
+
+// ConflictError is returned when a pull stopped with unmerged files that need resolving
+type ConflictError struct {
+	Operation string
+	Entries   []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v stopped with conflicts in: %v (resolve them and run 'dotman sync --continue' "+
+		"or back out with 'dotman sync --abort')", e.Operation, strings.Join(e.Entries, ", "))
+}
+
+func SyncRepo(folderPath string, opts SyncOptions) error {
 	internal.LogVerbose("Checking for valid repository")
-	code, err := git.CheckIfRepo(folderPath)
-	if err != nil || code != 0 {
+	isRepo, err := gitBackend.IsRepo(folderPath)
+	if err != nil || !isRepo {
 		return fmt.Errorf("not a git repository: %s", folderPath)
 	}
 
-	internal.LogVerbose("Repository detected at %v", folderPath)
-	if dryrun {
-		internal.LogVerbose("[dry-run] Collecting local changes")
-	} else {
-		internal.LogVerbose("Collecting local changes")
+	if opts.Strategy == "" {
+		opts.Strategy = git.StrategyFFOnly
+	}
+	if !git.ValidStrategy(opts.Strategy) {
+		return fmt.Errorf("unknown pull strategy %q", opts.Strategy)
 	}
 
-	output, err := git.Status(folderPath)
+	operation, err := gitBackend.InProgress(folderPath)
 	if err != nil {
-		return fmt.Errorf("failed to collect status: %w", err)
+		return fmt.Errorf("could not check repository state: %w", err)
 	}
-	if strings.TrimSpace(output) == "" {
-		if dryrun {
-			internal.LogVerbose("[dry-run] no changes detected")
-			return nil
-		} else {
-			internal.LogVerbose("No changes detected")
-			return nil
-		}
+	if operation != git.OperationNone {
+		return fmt.Errorf("a %v is already in progress, run 'dotman sync --continue' or 'dotman sync --abort'",
+			operation)
 	}
 
-	if dryrun {
-		internal.LogVerbose("[dry-run] Changes detected, following files would be staged " +
-			"and committed with commit message 'dotman sync':")
-		printChanges(output)
+	opts.Branch, err = syncBranch(folderPath, opts.Branch)
+	if err != nil {
+		return err
+	}
+	if opts.ReadOnly && opts.Upload {
+		internal.LogVerbose("%v is read-only, only pulling", folderPath)
+		opts.Upload = false
+	}
 
+	if opts.DryRun {
+		preview, err := PreviewSync(folderPath, opts.Branch)
+		if err != nil {
+			return err
+		}
+		printPreview(preview)
 		return nil
 	}
 
-	if upload {
-		internal.LogVerbose("Following files will be committed and pushed:")
+	if err := runHooks(opts.prompter(), folderPath, hooks.PreSync, nil); err != nil {
+		return fmt.Errorf("pre-sync hook aborted the sync: %w", err)
+	}
+
+	internal.LogVerbose("Repository detected at %v", folderPath)
+	if err := excludeIgnored(folderPath); err != nil {
+		return err
+	}
+	internal.LogVerbose("Collecting local changes")
+	output, err := gitBackend.Status(folderPath)
+	if err != nil {
+		return fmt.Errorf("failed to collect status: %w", err)
+	}
+
+	if opts.Upload && strings.TrimSpace(output) != "" {
+		internal.LogVerbose("Following files will be committed:")
 		printChanges(output)
 
-		if _, err := git.Add(folderPath); err != nil {
-			return fmt.Errorf("could not stage repo folder: %w", err)
+		if err := commitChanges(folderPath, "dotman sync"); err != nil {
+			return err
 		}
+	} else {
+		internal.LogVerbose("No local changes to commit")
+	}
 
-		code, _ = git.Diff(folderPath)
-		if code == 1 {
-			if _, err := git.Commit(folderPath, "dotman sync"); err != nil {
-				return fmt.Errorf("could not commit changes: %w", err)
+	var offline error
+	if opts.Download {
+		if err := checkInterrupted(); err != nil {
+			return err
+		}
+		if err := pullChanges(folderPath, opts); err != nil {
+			if !isOffline(err) {
+				return err
 			}
-		} else if code != 0 {
-			return fmt.Errorf("git diff failed with exit code %d", code)
+			internal.LogVerbose("Remote unreachable, skipping the pull: %v", err)
+			offline = err
 		}
+	}
+
+	return finishSync(folderPath, opts, offline)
+}
 
-		if _, err := git.Push(folderPath); err != nil {
-			return fmt.Errorf("could not push changes: %w", err)
+// ContinueSync finishes a merge or rebase that stopped on conflicts and pushes the result
+func ContinueSync(folderPath string, opts SyncOptions) error {
+	operation, err := gitBackend.InProgress(folderPath)
+	if err != nil {
+		return fmt.Errorf("could not check repository state: %w", err)
+	}
+	if operation == git.OperationNone {
+		return fmt.Errorf("no merge or rebase in progress in %v", folderPath)
+	}
+
+	if _, err := autoResolveManifest(folderPath); err != nil {
+		return err
+	}
+	conflicts, err := gitBackend.ConflictedFiles(folderPath)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	unresolved := []string{}
+	for _, conflict := range conflicts {
+		if hasConflictMarkers(filepath.Join(folderPath, conflict)) {
+			unresolved = append(unresolved, conflict)
 		}
 	}
+	if len(unresolved) > 0 {
+		entries := conflictEntries(folderPath, unresolved)
+		return fmt.Errorf("conflict markers are still present in: %v", strings.Join(entries, ", "))
+	}
 
-	if download {
-		output, err := git.Status(folderPath)
-		if err != nil {
-			return fmt.Errorf("failed to collect status: %w", err)
+	internal.LogVerbose("Staging resolved files")
+	if err := gitBackend.Add(folderPath); err != nil {
+		return fmt.Errorf("could not stage repo folder: %w", err)
+	}
+
+	before, err := gitBackend.RevParse(folderPath, "ORIG_HEAD")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	internal.LogVerbose("Continuing %v", operation)
+	if operation == git.OperationRebase {
+		err = gitBackend.RebaseContinue(folderPath)
+	} else {
+		err = gitBackend.MergeContinue(folderPath)
+	}
+	if err != nil {
+		if !stillInProgress(folderPath) {
+			return fmt.Errorf("could not continue %v: %w", operation, err)
+		}
+		if err := settleConflicts(folderPath); err != nil {
+			return err
+		}
+	}
+
+	if opts.Branch == "" {
+		if opts.Branch, err = gitBackend.CurrentBranch(folderPath); err != nil {
+			return fmt.Errorf("%w", err)
 		}
+	}
+
+	if err := afterPull(folderPath, before, opts); err != nil {
+		return err
+	}
+
+	return finishSync(folderPath, opts, nil)
+}
+
+// AbortSync backs out of a merge or rebase that stopped on conflicts
+func AbortSync(folderPath string) error {
+	operation, err := gitBackend.InProgress(folderPath)
+	if err != nil {
+		return fmt.Errorf("could not check repository state: %w", err)
+	}
+
+	internal.LogVerbose("Aborting %v", operation)
+	switch operation {
+	case git.OperationRebase:
+		err = gitBackend.RebaseAbort(folderPath)
+	case git.OperationMerge:
+		err = gitBackend.MergeAbort(folderPath)
+	default:
+		return fmt.Errorf("no merge or rebase in progress in %v", folderPath)
+	}
+	if err != nil {
+		return fmt.Errorf("could not abort %v: %w", operation, err)
+	}
+
+	return nil
+}
+
+// checkInterrupted stops between two steps once dotman was interrupted
+func checkInterrupted() error {
+	if internal.Interrupted() {
+		return fmt.Errorf("%w, nothing was changed after the last completed step", ErrInterrupted)
+	}
+	return nil
+}
 
-		if strings.TrimSpace(output) != "" {
-			if !internal.ConfirmWithUser("[warning] Local changes detected, pull may fail or cause conflicts. Continue? (y/N)") {
-				return fmt.Errorf("aborting downloading changes from git")
+// recoverInterrupted backs out of a merge or rebase that an interrupt left half done
+func recoverInterrupted(folderPath string, err error) error {
+	_ = internal.Uninterruptible(func() error {
+		if stillInProgress(folderPath) {
+			internal.LogVerbose("Backing out of the interrupted pull")
+			if err := AbortSync(folderPath); err != nil {
+				fmt.Printf("[warning] %v, run 'dotman sync --abort'\n", err)
 			}
 		}
+		return nil
+	})
+	return fmt.Errorf("%w, any unfinished merge or rebase was backed out: %w", ErrInterrupted, err)
+}
 
-		if _, err := git.Pull(folderPath); err != nil {
-			return fmt.Errorf("could not pull changes: %w", err)
+// syncBranch returns the branch to sync, making sure it is the one checked out
+func syncBranch(folderPath, want string) (string, error) {
+	current, err := gitBackend.CurrentBranch(folderPath)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+	if current == "HEAD" {
+		return "", fmt.Errorf("no branch is checked out in %v", folderPath)
+	}
+	if want != "" && current != want {
+		return "", fmt.Errorf("%v is checked out but dotman is configured to sync %v, run 'dotman branch switch %v'",
+			current, want, want)
+	}
+	return current, nil
+}
+
+// finishSync retires removed entries, commits the bookkeeping that produced and pushes to every push remote.
+// pullErr is set when the pull was skipped because the primary remote could not be reached, nothing is pushed
+// then and the sync is recorded as pending instead
+func finishSync(folderPath string, opts SyncOptions, pullErr error) error {
+	if opts.ReadOnly {
+		opts.Upload = false
+		if err := retireRemoved(folderPath); err != nil {
+			return err
+		}
+	} else {
+		internal.LogVerbose("Applying removal tombstones")
+		if _, err := applyTombstones(folderPath); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	var pushed, offline []string
+	var offlineErr, pushErr error
+	if opts.Upload {
+		if err := applyIgnore(folderPath); err != nil {
+			return err
+		}
+		if err := commitChanges(folderPath, "dotman sync"); err != nil {
+			return err
+		}
+		if err := checkInterrupted(); err != nil {
+			return err
+		}
+
+		if pullErr == nil {
+			results := pushAll(folderPath, opts.Branch)
+			results.print()
+			pushed, offline, offlineErr, pushErr = results.split()
+		} else {
+			offline = pushRemotes
+		}
+	}
+	if pullErr != nil {
+		offlineErr = pullErr
+	}
+
+	if err := settlePending(folderPath, opts.Download && pullErr == nil, pushed); err != nil {
+		return err
+	}
+	if offlineErr != nil {
+		if err := markPending(folderPath, opts.Branch, opts.Download && pullErr != nil, offline, offlineErr); err != nil {
+			return err
+		}
+	}
+	if pushErr != nil {
+		return fmt.Errorf("could not push changes: %w", pushErr)
+	}
+	if offlineErr != nil {
+		return fmt.Errorf("%w: %w", ErrPending, offlineErr)
+	}
+	return nil
+}
+
+func commitChanges(folderPath, message string) error {
+	if err := gitBackend.Add(folderPath); err != nil {
+		return fmt.Errorf("could not stage repo folder: %w", err)
+	}
+
+	staged, err := gitBackend.HasStagedChanges(folderPath)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if staged {
+		if err := gitBackend.Commit(folderPath, message); err != nil {
+			return fmt.Errorf("could not commit changes: %w", err)
 		}
 	}
 
 	return nil
 }
 
+func pullChanges(folderPath string, opts SyncOptions) error {
+	output, err := gitBackend.Status(folderPath)
+	if err != nil {
+		return fmt.Errorf("failed to collect status: %w", err)
+	}
+
+	if strings.TrimSpace(output) != "" && !opts.Autostash {
+		ok, err := opts.prompter().Confirm("sync.pull-with-changes",
+			"[warning] Local changes detected, pull may fail or cause conflicts. Continue? (y/N)")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborting downloading changes from git")
+		}
+	}
+
+	exists, err := gitBackend.RemoteBranchExists(folderPath, primaryRemote, opts.Branch)
+	if err != nil {
+		return fmt.Errorf("could not check %v for %v: %w", primaryRemote, opts.Branch, explainGitError(err))
+	}
+	if !exists {
+		internal.LogVerbose("%v does not exist on %v yet, nothing to pull", opts.Branch, primaryRemote)
+		return nil
+	}
+
+	before, err := gitBackend.RevParse(folderPath, "HEAD")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	internal.LogVerbose("Pulling %v from %v using the %v strategy", opts.Branch, primaryRemote, opts.Strategy)
+	if err := gitBackend.Pull(folderPath, primaryRemote, opts.Branch, opts.Strategy, opts.Autostash); err != nil {
+		if internal.Interrupted() {
+			return recoverInterrupted(folderPath, err)
+		}
+		if !stillInProgress(folderPath) {
+			return fmt.Errorf("could not pull changes: %w", explainGitError(err))
+		}
+		if err := settleConflicts(folderPath); err != nil {
+			return err
+		}
+	}
+
+	return afterPull(folderPath, before, opts)
+}
+
+// stillInProgress reports whether a failed git command left a merge or rebase behind to settle
+func stillInProgress(folderPath string) bool {
+	operation, err := gitBackend.InProgress(folderPath)
+	return err == nil && operation != git.OperationNone
+}
+
+// conflictEntries maps repository paths to their info.json entry names where possible
+func conflictEntries(folderPath string, conflicts []string) []string {
+	names := entryNames(folderPath)
+	seen := map[string]bool{}
+	entries := []string{}
+	for _, conflict := range conflicts {
+		name, ok := entryFor(names, conflict)
+		if !ok {
+			name = conflict
+		}
+		if !seen[name] {
+			seen[name] = true
+			entries = append(entries, name)
+		}
+	}
+	sort.Strings(entries)
+
+	return entries
+}
+
+// entryNames returns the info.json entry names keyed by their path relative to the repository
+func entryNames(folderPath string) map[string]string {
+	names := map[string]string{}
+	fileInfo, err := files.ReadFile(filepath.Join(folderPath, "info.json"))
+	if err != nil {
+		internal.LogVerbose("Could not read info.json, showing repository paths: %v", err)
+		return names
+	}
+	for name, info := range fileInfo {
+		rel, err := filepath.Rel(folderPath, info.Path)
+		if err != nil {
+			continue
+		}
+		names[filepath.ToSlash(rel)] = name
+	}
+	return names
+}
+
+// entryFor returns the name of the entry a repository path belongs to, the entry is the path itself or a tracked
+// directory it is in
+func entryFor(names map[string]string, path string) (string, bool) {
+	for {
+		if name, ok := names[path]; ok {
+			return name, true
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return "", false
+		}
+		path = path[:i]
+	}
+}
+
+func hasConflictMarkers(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> ") {
+			return true
+		}
+	}
+	return false
+}
+
 func printChanges(output string) {
 	for _, change := range git.ListChanges(output) {
 		internal.LogVerbose(change)
@@ -0,0 +1,219 @@
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/git"
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+// setupDivergedRepos creates two clones of the same remote that each commit a different .zshrc
+func setupDivergedRepos(t *testing.T, testDir string) (string, string) {
+	remote := testutils.SetupGitRemote(t, testDir)
+
+	machineA := filepath.Join(testDir, "machineA")
+	machineB := filepath.Join(testDir, "machineB")
+	testutils.CloneGitRemote(t, remote, machineA)
+	testutils.CloneGitRemote(t, remote, machineB)
+
+	testutils.CreateTestFile(t, filepath.Join(machineA, ".zshrc"), "export EDITOR=vim\n")
+	testutils.CreateTestFile(t, filepath.Join(machineA, "info.json"), `{
+  ".zshrc": {
+    "symlink": "~/.zshrc",
+    "path": "`+filepath.Join(machineA, ".zshrc")+`",
+    "status": "ok",
+    "errors": null
+  }
+}`)
+	err := SyncRepo(machineA, SyncOptions{Download: true, Upload: true})
+	if err != nil {
+		t.Fatalf("SyncRepo() on machineA error = %v", err)
+	}
+
+	testutils.CreateTestFile(t, filepath.Join(machineB, ".zshrc"), "export EDITOR=nano\n")
+	testutils.RunGit(t, machineB, "add", "-A")
+	testutils.RunGit(t, machineB, "commit", "-m", "local change")
+
+	return machineA, machineB
+}
+
+func TestSyncRepoFastForwardOnlyDiverged(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	_, machineB := setupDivergedRepos(t, testDir)
+
+	err := SyncRepo(machineB, SyncOptions{Download: true, Upload: true, Strategy: git.StrategyFFOnly})
+	if !errors.Is(err, ErrGitDiverged) {
+		t.Fatalf("Expected ErrGitDiverged when histories have diverged with ff-only, got %v", err)
+	}
+
+	operation, _ := gitBackend.InProgress(machineB)
+	if operation != git.OperationNone {
+		t.Errorf("Expected no operation in progress, got %v", operation)
+	}
+}
+
+func TestSyncRepoUploadRejected(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	_, machineB := setupDivergedRepos(t, testDir)
+
+	err := SyncRepo(machineB, SyncOptions{Upload: true})
+	if !errors.Is(err, ErrGitRejected) {
+		t.Fatalf("Expected ErrGitRejected when pushing without pulling first, got %v", err)
+	}
+	var cmdErr *internal.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Stderr == "" {
+		t.Errorf("Expected the error to carry git's stderr, got %v", err)
+	}
+}
+
+func TestSyncRepoMergeConflictContinue(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	machineA, machineB := setupDivergedRepos(t, testDir)
+
+	err := SyncRepo(machineB, SyncOptions{Download: true, Upload: true, Strategy: git.StrategyMerge})
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected ConflictError, got %v", err)
+	}
+	if conflictErr.Operation != git.OperationMerge {
+		t.Errorf("ConflictError.Operation = %v, want %v", conflictErr.Operation, git.OperationMerge)
+	}
+	if len(conflictErr.Entries) != 1 || conflictErr.Entries[0] != ".zshrc" {
+		t.Errorf("ConflictError.Entries = %v, want [.zshrc]", conflictErr.Entries)
+	}
+
+	// Continuing with markers still in place must fail
+	err = ContinueSync(machineB, SyncOptions{Upload: true})
+	if err == nil {
+		t.Error("Expected error when continuing with unresolved conflict markers")
+	}
+
+	testutils.CreateTestFile(t, filepath.Join(machineB, ".zshrc"), "export EDITOR=hx\n")
+	err = ContinueSync(machineB, SyncOptions{Upload: true})
+	if err != nil {
+		t.Fatalf("ContinueSync() error = %v", err)
+	}
+
+	err = SyncRepo(machineA, SyncOptions{Download: true, Upload: true})
+	if err != nil {
+		t.Fatalf("SyncRepo() on machineA error = %v", err)
+	}
+	testutils.AssertFileContent(t, filepath.Join(machineA, ".zshrc"), "export EDITOR=hx\n")
+}
+
+func TestConflictEntries(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	testutils.CreateTestFile(t, filepath.Join(testDir, "info.json"), `{
+  ".zshrc": {"symlink": "~/.zshrc", "path": "`+filepath.Join(testDir, ".zshrc")+`"},
+  "nvim": {"symlink": "~/.config/nvim", "path": "`+filepath.Join(testDir, "nvim")+`"}
+}`)
+
+	// Files inside a tracked directory are shown once, as the directory entry
+	entries := conflictEntries(testDir, []string{"nvim/init.lua", ".zshrc", "nvim/lua/plugins.lua", "README.md"})
+	want := []string{".zshrc", "README.md", "nvim"}
+	if !slices.Equal(entries, want) {
+		t.Errorf("conflictEntries() = %v, want %v", entries, want)
+	}
+}
+
+func TestSyncRepoRebaseConflictAbort(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	_, machineB := setupDivergedRepos(t, testDir)
+
+	err := SyncRepo(machineB, SyncOptions{Download: true, Upload: true, Strategy: git.StrategyRebase})
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected ConflictError, got %v", err)
+	}
+	if conflictErr.Operation != git.OperationRebase {
+		t.Errorf("ConflictError.Operation = %v, want %v", conflictErr.Operation, git.OperationRebase)
+	}
+
+	// A new sync must refuse to start while the rebase is unfinished
+	err = SyncRepo(machineB, SyncOptions{Download: true, Upload: true})
+	if err == nil {
+		t.Error("Expected error when syncing with a rebase in progress")
+	}
+
+	err = AbortSync(machineB)
+	if err != nil {
+		t.Fatalf("AbortSync() error = %v", err)
+	}
+	testutils.AssertFileContent(t, filepath.Join(machineB, ".zshrc"), "export EDITOR=nano\n")
+
+	operation, _ := gitBackend.InProgress(machineB)
+	if operation != git.OperationNone {
+		t.Errorf("Expected no operation in progress after abort, got %v", operation)
+	}
+}
+
+func TestSyncRepoDeploysManifestChanges(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	remote := testutils.SetupGitRemote(t, testDir)
+	machineA := filepath.Join(testDir, "machineA")
+	machineB := filepath.Join(testDir, "machineB")
+	testutils.CloneGitRemote(t, remote, machineA)
+	testutils.CloneGitRemote(t, remote, machineB)
+
+	homeDir := filepath.Join(testDir, "home")
+	vimLink := filepath.Join(homeDir, ".vimrc")
+	zshLink := filepath.Join(homeDir, ".zshrc")
+	testutils.CreateTestFile(t, filepath.Join(homeDir, ".keep"), "")
+	testutils.CreateTestFile(t, filepath.Join(machineA, ".vimrc"), "set number\n")
+	testutils.CreateTestFile(t, filepath.Join(machineA, ".zshrc"), "export EDITOR=vim\n")
+	testutils.CreateTestFile(t, filepath.Join(machineA, "info.json"), `{
+  ".vimrc": {"symlink": "`+vimLink+`", "path": "`+filepath.Join(machineB, ".vimrc")+`"},
+  ".zshrc": {"symlink": "`+zshLink+`", "path": "`+filepath.Join(machineB, ".zshrc")+`"}
+}`)
+	if err := SyncRepo(machineA, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() on machineA error = %v", err)
+	}
+
+	// Entries added on machineA are linked on machineB
+	if err := SyncRepo(machineB, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() on machineB error = %v", err)
+	}
+	testutils.AssertSymlink(t, vimLink, filepath.Join(machineB, ".vimrc"))
+	testutils.AssertSymlink(t, zshLink, filepath.Join(machineB, ".zshrc"))
+
+	// .vimrc is removed and .zshrc is moved to another link on machineA
+	newZshLink := filepath.Join(homeDir, ".config", "zsh", ".zshrc")
+	testutils.CreateTestFile(t, filepath.Join(homeDir, ".config", "zsh", ".keep"), "")
+	testutils.RunGit(t, machineA, "rm", "-q", ".vimrc")
+	testutils.CreateTestFile(t, filepath.Join(machineA, "info.json"), `{
+  ".zshrc": {"symlink": "`+newZshLink+`", "path": "`+filepath.Join(machineB, ".zshrc")+`"}
+}`)
+	if err := SyncRepo(machineA, SyncOptions{Download: true, Upload: true}); err != nil {
+		t.Fatalf("SyncRepo() on machineA error = %v", err)
+	}
+
+	// With --no-deploy nothing changes locally
+	if err := SyncRepo(machineB, SyncOptions{Download: true, NoDeploy: true}); err != nil {
+		t.Fatalf("SyncRepo() on machineB error = %v", err)
+	}
+	testutils.AssertSymlink(t, zshLink, filepath.Join(machineB, ".zshrc"))
+
+	testutils.RunGit(t, machineB, "reset", "-q", "--hard", "HEAD~1")
+	if err := SyncRepo(machineB, SyncOptions{Download: true}); err != nil {
+		t.Fatalf("SyncRepo() on machineB error = %v", err)
+	}
+	testutils.AssertFileNotExists(t, vimLink)
+	testutils.AssertFileNotExists(t, zshLink)
+	testutils.AssertSymlink(t, newZshLink, filepath.Join(machineB, ".zshrc"))
+}
@@ -0,0 +1,210 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ZonCen/dotman/internal"
+	"github.com/ZonCen/dotman/internal/files"
+)
+
+func machinesPath(folderPath string) string {
+	return filepath.Join(folderPath, "machines.json")
+}
+
+// applyTombstones retires every removed entry this machine has not handled yet and garbage collects
+// tombstones that all known machines have applied. It reports whether info.json was changed
+func applyTombstones(folderPath string) (bool, error) {
+	infoPath := filepath.Join(folderPath, "info.json")
+	fileInfo, err := files.ReadFile(infoPath)
+	if err != nil {
+		return false, fmt.Errorf("could not read info.json: %w", err)
+	}
+
+	machine := internal.MachineName()
+	machines, err := files.RegisterMachine(machinesPath(folderPath), machine)
+	if err != nil {
+		return false, fmt.Errorf("could not register machine: %w", err)
+	}
+
+	changed := false
+	names := make([]string, 0, len(fileInfo))
+	for name := range fileInfo {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := fileInfo[name]
+		if info.Tombstone == nil || info.Tombstone.AppliedOn(machine) {
+			continue
+		}
+		internal.LogVerbose("Retiring %v (%v) removed by %v", name, info.Tombstone.Action, info.Tombstone.RemovedBy)
+		if err := retireEntry(info); err != nil {
+			return changed, fmt.Errorf("could not retire %v: %w", name, err)
+		}
+		fmt.Printf("Retired %v removed on %v\n", name, info.Tombstone.RemovedBy)
+		info.Tombstone.AppliedBy = append(info.Tombstone.AppliedBy, machine)
+		fileInfo[name] = info
+		changed = true
+	}
+
+	if collectTombstones(fileInfo, machines) {
+		changed = true
+	}
+
+	if changed {
+		if err := files.SaveStatus(infoPath, fileInfo); err != nil {
+			return changed, fmt.Errorf("%w", err)
+		}
+	}
+	return changed, nil
+}
+
+// retireRemoved retires the removed entries of a read-only repository that are still linked. Nothing is written
+// to the repository, so an entry that is no longer linked counts as retired
+func retireRemoved(folderPath string) error {
+	fileInfo, err := files.ReadFile(filepath.Join(folderPath, "info.json"))
+	if err != nil {
+		return fmt.Errorf("could not read info.json: %w", err)
+	}
+	names := make([]string, 0, len(fileInfo))
+	for name := range fileInfo {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := fileInfo[name]
+		if info.Tombstone == nil {
+			continue
+		}
+		if ok, _ := checkSamePath(info.Symlink, info.Path); !ok {
+			continue
+		}
+		if err := retireEntry(info); err != nil {
+			return fmt.Errorf("could not retire %v: %w", name, err)
+		}
+		fmt.Printf("Retired %v removed on %v\n", name, info.Tombstone.RemovedBy)
+	}
+	return nil
+}
+
+// retireEntry removes the symlink of an entry and, for restore tombstones, puts a local copy in its place. Only a
+// target that was linked into the repository gets a copy, a machine that never deployed the entry gets nothing. A
+// layer below that tracks the target is linked instead of either
+func retireEntry(info files.FileInfo) error {
+	deployed, _ := checkSamePath(info.Symlink, info.Path)
+	if err := unlinkEntry(info); err != nil {
+		return err
+	}
+	if linked, err := linkLowerLayer(info.Symlink); err != nil || linked {
+		return err
+	}
+	if info.Tombstone.Action == files.RetireRestore && !deployed {
+		internal.LogVerbose("%v was not linked on this machine, nothing to restore", info.Symlink)
+	} else if info.Tombstone.Action == files.RetireRestore {
+		if err := restoreCopy(info); err != nil {
+			return err
+		}
+	}
+	// A restored copy keeps the directories it is in, only empty ones dotman created are removed
+	return removeCreatedDirs(info.Symlink)
+}
+
+// restoreCopy puts a local copy of the repository file where the link was, unless something is there already
+func restoreCopy(info files.FileInfo) error {
+	if _, err := os.Lstat(info.Symlink); err == nil {
+		internal.LogVerbose("%v already exists, keeping it", info.Symlink)
+		return nil
+	}
+	if !internal.FileExist(info.Path) {
+		return fmt.Errorf("file %v to restore does not exist", info.Path)
+	}
+	internal.LogVerbose("Restoring a local copy of %v at %v", info.Path, info.Symlink)
+	return copyFile(info.Path, info.Symlink)
+}
+
+// collectTombstones drops entries, and their repository files, once every known machine has retired them
+func collectTombstones(fileInfo map[string]files.FileInfo, machines map[string]string) bool {
+	changed := false
+	for name, info := range fileInfo {
+		if info.Tombstone == nil {
+			continue
+		}
+		done := true
+		for machine := range machines {
+			if !info.Tombstone.AppliedOn(machine) {
+				done = false
+				break
+			}
+		}
+		if !done {
+			continue
+		}
+		internal.LogVerbose("All known machines retired %v, removing it from the repository", name)
+		if err := os.RemoveAll(info.Path); err != nil {
+			internal.LogVerbose("Could not remove %v: %v", info.Path, err)
+			continue
+		}
+		delete(fileInfo, name)
+		changed = true
+	}
+	return changed
+}
+
+// newTombstone creates a tombstone already applied on this machine
+func newTombstone(action string) *files.Tombstone {
+	machine := internal.MachineName()
+	return &files.Tombstone{
+		Action:    action,
+		RemovedBy: machine,
+		RemovedAt: time.Now().UTC().Format(time.RFC3339),
+		AppliedBy: []string{machine},
+	}
+}
+
+func copyFile(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("could not stat %v: %w", src, err)
+	}
+	if info.IsDir() {
+		return copyDir(src, dest)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("could not open %v: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("could not create %v: %w", dest, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("could not copy %v: %w", src, err)
+	}
+	return out.Close()
+}
+
+func copyDir(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyFile(path, target)
+	})
+}
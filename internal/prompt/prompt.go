@@ -0,0 +1,163 @@
+// Package prompt asks the user questions through an interchangeable Prompter, so dotman can run from a
+// terminal, with fixed answers, from an answers file or refuse to guess when nobody can answer
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInputRequired is returned when a question needs an answer that can not be given
+var ErrInputRequired = errors.New("input required but dotman is running non-interactively")
+
+// Prompter answers the questions dotman asks. Every question has a stable key, such as init.git-init,
+// which is also how it is answered in an answers file
+type Prompter interface {
+	// Confirm asks a yes/no question, anything but yes counts as no
+	Confirm(key, question string) (bool, error)
+	// Choose asks for one of the given single letter choices
+	Choose(key, question string, choices ...string) (string, error)
+}
+
+func inputRequired(key, question string) error {
+	return fmt.Errorf("%w: %q (%v), answer it with --yes, --no or in an --answers file", ErrInputRequired,
+		strings.TrimSpace(question), key)
+}
+
+// Terminal reads answers line by line, an empty line declines a question. Running out of input is an error
+// instead of a silent no
+type Terminal struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewTerminal returns a prompter that asks on out and reads the answers from in
+func NewTerminal(in io.Reader, out io.Writer) *Terminal {
+	return &Terminal{in: bufio.NewReader(in), out: out}
+}
+
+// Stdio returns a prompter on the standard input and output
+func Stdio() *Terminal {
+	return NewTerminal(os.Stdin, os.Stdout)
+}
+
+func (t *Terminal) readLine(key, question string) (string, error) {
+	fmt.Fprint(t.out, question)
+	line, err := t.in.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		fmt.Fprintln(t.out)
+		return "", inputRequired(key, question)
+	}
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
+
+func (t *Terminal) Confirm(key, question string) (bool, error) {
+	for {
+		answer, err := t.readLine(key, question)
+		if err != nil {
+			return false, err
+		}
+		if yes, ok := parseYesNo(answer); ok {
+			return yes, nil
+		}
+		if answer == "" {
+			return false, nil
+		}
+		fmt.Fprintln(t.out, "Please enter y or n")
+	}
+}
+
+func (t *Terminal) Choose(key, question string, choices ...string) (string, error) {
+	for {
+		answer, err := t.readLine(key, question)
+		if err != nil {
+			return "", err
+		}
+		if slices.Contains(choices, answer) {
+			return answer, nil
+		}
+		fmt.Fprintf(t.out, "Please enter one of %v\n", strings.Join(choices, "/"))
+	}
+}
+
+// Fixed answers every yes/no question the same way, as --yes and --no do. Choices can not be answered
+type Fixed bool
+
+func (f Fixed) Confirm(key, question string) (bool, error) {
+	return bool(f), nil
+}
+
+func (f Fixed) Choose(key, question string, choices ...string) (string, error) {
+	return "", inputRequired(key, question)
+}
+
+// NonInteractive fails every question
+type NonInteractive struct{}
+
+func (NonInteractive) Confirm(key, question string) (bool, error) {
+	return false, inputRequired(key, question)
+}
+
+func (NonInteractive) Choose(key, question string, choices ...string) (string, error) {
+	return "", inputRequired(key, question)
+}
+
+// Answers answers questions by key and passes the ones it has no answer for on to Fallback
+type Answers struct {
+	Values   map[string]string
+	Fallback Prompter
+}
+
+// LoadAnswers reads an answers file, a YAML map from question key to answer
+func LoadAnswers(path string, fallback Prompter) (*Answers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read answers: %w", err)
+	}
+	values := map[string]string{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+	}
+	return &Answers{Values: values, Fallback: fallback}, nil
+}
+
+func (a *Answers) Confirm(key, question string) (bool, error) {
+	answer, ok := a.Values[key]
+	if !ok {
+		return a.Fallback.Confirm(key, question)
+	}
+	yes, ok := parseYesNo(strings.ToLower(strings.TrimSpace(answer)))
+	if !ok {
+		return false, fmt.Errorf("answer %q for %v is not yes or no", answer, key)
+	}
+	return yes, nil
+}
+
+func (a *Answers) Choose(key, question string, choices ...string) (string, error) {
+	answer, ok := a.Values[key]
+	if !ok {
+		return a.Fallback.Choose(key, question, choices...)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if !slices.Contains(choices, answer) {
+		return "", fmt.Errorf("answer %q for %v is not one of %v", answer, key, strings.Join(choices, "/"))
+	}
+	return answer, nil
+}
+
+func parseYesNo(answer string) (bool, bool) {
+	switch answer {
+	case "y", "yes", "true":
+		return true, true
+	case "n", "no", "false":
+		return false, true
+	}
+	return false, false
+}
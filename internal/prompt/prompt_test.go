@@ -0,0 +1,101 @@
+package prompt
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTerminal(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		{"yes", "y\n", true, false},
+		{"no", "no\n", false, false},
+		{"empty line declines", "\n", false, false},
+		{"retries until valid", "maybe\nyes\n", true, false},
+		{"last line without newline", "y", true, false},
+		{"no input", "", false, true},
+		{"input runs out", "maybe\n", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terminal := NewTerminal(strings.NewReader(tt.input), io.Discard)
+			got, err := terminal.Confirm("test.key", "Continue? (y/N)")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Confirm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrInputRequired) {
+				t.Errorf("Confirm() error = %v, want ErrInputRequired", err)
+			}
+			if got != tt.want {
+				t.Errorf("Confirm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	terminal := NewTerminal(strings.NewReader("x\nT\n"), io.Discard)
+	if got, err := terminal.Choose("test.choice", "Pick: ", "m", "t"); err != nil || got != "t" {
+		t.Errorf("Choose() = %v, %v, want t", got, err)
+	}
+}
+
+func TestFixedAndNonInteractive(t *testing.T) {
+	if yes, err := Fixed(true).Confirm("k", "q"); err != nil || !yes {
+		t.Errorf("Fixed(true).Confirm() = %v, %v", yes, err)
+	}
+	if yes, err := Fixed(false).Confirm("k", "q"); err != nil || yes {
+		t.Errorf("Fixed(false).Confirm() = %v, %v", yes, err)
+	}
+	if _, err := Fixed(true).Choose("k", "q", "m", "t"); !errors.Is(err, ErrInputRequired) {
+		t.Errorf("Fixed.Choose() error = %v, want ErrInputRequired", err)
+	}
+	_, err := NonInteractive{}.Confirm("init.git-init", "Initialize? ")
+	if !errors.Is(err, ErrInputRequired) || !strings.Contains(err.Error(), "init.git-init") {
+		t.Errorf("NonInteractive.Confirm() error = %v, want ErrInputRequired naming the key", err)
+	}
+}
+
+func TestAnswers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "answers.yaml")
+	content := "init.git-init: yes\ninit.symlinks: n\nresolve.file: T\nhooks.trust: sometimes\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	answers, err := LoadAnswers(path, NonInteractive{})
+	if err != nil {
+		t.Fatalf("LoadAnswers() error = %v", err)
+	}
+
+	if yes, err := answers.Confirm("init.git-init", "q"); err != nil || !yes {
+		t.Errorf("Confirm(init.git-init) = %v, %v, want yes", yes, err)
+	}
+	if yes, err := answers.Confirm("init.symlinks", "q"); err != nil || yes {
+		t.Errorf("Confirm(init.symlinks) = %v, %v, want no", yes, err)
+	}
+	if got, err := answers.Choose("resolve.file", "q", "m", "t"); err != nil || got != "t" {
+		t.Errorf("Choose(resolve.file) = %v, %v, want t", got, err)
+	}
+	if _, err := answers.Choose("resolve.file", "q", "m", "s"); err == nil {
+		t.Error("Choose() with an answer that is not a choice succeeded")
+	}
+	if _, err := answers.Confirm("hooks.trust", "q"); err == nil {
+		t.Error("Confirm() with an answer that is not yes or no succeeded")
+	}
+	// Questions without an answer go to the fallback
+	if _, err := answers.Confirm("init.checkout", "q"); !errors.Is(err, ErrInputRequired) {
+		t.Errorf("Confirm(init.checkout) error = %v, want ErrInputRequired from the fallback", err)
+	}
+
+	if _, err := LoadAnswers(filepath.Join(dir, "missing.yaml"), NonInteractive{}); err == nil {
+		t.Error("LoadAnswers() of a missing file succeeded")
+	}
+}
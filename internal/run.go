@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// stderrTailLines is how much of a failed command's stderr is kept in its error
+const stderrTailLines = 20
+
+// Limits for external commands, a zero timeout disables it. The config can override them
+var (
+	CommandTimeout = 10 * time.Minute
+	NetworkTimeout = 2 * time.Minute
+	NetworkRetries = 2
+	RetryBackoff   = 2 * time.Second
+)
+
+// NonInteractive stops git from prompting for credentials, so commands fail instead of waiting for input
+var NonInteractive bool
+
+// runContext is cancelled when dotman is interrupted, which stops the command that is running
+var runContext = context.Background()
+
+// SetContext makes every external command stop when ctx is cancelled
+func SetContext(ctx context.Context) {
+	runContext = ctx
+}
+
+// Interrupted reports whether dotman received an interrupt and should stop at the next safe point
+func Interrupted() bool {
+	return runContext.Err() != nil
+}
+
+// Uninterruptible runs fn with commands that ignore an interrupt, to put the repository back in order after one
+func Uninterruptible(fn func() error) error {
+	previous := runContext
+	runContext = context.WithoutCancel(previous)
+	defer func() { runContext = previous }()
+	return fn()
+}
+
+// CommandError describes a command that failed together with the end of what it wrote to stderr,
+// so callers can tell an authentication problem from a rejected push
+type CommandError struct {
+	Command  string
+	Args     []string
+	ExitCode int
+	Stderr   string
+	TimedOut time.Duration
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%v %v failed", e.Command, strings.Join(e.Args, " "))
+	switch {
+	case e.TimedOut > 0:
+		msg += fmt.Sprintf(": timed out after %v", e.TimedOut)
+	case errors.Is(e.Err, context.Canceled):
+		msg += ": interrupted"
+	case e.ExitCode >= 0:
+		msg += fmt.Sprintf(" with exit code %d", e.ExitCode)
+	default:
+		msg += fmt.Sprintf(": %v", e.Err)
+	}
+	if e.Stderr != "" {
+		msg += ":\n  " + strings.ReplaceAll(e.Stderr, "\n", "\n  ")
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// tail returns the last lines of output, without surrounding whitespace
+func tail(output string, lines int) string {
+	all := strings.Split(strings.TrimSpace(output), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
+
+// run executes the command with stdout going to stdout, stderr is captured and also shown under --verbose.
+// On interrupt or timeout the command gets SIGINT first, so git can remove its lock files before it is killed
+func run(timeout time.Duration, env []string, stdout io.Writer, name string, args ...string) (int, error) {
+	ctx, cancel := runContext, context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = 5 * time.Second
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if Verbose {
+		cmd.Stderr = io.MultiWriter(&stderr, os.Stderr)
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	err := cmd.Run()
+	if err == nil {
+		return 0, nil
+	}
+
+	code := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	}
+	cmdErr := &CommandError{
+		Command:  name,
+		Args:     args,
+		ExitCode: code,
+		Stderr:   tail(stderr.String(), stderrTailLines),
+		Err:      err,
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		cmdErr.Err = ctxErr
+		if errors.Is(ctxErr, context.DeadlineExceeded) && runContext.Err() == nil {
+			cmdErr.TimedOut = timeout
+		}
+	}
+	return code, cmdErr
+}
+
+// Run executes a command and returns its exit code, -1 when it could not be started. Its output is only
+// shown under --verbose, failures return a *CommandError
+func Run(name string, args ...string) (int, error) {
+	var stdout io.Writer
+	if Verbose {
+		stdout = os.Stdout
+	}
+	return run(CommandTimeout, nil, stdout, name, args...)
+}
+
+// RunOutput executes a command and returns its stdout, failures return a *CommandError
+func RunOutput(name string, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	_, err := run(CommandTimeout, nil, &stdout, name, args...)
+	return stdout.String(), err
+}
+
+// RunNetwork executes a command that talks to a remote like Run, with the network timeout, without
+// credential prompts in non-interactive mode and retrying transient failures with backoff
+func RunNetwork(name string, args ...string) (int, error) {
+	var stdout io.Writer
+	if Verbose {
+		stdout = os.Stdout
+	}
+	return runNetwork(stdout, name, args...)
+}
+
+// RunNetworkOutput is RunNetwork returning stdout, output of failed attempts is discarded
+func RunNetworkOutput(name string, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	_, err := runNetwork(&stdout, name, args...)
+	return stdout.String(), err
+}
+
+func runNetwork(stdout io.Writer, name string, args ...string) (int, error) {
+	var env []string
+	if NonInteractive {
+		env = append(env, "GIT_TERMINAL_PROMPT=0")
+		if os.Getenv("GIT_SSH_COMMAND") == "" {
+			env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+		}
+	}
+
+	var code int
+	err := Retry(func() error {
+		if buffer, ok := stdout.(*bytes.Buffer); ok {
+			buffer.Reset()
+		}
+		var err error
+		code, err = run(NetworkTimeout, env, stdout, name, args...)
+		return err
+	})
+	return code, err
+}
+
+// transientPatterns are failures that can go away by themselves, such as a flaky connection
+var transientPatterns = []string{
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"connection timed out",
+	"connection reset",
+	"operation timed out",
+	"the remote end hung up unexpectedly",
+	"early eof",
+	"rpc failed",
+	"network is unreachable",
+	"returned error: 502",
+	"returned error: 503",
+	"returned error: 504",
+}
+
+// IsTransient reports whether retrying err may succeed, interrupts and timeouts are never retried
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range transientPatterns {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retry calls op until it succeeds, fails permanently or NetworkRetries retries have been used,
+// doubling the wait after every attempt
+func Retry(op func() error) error {
+	wait := RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if attempt >= NetworkRetries || !IsTransient(err) {
+			return err
+		}
+		LogVerbose("Transient failure, retrying in %v: %v", wait, err)
+		select {
+		case <-time.After(wait):
+		case <-runContext.Done():
+			return err
+		}
+		wait *= 2
+	}
+}
+
+// NetworkContext returns a context for a network operation done in process, bounded by the network timeout
+func NetworkContext() (context.Context, context.CancelFunc) {
+	if NetworkTimeout > 0 {
+		return context.WithTimeout(runContext, NetworkTimeout)
+	}
+	return context.WithCancel(runContext)
+}
+
+// Command returns a command that stops with SIGINT when dotman is interrupted, for commands that run attached
+// to the terminal such as hooks and editors
+func Command(name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(runContext, name, args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	return cmd
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCapturesStderr(t *testing.T) {
+	code, err := Run("sh", "-c", "echo fine; echo 'fatal: bad things' >&2; exit 3")
+	if code != 3 {
+		t.Errorf("Run() code = %v, want 3", code)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("Run() error = %v, want *CommandError", err)
+	}
+	if cmdErr.Command != "sh" || cmdErr.ExitCode != 3 || cmdErr.Stderr != "fatal: bad things" {
+		t.Errorf("CommandError = %+v", cmdErr)
+	}
+	if !strings.Contains(err.Error(), "fatal: bad things") {
+		t.Errorf("Error() = %q, want it to include stderr", err.Error())
+	}
+}
+
+func TestRunOutputKeepsStderrTail(t *testing.T) {
+	script := fmt.Sprintf("echo out; for i in $(seq 1 %d); do echo line$i >&2; done; exit 1", stderrTailLines+5)
+	out, err := RunOutput("sh", "-c", script)
+	if out != "out\n" {
+		t.Errorf("RunOutput() = %q, want stdout only", out)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("RunOutput() error = %v, want *CommandError", err)
+	}
+	lines := strings.Split(cmdErr.Stderr, "\n")
+	if len(lines) != stderrTailLines || lines[len(lines)-1] != fmt.Sprintf("line%d", stderrTailLines+5) {
+		t.Errorf("Stderr kept %d lines ending in %q", len(lines), lines[len(lines)-1])
+	}
+}
+
+func TestRunMissingCommand(t *testing.T) {
+	code, err := Run("dotman-command-that-does-not-exist")
+	var cmdErr *CommandError
+	if code != -1 || !errors.As(err, &cmdErr) {
+		t.Errorf("Run() = %v, %v, want -1 and a *CommandError", code, err)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	previous := CommandTimeout
+	CommandTimeout = 100 * time.Millisecond
+	defer func() { CommandTimeout = previous }()
+
+	start := time.Now()
+	_, err := Run("sleep", "5")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.TimedOut != CommandTimeout {
+		t.Fatalf("Run() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run() took %v, want it stopped at the timeout", elapsed)
+	}
+}
+
+func TestRunInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	SetContext(ctx)
+	defer SetContext(context.Background())
+	cancel()
+
+	if !Interrupted() {
+		t.Error("Interrupted() = false after cancelling the context")
+	}
+	_, err := Run("true")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	err = Uninterruptible(func() error {
+		_, err := Run("true")
+		return err
+	})
+	if err != nil {
+		t.Errorf("Run() inside Uninterruptible error = %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	previousRetries, previousBackoff := NetworkRetries, RetryBackoff
+	NetworkRetries, RetryBackoff = 2, time.Millisecond
+	defer func() { NetworkRetries, RetryBackoff = previousRetries, previousBackoff }()
+
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"success", nil, 1},
+		{"permanent failure", errors.New("fatal: Authentication failed"), 1},
+		{"transient failure", errors.New("fatal: unable to access: Could not resolve host: github.com"), 3},
+		{"timeout", fmt.Errorf("push: %w", context.DeadlineExceeded), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := Retry(func() error {
+				attempts++
+				return tt.err
+			})
+			if attempts != tt.attempts || err != tt.err {
+				t.Errorf("Retry() = %v after %d attempts, want %v after %d", err, attempts, tt.err, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRunNetworkNonInteractive(t *testing.T) {
+	previousRetries, previous := NetworkRetries, NonInteractive
+	NetworkRetries, NonInteractive = 0, true
+	defer func() { NetworkRetries, NonInteractive = previousRetries, previous }()
+
+	_, err := RunNetwork("sh", "-c", `echo "prompt=$GIT_TERMINAL_PROMPT" >&2; exit 1`)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Stderr != "prompt=0" {
+		t.Errorf("RunNetwork() error = %v, want GIT_TERMINAL_PROMPT=0 in the environment", err)
+	}
+}
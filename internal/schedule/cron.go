@@ -0,0 +1,158 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// cronMarker tags the crontab line dotman owns so it can be replaced and removed
+const cronMarker = "# dotman-schedule"
+
+// Cron installs the schedule as a line in the user's crontab
+type Cron struct{}
+
+// CronSpec turns an interval into a cron schedule, which only works for intervals that evenly divide an hour
+// or a day
+func CronSpec(every time.Duration) (string, error) {
+	switch {
+	case every == 24*time.Hour:
+		return "0 0 * * *", nil
+	case every == time.Hour:
+		return "0 * * * *", nil
+	case every >= time.Hour && every%time.Hour == 0 && (24*time.Hour)%every == 0:
+		return fmt.Sprintf("0 */%d * * *", every/time.Hour), nil
+	case every >= time.Minute && every < time.Hour && every%time.Minute == 0 && time.Hour%every == 0:
+		return fmt.Sprintf("*/%d * * * *", every/time.Minute), nil
+	}
+	return "", fmt.Errorf("cron can not run every %v, use an interval that divides an hour or a day", every)
+}
+
+// CronLine runs sync and then status, appending both to the log
+func CronLine(opts Options) (string, error) {
+	spec, err := CronSpec(opts.Every)
+	if err != nil {
+		return "", err
+	}
+	log := quote(opts.LogPath)
+	return fmt.Sprintf("%v %v </dev/null >>%v 2>&1; %v >>%v 2>&1 %v every=%v",
+		spec, opts.command(quote, "sync", "--non-interactive"), log, opts.command(quote, "status"), log,
+		cronMarker, opts.Every), nil
+}
+
+// UpdateCrontab replaces dotman's line in crontab with line, an empty line only removes it
+func UpdateCrontab(crontab, line string) string {
+	lines := []string{}
+	for _, existing := range strings.Split(crontab, "\n") {
+		if existing == "" || strings.Contains(existing, cronMarker) {
+			continue
+		}
+		lines = append(lines, existing)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// findCronLine returns dotman's line from crontab
+func findCronLine(crontab string) (string, bool) {
+	for _, line := range strings.Split(crontab, "\n") {
+		if strings.Contains(line, cronMarker) {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// readCrontab returns the current crontab, an empty one when the user has none
+func readCrontab() (string, error) {
+	out, err := internal.RunOutput("crontab", "-l")
+	if err != nil {
+		var cmdErr *internal.CommandError
+		if errors.As(err, &cmdErr) && strings.Contains(cmdErr.Stderr, "no crontab") {
+			return "", nil
+		}
+		return "", fmt.Errorf("could not read crontab: %w", err)
+	}
+	return out, nil
+}
+
+func writeCrontab(content string) error {
+	tmp, err := os.CreateTemp("", "dotman-crontab")
+	if err != nil {
+		return fmt.Errorf("could not write crontab: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("could not write crontab: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write crontab: %w", err)
+	}
+	if _, err := internal.Run("crontab", tmp.Name()); err != nil {
+		return fmt.Errorf("could not install crontab: %w", err)
+	}
+	return nil
+}
+
+func (Cron) Install(opts Options) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	line, err := CronLine(opts)
+	if err != nil {
+		return err
+	}
+	if err := internal.CreateFolder(filepath.Dir(opts.LogPath)); err != nil {
+		return err
+	}
+	crontab, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	internal.LogVerbose("Adding to crontab: %v", line)
+	return writeCrontab(UpdateCrontab(crontab, line))
+}
+
+func (Cron) Status() (Status, error) {
+	status := Status{Backend: BackendCron}
+	crontab, err := readCrontab()
+	if err != nil {
+		return status, err
+	}
+	line, ok := findCronLine(crontab)
+	if !ok {
+		return status, nil
+	}
+	status.Installed = true
+	status.Active = "active"
+	status.Files = []string{line}
+	if _, every, ok := strings.Cut(line, cronMarker+" every="); ok {
+		status.Every = strings.TrimSpace(every)
+	}
+	if _, log, ok := strings.Cut(line, " >>"); ok {
+		status.LogPath = strings.Trim(strings.Fields(log)[0], "'")
+	}
+	return status, nil
+}
+
+func (Cron) Remove() error {
+	crontab, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	if _, ok := findCronLine(crontab); !ok {
+		return nil
+	}
+	return writeCrontab(UpdateCrontab(crontab, ""))
+}
@@ -0,0 +1,112 @@
+// Package schedule installs a recurring dotman sync and status check as a systemd user timer or a cron job
+package schedule
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// Backends a schedule can be installed with
+const (
+	BackendSystemd = "systemd"
+	BackendCron    = "cron"
+)
+
+// Options describes what to run and how often
+type Options struct {
+	Every   time.Duration
+	Binary  string
+	LogPath string
+	// Config is the config the schedule was installed with, passed on with --config
+	Config string
+	// Repo picks the repository to sync with --repo, All syncs every configured repository instead
+	Repo string
+	All  bool
+}
+
+// Status describes an installed schedule
+type Status struct {
+	Backend   string
+	Installed bool
+	Every     string
+	Active    string
+	NextRun   string
+	LogPath   string
+	Files     []string
+}
+
+// Scheduler installs, inspects and removes the schedule with one backend
+type Scheduler interface {
+	Install(opts Options) error
+	Status() (Status, error)
+	Remove() error
+}
+
+// DefaultOptions runs this dotman binary and logs next to the rest of dotman's state
+func DefaultOptions(every time.Duration) (Options, error) {
+	binary, err := os.Executable()
+	if err != nil {
+		return Options{}, fmt.Errorf("could not find the dotman binary: %w", err)
+	}
+	return Options{Every: every, Binary: binary, LogPath: filepath.Join(internal.StateDir(), "schedule.log")}, nil
+}
+
+// New returns the scheduler for backend, an empty backend picks systemd when it manages the user session
+func New(backend string) (Scheduler, error) {
+	switch backend {
+	case "":
+		if HasSystemd() {
+			return NewSystemd(), nil
+		}
+		return Cron{}, nil
+	case BackendSystemd:
+		return NewSystemd(), nil
+	case BackendCron:
+		return Cron{}, nil
+	}
+	return nil, fmt.Errorf("unknown schedule backend %q, use %v or %v", backend, BackendSystemd, BackendCron)
+}
+
+// HasSystemd reports whether systemd is running and systemctl is available
+func HasSystemd() bool {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return false
+	}
+	return internal.FolderExist("/run/systemd/system")
+}
+
+func (o Options) validate() error {
+	if o.Every < time.Minute {
+		return fmt.Errorf("interval %v is too short, use at least 1m", o.Every)
+	}
+	if o.Binary == "" || o.LogPath == "" {
+		return fmt.Errorf("binary and log path are required")
+	}
+	return nil
+}
+
+// command returns the dotman subcommand with its flags, the flags the schedule was installed with last. quote
+// makes the values safe for the backend
+func (o Options) command(quote func(string) string, args ...string) string {
+	if o.Config != "" {
+		args = append(args, "--config", quote(o.Config))
+	}
+	switch {
+	case o.Repo != "":
+		args = append(args, "--repo", quote(o.Repo))
+	case o.All:
+		args = append(args, "--all")
+	}
+	return quote(o.Binary) + " " + strings.Join(args, " ")
+}
+
+// quote makes a path safe to use as a single word in a shell command
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
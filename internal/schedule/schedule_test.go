@@ -0,0 +1,144 @@
+package schedule
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZonCen/dotman/internal/testutils"
+)
+
+func TestSystemdInstallStatusRemove(t *testing.T) {
+	testDir := testutils.TestDir(t)
+	defer testutils.CleanupTestDir(t, testDir)
+
+	scheduler := Systemd{UnitDir: filepath.Join(testDir, "systemd", "user")}
+	opts := Options{
+		Every:   time.Hour,
+		Binary:  "/usr/local/bin/dotman",
+		LogPath: filepath.Join(testDir, "state", "schedule.log"),
+	}
+
+	if err := scheduler.Install(opts); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	service, err := testutils.ReadFileContent(filepath.Join(scheduler.UnitDir, ServiceName))
+	if err != nil {
+		t.Fatalf("could not read service: %v", err)
+	}
+	for _, want := range []string{
+		`ExecStart="/usr/local/bin/dotman" sync --non-interactive`,
+		`ExecStopPost="/usr/local/bin/dotman" status`,
+		"StandardInput=null",
+		"StandardOutput=append:" + opts.LogPath,
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service does not contain %q:\n%v", want, service)
+		}
+	}
+	timer, err := testutils.ReadFileContent(filepath.Join(scheduler.UnitDir, TimerName))
+	if err != nil {
+		t.Fatalf("could not read timer: %v", err)
+	}
+	if !strings.Contains(timer, "OnUnitActiveSec=3600s") || !strings.Contains(timer, "WantedBy=timers.target") {
+		t.Errorf("unexpected timer:\n%v", timer)
+	}
+
+	status, err := scheduler.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if !status.Installed || status.Every != "1h0m0s" || status.LogPath != opts.LogPath {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	if err := scheduler.Remove(); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	testutils.AssertFileNotExists(t, filepath.Join(scheduler.UnitDir, ServiceName))
+	testutils.AssertFileNotExists(t, filepath.Join(scheduler.UnitDir, TimerName))
+	if status, _ := scheduler.Status(); status.Installed {
+		t.Error("Status() still installed after Remove()")
+	}
+}
+
+func TestSystemdRejectsShortInterval(t *testing.T) {
+	scheduler := Systemd{UnitDir: t.TempDir()}
+	err := scheduler.Install(Options{Every: 10 * time.Second, Binary: "dotman", LogPath: "log"})
+	if err == nil {
+		t.Error("Expected error for an interval below a minute")
+	}
+}
+
+func TestCronSpec(t *testing.T) {
+	tests := []struct {
+		every   time.Duration
+		want    string
+		wantErr bool
+	}{
+		{15 * time.Minute, "*/15 * * * *", false},
+		{time.Hour, "0 * * * *", false},
+		{6 * time.Hour, "0 */6 * * *", false},
+		{24 * time.Hour, "0 0 * * *", false},
+		{7 * time.Minute, "", true},
+		{5 * time.Hour, "", true},
+	}
+	for _, tt := range tests {
+		got, err := CronSpec(tt.every)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("CronSpec(%v) = %q, %v, want %q", tt.every, got, err, tt.want)
+		}
+	}
+}
+
+func TestUpdateCrontab(t *testing.T) {
+	line, err := CronLine(Options{Every: time.Hour, Binary: "/bin/dotman", LogPath: "/tmp/dotman.log"})
+	if err != nil {
+		t.Fatalf("CronLine() error = %v", err)
+	}
+	want := "0 * * * * '/bin/dotman' sync --non-interactive </dev/null >>'/tmp/dotman.log' 2>&1; " +
+		"'/bin/dotman' status >>'/tmp/dotman.log' 2>&1 # dotman-schedule every=1h0m0s"
+	if line != want {
+		t.Errorf("CronLine() = %q, want %q", line, want)
+	}
+
+	existing := "MAILTO=me\n@daily backup\n0 0 * * * old " + cronMarker + " every=24h0m0s\n"
+	updated := UpdateCrontab(existing, line)
+	if updated != "MAILTO=me\n@daily backup\n"+line+"\n" {
+		t.Errorf("UpdateCrontab() = %q", updated)
+	}
+	if removed := UpdateCrontab(updated, ""); removed != "MAILTO=me\n@daily backup\n" {
+		t.Errorf("UpdateCrontab() removing = %q", removed)
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	opts := Options{Every: time.Hour, Binary: "/bin/dotman", LogPath: "/tmp/dotman.log",
+		Config: "/home/me/my config.yaml", All: true}
+
+	line, err := CronLine(opts)
+	if err != nil {
+		t.Fatalf("CronLine() error = %v", err)
+	}
+	for _, want := range []string{
+		"'/bin/dotman' sync --non-interactive --config '/home/me/my config.yaml' --all </dev/null",
+		"'/bin/dotman' status --config '/home/me/my config.yaml' --all >>",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("CronLine() = %q, want it to contain %q", line, want)
+		}
+	}
+
+	// A single repository picked with --repo wins over --all
+	opts.Repo = "work"
+	service := ServiceUnit(opts)
+	for _, want := range []string{
+		`ExecStart="/bin/dotman" sync --non-interactive --config "/home/me/my config.yaml" --repo "work"`,
+		`ExecStopPost="/bin/dotman" status --config "/home/me/my config.yaml" --repo "work"`,
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("ServiceUnit() does not contain %q:\n%v", want, service)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package schedule
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ZonCen/dotman/internal"
+)
+
+// Unit names written by the systemd backend
+const (
+	ServiceName = "dotman-sync.service"
+	TimerName   = "dotman-sync.timer"
+)
+
+// Systemd installs a user service and timer into UnitDir. With Manage set systemctl is used to
+// reload, enable and inspect them, tests leave it unset to only generate the units
+type Systemd struct {
+	UnitDir string
+	Manage  bool
+}
+
+// NewSystemd writes units to the user unit directory and manages them with systemctl
+func NewSystemd() Systemd {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, _ := os.UserHomeDir()
+		configHome = filepath.Join(home, ".config")
+	}
+	return Systemd{UnitDir: filepath.Join(configHome, "systemd", "user"), Manage: true}
+}
+
+func (s Systemd) servicePath() string {
+	return filepath.Join(s.UnitDir, ServiceName)
+}
+
+func (s Systemd) timerPath() string {
+	return filepath.Join(s.UnitDir, TimerName)
+}
+
+// systemdQuote quotes an argument of ExecStart, escaping what systemd would otherwise expand
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "%", "%%")
+	s = strings.ReplaceAll(s, "$", "$$")
+	return `"` + s + `"`
+}
+
+// ServiceUnit runs sync without a terminal and always checks status afterwards, appending both to the log
+func ServiceUnit(opts Options) string {
+	return fmt.Sprintf(`[Unit]
+Description=Sync dotfiles with dotman
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+StandardInput=null
+StandardOutput=append:%[2]v
+StandardError=append:%[2]v
+ExecStart=%[1]v
+ExecStopPost=%[3]v
+`, opts.command(systemdQuote, "sync", "--non-interactive"), opts.LogPath, opts.command(systemdQuote, "status"))
+}
+
+// TimerUnit starts the service a few minutes after login and then every opts.Every
+func TimerUnit(opts Options) string {
+	return fmt.Sprintf(`[Unit]
+Description=Run dotman sync every %[1]v
+
+[Timer]
+OnStartupSec=5min
+OnUnitActiveSec=%[2]ds
+Unit=%[3]v
+
+[Install]
+WantedBy=timers.target
+`, opts.Every, int(opts.Every.Seconds()), ServiceName)
+}
+
+func (s Systemd) Install(opts Options) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if err := internal.CreateFolder(s.UnitDir); err != nil {
+		return err
+	}
+	if err := internal.CreateFolder(filepath.Dir(opts.LogPath)); err != nil {
+		return err
+	}
+
+	internal.LogVerbose("Writing %v and %v to %v", ServiceName, TimerName, s.UnitDir)
+	if err := internal.WriteFileAtomic(s.servicePath(), []byte(ServiceUnit(opts)), 0644); err != nil {
+		return fmt.Errorf("could not write %v: %w", ServiceName, err)
+	}
+	if err := internal.WriteFileAtomic(s.timerPath(), []byte(TimerUnit(opts)), 0644); err != nil {
+		return fmt.Errorf("could not write %v: %w", TimerName, err)
+	}
+
+	if !s.Manage {
+		return nil
+	}
+	if _, err := internal.Run("systemctl", "--user", "daemon-reload"); err != nil {
+		return fmt.Errorf("could not reload systemd: %w", err)
+	}
+	if _, err := internal.Run("systemctl", "--user", "enable", "--now", TimerName); err != nil {
+		return fmt.Errorf("could not enable %v: %w", TimerName, err)
+	}
+	return nil
+}
+
+func (s Systemd) Status() (Status, error) {
+	status := Status{Backend: BackendSystemd}
+	content, err := os.ReadFile(s.timerPath())
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("could not read %v: %w", TimerName, err)
+	}
+	status.Installed = true
+	status.Files = []string{s.servicePath(), s.timerPath()}
+	status.Every = unitValue(string(content), "OnUnitActiveSec")
+	if every, err := time.ParseDuration(status.Every); err == nil {
+		status.Every = every.String()
+	}
+	if service, err := os.ReadFile(s.servicePath()); err == nil {
+		status.LogPath = strings.TrimPrefix(unitValue(string(service), "StandardOutput"), "append:")
+	}
+
+	if s.Manage {
+		active, _ := internal.RunOutput("systemctl", "--user", "is-active", TimerName)
+		status.Active = strings.TrimSpace(active)
+		next, _ := internal.RunOutput("systemctl", "--user", "show", TimerName,
+			"--property", "NextElapseUSecRealtime", "--value")
+		status.NextRun = strings.TrimSpace(next)
+	}
+	return status, nil
+}
+
+func (s Systemd) Remove() error {
+	if s.Manage {
+		if _, err := internal.Run("systemctl", "--user", "disable", "--now", TimerName); err != nil {
+			internal.LogVerbose("Could not disable %v: %v", TimerName, err)
+		}
+	}
+	for _, path := range []string{s.timerPath(), s.servicePath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove %v: %w", path, err)
+		}
+	}
+	if s.Manage {
+		if _, err := internal.Run("systemctl", "--user", "daemon-reload"); err != nil {
+			return fmt.Errorf("could not reload systemd: %w", err)
+		}
+	}
+	return nil
+}
+
+// unitValue returns the value of the first key=value line for key
+func unitValue(unit, key string) string {
+	for _, line := range strings.Split(unit, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), key+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
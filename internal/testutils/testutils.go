@@ -3,6 +3,7 @@ package testutils
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -138,6 +139,17 @@ func (m *MockUserInput) GetResponse() string {
 	return response
 }
 
+// Confirm answers the next response, so MockUserInput can stand in for a prompter
+func (m *MockUserInput) Confirm(key, question string) (bool, error) {
+	response := strings.ToLower(m.GetResponse())
+	return response == "y" || response == "yes", nil
+}
+
+// Choose answers the next response as the choice
+func (m *MockUserInput) Choose(key, question string, choices ...string) (string, error) {
+	return strings.ToLower(m.GetResponse()), nil
+}
+
 // TestConfig represents a test configuration
 type TestConfig struct {
 	RepoPath string
@@ -166,3 +178,45 @@ func ReadFileContent(path string) (string, error) {
 func Contains(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+// RunGit runs git with the given arguments inside dir and fails the test on error
+func RunGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+// SetupGitRemote creates a bare repository with one commit on main and returns its path.
+// HOME is pointed at testDir so deployed symlinks never touch the real home directory
+func SetupGitRemote(t *testing.T, testDir string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("HOME", testDir)
+	t.Setenv("GIT_AUTHOR_NAME", "dotman")
+	t.Setenv("GIT_AUTHOR_EMAIL", "dotman@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "dotman")
+	t.Setenv("GIT_COMMITTER_EMAIL", "dotman@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(testDir, "gitconfig"))
+	CreateTestFile(t, filepath.Join(testDir, "gitconfig"), "[init]\n\tdefaultBranch = main\n")
+
+	remote := filepath.Join(testDir, "remote.git")
+	RunGit(t, testDir, "init", "--bare", "-b", "main", remote)
+
+	seed := filepath.Join(testDir, "seed")
+	RunGit(t, testDir, "clone", remote, seed)
+	CreateTestFile(t, filepath.Join(seed, "info.json"), "{}")
+	RunGit(t, seed, "add", "-A")
+	RunGit(t, seed, "commit", "-m", "initial")
+	RunGit(t, seed, "push", "origin", "HEAD:main")
+
+	return remote
+}
+
+// CloneGitRemote clones remote into dir, simulating another machine
+func CloneGitRemote(t *testing.T, remote, dir string) {
+	RunGit(t, filepath.Dir(dir), "clone", remote, dir)
+}